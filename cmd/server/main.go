@@ -4,11 +4,22 @@ import (
 	"context"
 	"fmt"
 	"go-clean-arch/config"
+	"go-clean-arch/helper-libs/confighelper"
+	"go-clean-arch/helper-libs/dihelper"
+	"go-clean-arch/helper-libs/echohelper"
+	"go-clean-arch/helper-libs/healthhelper"
+	"go-clean-arch/helper-libs/idempotencyhelper"
+	"go-clean-arch/helper-libs/lifecyclehelper"
 	"go-clean-arch/helper-libs/loghelper"
+	"go-clean-arch/helper-libs/ratelimithelper"
+	"go-clean-arch/helper-libs/redisleaderhelper"
+	"go-clean-arch/helper-libs/redislockhelper"
+	"go-clean-arch/helper-libs/schedulerhelper"
+	"go-clean-arch/helper-libs/tlshelper"
+	"go-clean-arch/internal/api"
 	v1 "go-clean-arch/internal/api/v1"
 	"go-clean-arch/internal/diregistry"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/labstack/echo-contrib/echoprometheus"
@@ -37,6 +48,10 @@ import (
 
 // @securityDefinitions.basic  BasicAuth
 
+// @securityDefinitions.apikey  Bearer Authentication
+// @in                          header
+// @name                        Authorization
+
 // @externalDocs.description  OpenAPI
 // @externalDocs.url          https://swagger.io/resources/open-api/
 func main() {
@@ -49,29 +64,153 @@ func main() {
 		loghelper.Logger.Panic("Can't init zap logger", zap.Error(err))
 	}
 
+	if cfg.LogLevel != "" {
+		if err := loghelper.SetLevel(cfg.LogLevel); err != nil {
+			loghelper.Logger.Panic("Invalid log level", zap.Error(err))
+		}
+	}
+
 	loghelper.Logger.Infof("config: %+v", *cfg)
 
+	appCtx, cancelApp := context.WithCancel(context.Background())
+	defer cancelApp()
+
 	httpServer := echo.New()
+	httpServer.HTTPErrorHandler = api.HTTPErrorHandler
+	httpServer.IPExtractor, err = echohelper.NewIPExtractor(cfg.Server.TrustedProxies)
+	if err != nil {
+		loghelper.Logger.Panic("Invalid trusted proxies", zap.Error(err))
+	}
 	if cfg.Env == "dev" {
 		// use echoSwagger middleware to serve the API docs
 		httpServer.GET("/swagger/*", echoSwagger.WrapHandler)
 		// httpServer.Static("/swaggerui", "swaggerui")
 	}
+	httpServer.Use(echohelper.Trace())
 	httpServer.Use(middleware.Recover())
+	// CORS runs before authentication so preflight requests are answered without credentials
+	if cfg.Server.UseCORs {
+		httpServer.Use(echohelper.CORS(&cfg.Server.Cors))
+	}
+	if cfg.BasicAuth.Enabled {
+		httpServer.Use(echohelper.BasicAuthGuard(echohelper.NewBasicAuthGuardConfig(&cfg.BasicAuth)))
+	}
+	if cfg.Jwt.Enabled {
+		keySet, err := echohelper.NewKeySet(appCtx, &cfg.Jwt)
+		if err != nil {
+			loghelper.Logger.Panic("Can't init jwt key set", zap.Error(err))
+		}
+		httpServer.Use(echohelper.JWTWithConfig(echohelper.JWTConfig{
+			ExcludePaths:    cfg.JwtExcludePaths,
+			KeySet:          keySet,
+			Issuer:          cfg.Jwt.Issuer,
+			Audiences:       cfg.Jwt.Audiences,
+			Leeway:          cfg.Jwt.Leeway,
+			AppSubjectClaim: cfg.Jwt.AppSubjectClaim,
+		}))
+	}
+
+	// Limits are shared by every replica through redis, the limiter runs after JWT so clients can be keyed by subject
+	rateLimiter := echohelper.NewDistributedRateLimiter(echohelper.DistributedRateLimiterConfig{
+		Limiter: diregistry.GetDependency(diregistry.RateLimiterDIName).(ratelimithelper.Limiter),
+		Limit: ratelimithelper.Limit{
+			Rate:   cfg.Server.RateLimit,
+			Period: cfg.Server.RateLimitPeriod,
+			Burst:  cfg.Server.RateLimitBurst,
+		},
+		KeyBy: cfg.Server.RateLimitKeyBy,
+	})
+	httpServer.Use(rateLimiter.Middleware())
+	if cfg.Idempotency.Enabled {
+		httpServer.Use(echohelper.Idempotency(echohelper.IdempotencyConfig{
+			Store:       diregistry.GetDependency(diregistry.IdempotencyStoreDIName).(idempotencyhelper.Store),
+			Locker:      diregistry.GetDependency(diregistry.RedisLockHelperDIName).(*redislockhelper.RedisLockHelper),
+			LockPrefix:  cfg.App + ":idempotency:lock:",
+			Methods:     cfg.Idempotency.Methods,
+			TTL:         cfg.Idempotency.Ttl,
+			LockTimeout: cfg.Idempotency.LockTimeout,
+			LockWait:    cfg.Idempotency.LockWait,
+		}))
+	}
+
+	// Only the log level and the rate limit are applied on reload, other changes wait for a restart
+	configLoader := diregistry.GetDependency(diregistry.ConfigLoaderDIName).(*confighelper.Loader[config.Config])
+	configLoader.OnChange(func(old, new *config.Config) {
+		if new.LogLevel != old.LogLevel && new.LogLevel != "" {
+			if err := loghelper.SetLevel(new.LogLevel); err != nil {
+				loghelper.Logger.Errorw("apply reloaded log level failed", zap.Error(err))
+			}
+		}
+		if new.Server.RateLimit != old.Server.RateLimit {
+			rateLimiter.SetRate(new.Server.RateLimit)
+		}
+		if old.RequiresRestart(new) {
+			loghelper.Logger.Warn("config changes other than log_level and server.rate_limit require a restart")
+		}
+	})
+	configLoader.Watch()
 
 	httpServer.Use(echoprometheus.NewMiddleware("myapp"))   // adds middleware to gather metrics
 	httpServer.GET("/metrics", echoprometheus.NewHandler()) // adds route to serve gathered metrics
 
 	httpServer.Server.Addr = fmt.Sprintf(":%d", cfg.HttpAddress)
-	httpServer.Server.ReadTimeout = 10 * time.Minute
-	httpServer.Server.WriteTimeout = 5 * time.Minute
+	httpServer.Server.ReadTimeout = cfg.Server.ReadTimeout
+	httpServer.Server.ReadHeaderTimeout = cfg.Server.ReadHeaderTimeout
+	httpServer.Server.WriteTimeout = cfg.Server.WriteTimeout
+	httpServer.Server.IdleTimeout = cfg.Server.IdleTimeout
+	if cfg.Server.UseTls {
+		clientAuth, err := tlshelper.ParseClientAuthType(cfg.Server.TlsClientAuth)
+		if err != nil {
+			loghelper.Logger.Panic("Invalid tls client auth", zap.Error(err))
+		}
+		// Certificate files are reloaded on change until appCtx is canceled
+		tlsConfig, err := tlshelper.NewServerTLSConfig(appCtx, &tlshelper.TlsServerOptions{
+			CertBase64:       cfg.Server.TlsCertBase64,
+			KeyBase64:        cfg.Server.TlsKeyBase64,
+			RootCACertBase64: cfg.Server.TlsRootCACertBase64,
+			CertFile:         cfg.Server.TlsCertFile,
+			KeyFile:          cfg.Server.TlsKeyFile,
+			RootCACertFile:   cfg.Server.TlsRootCACertFile,
+			ClientAuth:       clientAuth,
+		})
+		if err != nil {
+			loghelper.Logger.Panic("Can't init tls config", zap.Error(err))
+		}
+		httpServer.Server.TLSConfig = tlsConfig
+	}
 
 	// Init route
 	APIServer := diregistry.GetDependency(diregistry.ApiServerV1DIName).(v1.APIServer)
 	v1publicRouter := httpServer.Group("/v1")
 	APIServer.ConfigRoute(v1publicRouter)
 
-	// Start the HTTP server
+	// Jobs run on the elected leader or under their lock until appCtx is canceled, schedulerDone is closed once
+	// the running jobs returned and leaderDone once the leadership is released
+	schedulerDone := make(chan struct{})
+	leaderDone := make(chan struct{})
+	if cfg.Scheduler.Enabled {
+		leader := diregistry.GetDependency(diregistry.LeaderHelperDIName).(redisleaderhelper.RedisLeaderHelper)
+		scheduler := diregistry.GetDependency(diregistry.SchedulerDIName).(schedulerhelper.Scheduler)
+		go func() {
+			defer close(leaderDone)
+			if err := leader.Run(appCtx); err != nil {
+				loghelper.Logger.Errorw("leader election stopped", zap.Error(err))
+			}
+		}()
+		go func() {
+			defer close(schedulerDone)
+			if err := scheduler.Run(appCtx); err != nil {
+				loghelper.Logger.Errorw("scheduler stopped", zap.Error(err))
+			}
+		}()
+		SchedulerAPIServer := diregistry.GetDependency(diregistry.SchedulerApiServerV1DIName).(v1.APIServer)
+		SchedulerAPIServer.ConfigRoute(v1publicRouter)
+	} else {
+		close(schedulerDone)
+		close(leaderDone)
+	}
+
+	// Start the HTTP server, StartServer serves HTTPS when TLSConfig is set
 	go func() {
 		if err := httpServer.StartServer(httpServer.Server); err != nil {
 			if err == http.ErrServerClosed {
@@ -83,29 +222,52 @@ func main() {
 	}()
 	loghelper.Logger.Infof("Gateway is started on port %d", cfg.HttpAddress)
 
-	// Wait for interrupt signal to gracefully shutdown the server with
-	// a timeout of 10 seconds.
-	signals := make(chan os.Signal, 1)
-	shutdown := make(chan bool, 1)
-	// Grateful shutdown
-	go func() {
-		<-signals
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		// Stop HTTP Server
-		if err := httpServer.Shutdown(ctx); err != nil {
-			loghelper.Logger.WithContext(ctx).Errorf("Failed to shutdown gateway - err", err)
-		}
-
-		loghelper.Logger.Info("*****GRACEFUL SHUTTING DOWN*****")
-		switch cfg.Env {
-		case "prd":
-			time.Sleep(15 * time.Second)
-		case "dev":
-			time.Sleep(3 * time.Second)
-		}
-		shutdown <- true
-	}()
-	<-shutdown
+	// Graceful shutdown: fail readiness first so no new traffic is routed, then drain in-flight requests,
+	// stop background work and close the pools in reverse dependency order.
+	healthRegistry := diregistry.GetDependency(diregistry.HealthRegistryDIName).(healthhelper.Registry)
+	lifecycle := lifecyclehelper.NewManager(&lifecyclehelper.ManagerOptions{})
+	lifecycle.OnShutdown(
+		lifecyclehelper.Stage{
+			Name:    "readiness",
+			Timeout: cfg.Shutdown.ReadinessDelay + time.Second,
+			Hook: func(ctx context.Context) error {
+				loghelper.Logger.Info("*****GRACEFUL SHUTTING DOWN*****")
+				healthRegistry.MarkShuttingDown()
+				return lifecyclehelper.Sleep(ctx, cfg.Shutdown.ReadinessDelay)
+			},
+		},
+		lifecyclehelper.Stage{
+			Name:    "http",
+			Timeout: cfg.Shutdown.HttpTimeout,
+			Hook:    httpServer.Shutdown,
+		},
+		lifecyclehelper.Stage{
+			Name:    "background",
+			Timeout: cfg.Shutdown.BackgroundTimeout,
+			Hook: func(ctx context.Context) error {
+				// Leader election, jobs and subscriptions run with appCtx
+				cancelApp()
+				// The leadership is released before the redis client is closed by the resources stage
+				for _, done := range []chan struct{}{schedulerDone, leaderDone} {
+					select {
+					case <-done:
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				}
+				return nil
+			},
+		},
+		lifecyclehelper.Stage{
+			Name:    "resources",
+			Timeout: cfg.Shutdown.ResourcesTimeout,
+			Hook: func(ctx context.Context) error {
+				return dihelper.CleanDependency()
+			},
+		},
+	)
+	if err := lifecycle.Wait(); err != nil {
+		loghelper.Logger.Errorw("graceful shutdown failed", zap.Error(err))
+	}
 	loghelper.Logger.Info("*****SHUTDOWN*****")
 }
@@ -1,46 +1,155 @@
 package config
 
 import (
-	"bytes"
-	"strings"
+	"crypto/tls"
+	"fmt"
+	"go-clean-arch/helper-libs/commonhelper"
+	"go-clean-arch/helper-libs/confighelper"
+	"go-clean-arch/helper-libs/echohelper"
+	"go-clean-arch/helper-libs/ratelimithelper"
+	"go-clean-arch/helper-libs/tlshelper"
+	"net"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"time"
+)
+
+const (
+	IdempotencyStore_Redis    = "redis"
+	IdempotencyStore_Postgres = "postgres"
 
-	"github.com/spf13/viper"
-	_ "github.com/spf13/viper/remote"
+	// adminPath are the scheduler routes, excluded from jwt and guarded by basic auth.
+	adminPath = "/v1/admin/*"
 )
 
+// defaultConfig declares every key, secrets are left empty and are set through env variables or references
+// such as `file:///run/secrets/db_password`, `env:DB_PASSWORD`, `base64:...` or `enc:v1:...`, see confighelper.SecretResolver.
+// The defaults alone must pass Validate, so features requiring a secret are disabled.
 var defaultConfig = []byte(`
 app: card-integrate-proxy
 env: dev
+log_level: info
 http_address: 8280
+server:
+  rate_limit: 100
+  rate_limit_algorithm: token_bucket
+  rate_limit_period: 1s
+  rate_limit_burst: 0
+  rate_limit_key_by:
+    - ip
+  trusted_proxies: []
+  use_tls: false
+  tls_cert_file:
+  tls_key_file:
+  tls_rootca_cert_file:
+  tls_cert_base64:
+  tls_key_base64:
+  tls_rootca_cert_base64:
+  tls_client_auth: none
+  read_timeout: 10m
+  read_header_timeout: 10s
+  write_timeout: 5m
+  idle_timeout: 2m
+  use_cors: false
+  cors:
+    allow_origins:
+    allow_methods:
+      - GET
+      - HEAD
+      - PUT
+      - PATCH
+      - POST
+      - DELETE
+    allow_headers:
+    expose_headers:
+    allow_credentials: false
+    max_age: 0
+    policies:
 sensitive_fields:
   password: (?P<FIRST>[0-9]{6})(?P<MASK>[0-9]*)(?P<LAST>[0-9]{4})
 basic_auth:
+  enabled: false
+  realm: operations
   username: admin
-  password: 12345678
+  password:
+  password_hash:
+  password_hash_env:
+  paths:
+    - /metrics
+    - /swagger/*
+    - /v1/health/*
+    - /v1/admin/*
+  credentials:
+jwt:
+  enabled: false
+  issuer:
+  audiences:
+  algorithm: RS256
+  jwks_url:
+  jwks_refresh_interval: 15m
+  public_key_file:
+  public_key_base64:
+  hmac_secret:
+  leeway: 30s
+  app_subject_claim:
 jwt_exclude_paths:
+  - /metrics
+  - /swagger/*
+  - /v1/health/*
+  - /v1/admin/*
+idempotency:
+  enabled: true
+  store: redis
+  ttl: 24h
+  lock_timeout: 30s
+  lock_wait: 5s
+  methods:
+    - POST
+    - PATCH
 database:
   host: 0.0.0.0
   port: 5432
   username: pmtrade
-  password: Abc12345
+  password:
   database: pmtrade
   search_path: pst
   auto_migration: false
 cache:
   host: 0.0.0.0
   port: 6379
-  password: 12345678
+  password:
+  local_size: 10000
+  local_ttl: 1m
+scheduler:
+  enabled: false
+  leader_ttl: 10s
+  max_concurrent: 4
+  history_retention: 720h
+shutdown:
+  readiness_delay: 3s
+  http_timeout: 10s
+  background_timeout: 5s
+  resources_timeout: 5s
 `)
 
 type (
 	Config struct {
-		Env             string            `mapstructure:"env"`
-		App             string            `mapstructure:"app"`
-		HttpAddress     uint32            `mapstructure:"http_address"`
-		SensitiveFields map[string]string `mapstructure:"sensitive_fields"`
-		JwtExcludePaths []string          `mapstructure:"jwt_exclude_paths"`
-		Database        databaseConfig    `mapstructure:"database"`
-		Cache           cacheConfig       `mapstructure:"cache"`
+		Env             string                            `mapstructure:"env"`
+		App             string                            `mapstructure:"app"`
+		LogLevel        string                            `mapstructure:"log_level"`
+		HttpAddress     uint32                            `mapstructure:"http_address"`
+		Server          confighelper.ServerConfig         `mapstructure:"server"`
+		SensitiveFields map[string]string                 `mapstructure:"sensitive_fields"`
+		BasicAuth       confighelper.BasicAuthGuardConfig `mapstructure:"basic_auth"`
+		Jwt             confighelper.JwtConfig            `mapstructure:"jwt"`
+		JwtExcludePaths []string                          `mapstructure:"jwt_exclude_paths"`
+		Idempotency     confighelper.IdempotencyConfig    `mapstructure:"idempotency"`
+		Database        databaseConfig                    `mapstructure:"database"`
+		Cache           cacheConfig                       `mapstructure:"cache"`
+		Scheduler       confighelper.SchedulerConfig      `mapstructure:"scheduler"`
+		Shutdown        confighelper.ShutdownConfig       `mapstructure:"shutdown"`
 	}
 
 	// kafkaConfig struct {
@@ -58,7 +167,7 @@ type (
 		Host          string `mapstructure:"host"`
 		Port          uint32 `mapstructure:"port"`
 		Username      string `mapstructure:"username"`
-		Password      string `mapstructure:"password"`
+		Password      string `mapstructure:"password" secret:"true"`
 		Database      string `mapstructure:"database"`
 		SearchPath    string `mapstructure:"search_path"`
 		AutoMigration bool   `mapstructure:"auto_migration"`
@@ -67,22 +176,150 @@ type (
 	cacheConfig struct {
 		Host     string `mapstructure:"host"`
 		Port     uint32 `mapstructure:"port"`
-		Password string `mapstructure:"password"`
+		Password string `mapstructure:"password" secret:"true"`
+		// LocalSize and LocalTTL bound the in-process tier of the tiered cache helper
+		LocalSize int           `mapstructure:"local_size"`
+		LocalTTL  time.Duration `mapstructure:"local_ttl"`
 	}
 )
 
+// NewLoader layers the embedded defaults, the file of the `-c`/`-config` flag or CONFIG_FILE env,
+// env variables and the remote provider of the CONFIG_REMOTE_* env variables.
+func NewLoader() *confighelper.Loader[Config] {
+	return confighelper.NewLoader[Config](&confighelper.LoaderOptions{
+		DefaultConfig: defaultConfig,
+		ConfigFile:    confighelper.ConfigFileFromArgs(os.Args[1:]),
+		Remote:        confighelper.RemoteConfigFromEnv(),
+	})
+}
+
 func Load() (*Config, error) {
-	var cfg = &Config{}
-	viper.SetConfigType("yaml")
-	err := viper.ReadConfig(bytes.NewBuffer(defaultConfig))
-	if err != nil {
-		return nil, err
+	return NewLoader().Load()
+}
+
+// String hides the fields tagged `secret:"true"`, so logging the config never prints a resolved secret.
+func (c Config) String() string {
+	return confighelper.Redact(c)
+}
+
+// Validate reports every missing required field and out of range value.
+func (c *Config) Validate() error {
+	errs := &confighelper.ValidationErrors{}
+	errs.Addf(c.App == "", "app is required")
+	errs.Addf(!slices.Contains([]commonhelper.Environment{commonhelper.ENV__DEV, commonhelper.ENV__PRD}, commonhelper.Environment(c.Env)),
+		"env must be %s or %s, got %q", commonhelper.ENV__DEV, commonhelper.ENV__PRD, c.Env)
+	errs.Addf(c.LogLevel != "" && !isLogLevel(c.LogLevel), "log_level must be debug, info, warn or error, got %q", c.LogLevel)
+	errs.Addf(c.HttpAddress == 0 || c.HttpAddress > 65535, "http_address must be between 1 and 65535, got %d", c.HttpAddress)
+	errs.Addf(c.Server.RateLimit < 0, "server.rate_limit must not be negative, got %d", c.Server.RateLimit)
+	_, err := ratelimithelper.ParseAlgorithm(c.Server.RateLimitAlgorithm)
+	errs.Addf(err != nil, "server.rate_limit_algorithm must be token_bucket or sliding_window, got %q", c.Server.RateLimitAlgorithm)
+	errs.Addf(c.Server.RateLimitPeriod < 0, "server.rate_limit_period must not be negative, got %s", c.Server.RateLimitPeriod)
+	errs.Addf(c.Server.RateLimitBurst < 0, "server.rate_limit_burst must not be negative, got %d", c.Server.RateLimitBurst)
+	for _, keyBy := range c.Server.RateLimitKeyBy {
+		errs.Addf(!slices.Contains([]string{echohelper.RateLimitKeyBy_IP, echohelper.RateLimitKeyBy_Subject, echohelper.RateLimitKeyBy_Route}, keyBy),
+			"server.rate_limit_key_by must be ip, subject or route, got %q", keyBy)
+	}
+	for _, cidr := range c.Server.TrustedProxies {
+		_, _, err := net.ParseCIDR(cidr)
+		errs.Addf(err != nil, "server.trusted_proxies must be CIDRs, got %q", cidr)
+	}
+	errs.Addf(c.Server.ReadTimeout < 0, "server.read_timeout must not be negative, got %s", c.Server.ReadTimeout)
+	errs.Addf(c.Server.ReadHeaderTimeout < 0, "server.read_header_timeout must not be negative, got %s", c.Server.ReadHeaderTimeout)
+	errs.Addf(c.Server.WriteTimeout < 0, "server.write_timeout must not be negative, got %s", c.Server.WriteTimeout)
+	errs.Addf(c.Server.IdleTimeout < 0, "server.idle_timeout must not be negative, got %s", c.Server.IdleTimeout)
+	clientAuth, err := tlshelper.ParseClientAuthType(c.Server.TlsClientAuth)
+	errs.Addf(err != nil, "server.tls_client_auth must be none, request, require, verify_if_given or require_and_verify, got %q", c.Server.TlsClientAuth)
+	if c.Server.UseTls {
+		hasFiles := c.Server.TlsCertFile != "" && c.Server.TlsKeyFile != ""
+		hasBase64 := c.Server.TlsCertBase64 != "" && c.Server.TlsKeyBase64 != ""
+		errs.Addf(!hasFiles && !hasBase64, "server requires tls_cert_file and tls_key_file, or tls_cert_base64 and tls_key_base64 when use_tls")
+		verifiesClient := clientAuth == tls.VerifyClientCertIfGiven || clientAuth == tls.RequireAndVerifyClientCert
+		errs.Addf(verifiesClient && c.Server.TlsRootCACertFile == "" && c.Server.TlsRootCACertBase64 == "",
+			"server requires tls_rootca_cert_file or tls_rootca_cert_base64 to verify client certificates")
+	}
+	if c.Server.UseCORs {
+		validateCorsPolicy(errs, "server.cors", c.Server.Cors.CorsPolicy)
+		for i, policy := range c.Server.Cors.Policies {
+			errs.Addf(len(policy.Paths) == 0, "server.cors.policies[%d].paths is required", i)
+			validateCorsPolicy(errs, fmt.Sprintf("server.cors.policies[%d]", i), policy)
+		}
+	}
+	for field, pattern := range c.SensitiveFields {
+		_, err := regexp.Compile(pattern)
+		errs.Addf(err != nil, "sensitive_fields.%s is not a valid regex: %v", field, err)
+	}
+
+	errs.Addf(c.Database.Host == "", "database.host is required")
+	errs.Addf(c.Database.Port == 0 || c.Database.Port > 65535, "database.port must be between 1 and 65535, got %d", c.Database.Port)
+	errs.Addf(c.Database.Username == "", "database.username is required")
+	errs.Addf(c.Database.Database == "", "database.database is required")
+	errs.Addf(c.Cache.Host == "", "cache.host is required")
+	errs.Addf(c.Cache.Port == 0 || c.Cache.Port > 65535, "cache.port must be between 1 and 65535, got %d", c.Cache.Port)
+	errs.Addf(c.Cache.LocalSize <= 0, "cache.local_size must be positive, got %d", c.Cache.LocalSize)
+	errs.Addf(c.Cache.LocalTTL <= 0, "cache.local_ttl must be positive, got %s", c.Cache.LocalTTL)
+
+	if c.BasicAuth.Enabled {
+		hasCredential := c.BasicAuth.Username != ""
+		errs.Addf(hasCredential && !hasPassword(c.BasicAuth.BasicAuth), "basic_auth.password or password_hash is required for %s", c.BasicAuth.Username)
+		for i, credential := range c.BasicAuth.Credentials {
+			errs.Addf(credential.Username == "", "basic_auth.credentials[%d].username is required", i)
+			errs.Addf(!hasPassword(credential), "basic_auth.credentials[%d].password or password_hash is required", i)
+			hasCredential = hasCredential || credential.Username != ""
+		}
+		errs.Addf(!hasCredential, "basic_auth requires username or credentials when enabled")
 	}
-	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "__"))
-	viper.AutomaticEnv()
-	err = viper.Unmarshal(&cfg)
-	if err != nil {
-		return nil, err
+	if c.Jwt.Enabled {
+		errs.Addf(c.Jwt.JwksUrl == "" && c.Jwt.PublicKeyFile == "" && c.Jwt.PublicKeyBase64 == "" && c.Jwt.HmacSecret == "",
+			"jwt requires jwks_url, public_key_file, public_key_base64 or hmac_secret when enabled")
+		errs.Addf(c.Jwt.Leeway < 0, "jwt.leeway must not be negative, got %s", c.Jwt.Leeway)
+	}
+
+	if c.Idempotency.Enabled {
+		errs.Addf(!slices.Contains([]string{IdempotencyStore_Redis, IdempotencyStore_Postgres}, c.Idempotency.Store),
+			"idempotency.store must be %s or %s, got %q", IdempotencyStore_Redis, IdempotencyStore_Postgres, c.Idempotency.Store)
+		errs.Addf(c.Idempotency.Ttl < 0, "idempotency.ttl must not be negative, got %s", c.Idempotency.Ttl)
+		errs.Addf(c.Idempotency.LockTimeout < 0, "idempotency.lock_timeout must not be negative, got %s", c.Idempotency.LockTimeout)
+		errs.Addf(c.Idempotency.LockWait < 0, "idempotency.lock_wait must not be negative, got %s", c.Idempotency.LockWait)
+	}
+
+	if c.Scheduler.Enabled {
+		errs.Addf(!c.BasicAuth.Enabled || !slices.Contains(c.BasicAuth.Paths, adminPath),
+			"scheduler requires basic_auth to be enabled and to guard %s", adminPath)
+		errs.Addf(c.Scheduler.LeaderTtl <= 0, "scheduler.leader_ttl must be positive, got %s", c.Scheduler.LeaderTtl)
+		errs.Addf(c.Scheduler.MaxConcurrent < 0, "scheduler.max_concurrent must not be negative, got %d", c.Scheduler.MaxConcurrent)
+		errs.Addf(c.Scheduler.HistoryRetention < 0, "scheduler.history_retention must not be negative, got %s", c.Scheduler.HistoryRetention)
+	}
+
+	errs.Addf(c.Shutdown.ReadinessDelay < 0, "shutdown.readiness_delay must not be negative, got %s", c.Shutdown.ReadinessDelay)
+	errs.Addf(c.Shutdown.HttpTimeout < 0, "shutdown.http_timeout must not be negative, got %s", c.Shutdown.HttpTimeout)
+	errs.Addf(c.Shutdown.BackgroundTimeout < 0, "shutdown.background_timeout must not be negative, got %s", c.Shutdown.BackgroundTimeout)
+	errs.Addf(c.Shutdown.ResourcesTimeout < 0, "shutdown.resources_timeout must not be negative, got %s", c.Shutdown.ResourcesTimeout)
+	return errs.Err()
+}
+
+// RequiresRestart reports whether next changes more than the settings applied on reload, i.e. log level and rate limit.
+func (c *Config) RequiresRestart(next *Config) bool {
+	current, candidate := *c, *next
+	candidate.LogLevel = current.LogLevel
+	candidate.Server.RateLimit = current.Server.RateLimit
+	return !reflect.DeepEqual(current, candidate)
+}
+
+func validateCorsPolicy(errs *confighelper.ValidationErrors, name string, policy confighelper.CorsPolicy) {
+	errs.Addf(len(policy.AllowOrigins) == 0, "%s.allow_origins is required when use_cors", name)
+	errs.Addf(policy.AllowCredentials && slices.Contains(policy.AllowOrigins, "*"),
+		"%s.allow_credentials must not be used with the `*` origin", name)
+	errs.Addf(policy.MaxAge < 0, "%s.max_age must not be negative, got %d", name, policy.MaxAge)
+}
+
+func hasPassword(credential confighelper.BasicAuth) bool {
+	return credential.Password != "" || credential.PasswordHash != "" || credential.PasswordHashEnv != ""
+}
+
+func isLogLevel(level string) bool {
+	switch commonhelper.LogLevel(level) {
+	case commonhelper.LOG_LEVEL__DEBUG, commonhelper.LOG_LEVEL__INFO, commonhelper.LOG_LEVEL__WARN, commonhelper.LOG_LEVEL__ERROR:
+		return true
 	}
-	return cfg, nil
+	return false
 }
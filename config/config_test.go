@@ -0,0 +1,58 @@
+package config
+
+import (
+	"go-clean-arch/helper-libs/confighelper"
+	"strings"
+	"testing"
+)
+
+func TestDefaultConfigIsValid(t *testing.T) {
+	cfg, err := confighelper.NewLoader[Config](&confighelper.LoaderOptions{
+		DefaultConfig: defaultConfig,
+	}).Load()
+	if err != nil {
+		t.Fatalf("expected the embedded defaults to be valid, actual: %v", err)
+	}
+	if cfg.BasicAuth.Enabled {
+		t.Error("expected basic auth to be disabled until a password is configured")
+	}
+}
+
+func TestValidateBasicAuthPassword(t *testing.T) {
+	cfg, err := confighelper.NewLoader[Config](&confighelper.LoaderOptions{
+		DefaultConfig: defaultConfig,
+	}).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.BasicAuth.Enabled = true
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "basic_auth.password or password_hash is required for admin") {
+		t.Errorf("expected basic auth without password to be rejected, actual: %v", err)
+	}
+	cfg.BasicAuth.Password = "secret"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected basic auth with password to be valid, actual: %v", err)
+	}
+}
+
+func TestValidateSchedulerRequiresBasicAuth(t *testing.T) {
+	cfg, err := confighelper.NewLoader[Config](&confighelper.LoaderOptions{
+		DefaultConfig: defaultConfig,
+	}).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Scheduler.Enabled = true
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "scheduler requires basic_auth") {
+		t.Errorf("expected the scheduler without basic auth to be rejected, actual: %v", err)
+	}
+	cfg.BasicAuth.Enabled = true
+	cfg.BasicAuth.Password = "secret"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected the scheduler guarded by basic auth to be valid, actual: %v", err)
+	}
+	cfg.BasicAuth.Paths = []string{"/metrics"}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "scheduler requires basic_auth") {
+		t.Errorf("expected the scheduler without guarded admin routes to be rejected, actual: %v", err)
+	}
+}
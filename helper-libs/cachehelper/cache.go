@@ -7,17 +7,35 @@ import (
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
 )
 
 type CacheMessage struct {
 	redis.Message
+	// TraceId of the publisher when the payload is a JSON object carrying it
+	TraceId string
 }
 
 type SubscribeFunc func(CacheMessage) error
 
+// handleCacheMessage runs subscribeFunc and logs its error, pub/sub has no redelivery, use StreamHelper when
+// messages must not be lost.
+func handleCacheMessage(subscribeFunc SubscribeFunc, message CacheMessage) {
+	message = newCacheMessage(message)
+	if err := subscribeFunc(message); err != nil {
+		loghelper.Logger.WithContext(message.Context()).Warnw("failed to handle cache message", zap.String("channel", message.Channel), zap.Error(err))
+	}
+}
+
 type (
 	CacheConfigOptions struct {
 		RedisClientHelper *redisclienthelper.RedisClientHelper
+		// Codec of the stored values, plain JSON is written when neither Codec nor Compressor is set.
+		// Values are read whatever codec wrote them.
+		Codec      Codec
+		Compressor Compressor
+		// CompressionThreshold is the encoded size from which values are compressed, it falls back to 1024 bytes.
+		CompressionThreshold int
 	}
 )
 
@@ -32,6 +50,8 @@ type CacheHelper interface {
 	DelMulti(ctx context.Context, keys ...string) error
 	GetKeysByPattern(ctx context.Context, pattern string, cursor uint64, limit int64) ([]string, uint64, error)
 	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error)
+	// SubscribeMessage subscribes to the keySpace channel, it blocks until ctx is done or the subscription is
+	// closed. Use KeyEventHelper for keyspace notifications.
 	SubscribeMessage(ctx context.Context, keySpace string, subscribeFunc SubscribeFunc)
 	PublishMessage(ctx context.Context, keySpace string, message interface{}) error
 	GetMulti(ctx context.Context, data interface{}, keys ...string) ([]interface{}, error)
@@ -54,6 +74,11 @@ type CacheHelperEnhancement interface {
 	CacheHelper
 	GetTransaction(ctx context.Context, transactionID string) CacheTransactionExecution
 	GetPipeline(ctx context.Context, transactionID string) CachePipelineExecution
+	// NewPipeline returns a typed pipeline, prefer it over GetPipeline.
+	NewPipeline(ctx context.Context) CachePipeline
+	// NewTransaction returns a typed pipeline sent inside MULTI/EXEC, in cluster mode the keys of its multi-key
+	// commands must share a slot.
+	NewTransaction(ctx context.Context) CachePipeline
 }
 
 type CacheCommandType string
@@ -127,10 +152,12 @@ func NewCacheHelper(opts *CacheConfigOptions) CacheHelper {
 	if opts.RedisClientHelper.ClusterClient != nil {
 		return &clusterRedisHelper{
 			clusterClient: opts.RedisClientHelper.ClusterClient,
+			values:        newValueEncoder(opts),
 		}
 	}
 
 	return &redisHelper{
 		client: opts.RedisClientHelper.Client,
+		values: newValueEncoder(opts),
 	}
 }
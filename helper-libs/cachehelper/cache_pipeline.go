@@ -3,6 +3,7 @@ package cachehelper
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strconv"
 	"time"
 
@@ -66,49 +67,63 @@ func (r *baseRedisCachePipeline) Discard(ctx context.Context) error {
 	return nil
 }
 
+// BuildCommand queues a command of the legacy builder, bad arguments are returned as errors. Prefer the typed
+// CachePipeline of NewPipeline.
 func (r *baseRedisCachePipeline) BuildCommand(ctx context.Context, cacheCommandType CacheCommandType, data ...interface{}) (err error) {
 
 	if len(data) == 0 {
 		return errors.New("missing data to process")
 	}
-	var (
-		keyCache string = data[0].(string)
-		cmd      redis.Cmder
-	)
+	keyCache, err := commandArg[string](data, 0)
+	if err != nil {
+		return err
+	}
+	var cmd redis.Cmder
 	switch cacheCommandType {
-	case CacheCommandTypeGetInterface:
+	case CacheCommandTypeGet, CacheCommandTypeGetInterface:
 		cmd = r.Pipeliner.Get(ctx, keyCache)
 	case CacheCommandTypeAddMemberWithScore:
-		member := redis.Z{
-			Member: data[1].(string),
-			Score:  data[2].(float64),
+		member, err := commandArg[string](data, 1)
+		if err != nil {
+			return err
 		}
-		cmd = r.Pipeliner.ZAdd(ctx, keyCache, member)
+		score, err := commandArg[float64](data, 2)
+		if err != nil {
+			return err
+		}
+		cmd = r.Pipeliner.ZAdd(ctx, keyCache, redis.Z{Member: member, Score: score})
 	case CacheCommandTypeGetMembersWithScore:
-		min, _ := strconv.ParseInt(data[1].(string), 10, 64)
-		max, _ := strconv.ParseInt(data[2].(string), 10, 64)
+		min, max, err := commandRange(data)
+		if err != nil {
+			return err
+		}
 		cmd = r.Pipeliner.ZRangeWithScores(ctx, keyCache, min, max)
 	case CacheCommandTypeRemoveMembersWithScore:
-		var (
-			min string = data[1].(string)
-			max string = data[2].(string)
-		)
+		min, err := commandArg[string](data, 1)
+		if err != nil {
+			return err
+		}
+		max, err := commandArg[string](data, 2)
+		if err != nil {
+			return err
+		}
 		cmd = r.Pipeliner.ZRemRangeByScore(ctx, keyCache, min, max)
 
 	case CacheCommandTypeExpire:
-		var (
-			ttl      uint32        = data[1].(uint32)
-			duration time.Duration = data[2].(time.Duration)
-		)
-		cmd = r.Pipeliner.Expire(ctx, keyCache, time.Duration(ttl)*(duration))
+		expiration, err := commandDuration(data, 1)
+		if err != nil {
+			return err
+		}
+		cmd = r.Pipeliner.Expire(ctx, keyCache, expiration)
 	case CacheCommandTypeSetNX:
-		var (
-			value    interface{}   = data[1]
-			ttl      uint32        = data[2].(uint32)
-			duration time.Duration = data[3].(time.Duration)
-		)
-
-		cmd = r.Pipeliner.SetNX(ctx, keyCache, value, time.Duration(ttl)*(duration))
+		if len(data) < 2 {
+			return errors.New("missing argument 1")
+		}
+		expiration, err := commandDuration(data, 2)
+		if err != nil {
+			return err
+		}
+		cmd = r.Pipeliner.SetNX(ctx, keyCache, data[1], expiration)
 	case CacheCommandTypeIncrease:
 		cmd = r.Pipeliner.Incr(ctx, keyCache)
 	case CacheCommandTypeDel:
@@ -125,3 +140,42 @@ func (r *baseRedisCachePipeline) BuildCommand(ctx context.Context, cacheCommandT
 func (r *baseRedisCachePipeline) GetCommands(context.Context) (CacheLazyExecute, error) {
 	return r, nil
 }
+
+func commandArg[T any](data []interface{}, index int) (T, error) {
+	var zero T
+	if index >= len(data) {
+		return zero, fmt.Errorf("missing argument %d", index)
+	}
+	value, ok := data[index].(T)
+	if !ok {
+		return zero, fmt.Errorf("argument %d must be %T, got %T", index, zero, data[index])
+	}
+	return value, nil
+}
+
+// commandDuration reads the uint32 count and the time.Duration unit of the expiration commands.
+func commandDuration(data []interface{}, index int) (time.Duration, error) {
+	ttl, err := commandArg[uint32](data, index)
+	if err != nil {
+		return 0, err
+	}
+	unit, err := commandArg[time.Duration](data, index+1)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(ttl) * unit, nil
+}
+
+func commandRange(data []interface{}) (int64, int64, error) {
+	start, err := commandArg[string](data, 1)
+	if err != nil {
+		return 0, 0, err
+	}
+	stop, err := commandArg[string](data, 2)
+	if err != nil {
+		return 0, 0, err
+	}
+	startIndex, _ := strconv.ParseInt(start, 10, 64)
+	stopIndex, _ := strconv.ParseInt(stop, 10, 64)
+	return startIndex, stopIndex, nil
+}
@@ -0,0 +1,315 @@
+package cachehelper
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+// Values written with a Codec or a Compressor start with a 3 bytes header: the magic byte, the codec id and the
+// compression id. Values without it are plain JSON, as written before codecs existed, so both can be read during rollout.
+const (
+	CodecId_JSON     CodecId = 1
+	CodecId_Msgpack  CodecId = 2
+	CodecId_Gob      CodecId = 3
+	CodecId_Protobuf CodecId = 4
+
+	CompressionId_None   CompressionId = 0
+	CompressionId_Zstd   CompressionId = 1
+	CompressionId_Snappy CompressionId = 2
+
+	valueHeaderMagic            byte = 0xC5
+	valueHeaderSize                  = 3
+	defaultCompressionThreshold      = 1024
+)
+
+var (
+	ErrUnknownCodec       = errors.New("cache: unknown codec")
+	ErrUnknownCompression = errors.New("cache: unknown compression")
+	ErrNotProtoMessage    = errors.New("cache: protobuf codec requires a proto.Message")
+
+	registryMu  sync.RWMutex
+	codecs      = map[CodecId]Codec{}
+	compressors = map[CompressionId]Compressor{}
+)
+
+type (
+	CodecId byte
+
+	CompressionId byte
+
+	// Codec encodes the values of Set, SetNX and the non string values of hashes.
+	Codec interface {
+		Id() CodecId
+		Marshal(value interface{}) ([]byte, error)
+		Unmarshal(data []byte, value interface{}) error
+	}
+
+	Compressor interface {
+		Id() CompressionId
+		Compress(data []byte) ([]byte, error)
+		Decompress(data []byte) ([]byte, error)
+	}
+
+	// valueEncoder writes plain JSON unless a codec or a compressor is configured.
+	valueEncoder struct {
+		codec      Codec
+		compressor Compressor
+		threshold  int
+	}
+
+	// rawValue receives the stored bytes of a value, header included, without decoding them.
+	rawValue []byte
+
+	jsonCodec     struct{}
+	msgpackCodec  struct{}
+	gobCodec      struct{}
+	protobufCodec struct{}
+
+	// zstdCompressor creates its encoder and decoder on first use, they are safe for concurrent use.
+	zstdCompressor struct {
+		once    sync.Once
+		encoder *zstd.Encoder
+		decoder *zstd.Decoder
+		err     error
+	}
+	snappyCompressor struct{}
+)
+
+func init() {
+	for _, codec := range []Codec{JSONCodec(), MsgpackCodec(), GobCodec(), ProtobufCodec()} {
+		RegisterCodec(codec)
+	}
+	for _, compressor := range []Compressor{ZstdCompressor(), SnappyCompressor()} {
+		RegisterCompressor(compressor)
+	}
+}
+
+// RegisterCodec makes the values written by codec readable by every helper, the built-in codecs are registered already.
+func RegisterCodec(codec Codec) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	codecs[codec.Id()] = codec
+}
+
+func RegisterCompressor(compressor Compressor) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	compressors[compressor.Id()] = compressor
+}
+
+func JSONCodec() Codec { return jsonCodec{} }
+
+// MsgpackCodec honors the json struct tags, so entities can switch from JSON without new tags.
+func MsgpackCodec() Codec { return msgpackCodec{} }
+
+// GobCodec keeps the Go types, values stored in interfaces must be registered with gob.Register.
+func GobCodec() Codec { return gobCodec{} }
+
+// ProtobufCodec only encodes proto.Message values.
+func ProtobufCodec() Codec { return protobufCodec{} }
+
+func ZstdCompressor() Compressor { return &zstdCompressor{} }
+
+func SnappyCompressor() Compressor { return snappyCompressor{} }
+
+func newValueEncoder(opts *CacheConfigOptions) *valueEncoder {
+	encoder := &valueEncoder{
+		codec:      opts.Codec,
+		compressor: opts.Compressor,
+		threshold:  opts.CompressionThreshold,
+	}
+	if encoder.compressor != nil && encoder.codec == nil {
+		encoder.codec = JSONCodec()
+	}
+	if encoder.threshold <= 0 {
+		encoder.threshold = defaultCompressionThreshold
+	}
+	return encoder
+}
+
+func (e *valueEncoder) encode(value interface{}) ([]byte, error) {
+	if e == nil || e.codec == nil {
+		return json.Marshal(value)
+	}
+	data, err := e.codec.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	compression := CompressionId_None
+	if e.compressor != nil && len(data) >= e.threshold {
+		if data, err = e.compressor.Compress(data); err != nil {
+			return nil, err
+		}
+		compression = e.compressor.Id()
+	}
+	return append([]byte{valueHeaderMagic, byte(e.codec.Id()), byte(compression)}, data...), nil
+}
+
+// encodeField keeps strings as they are so hash fields stay readable and countable, other values are encoded.
+func (e *valueEncoder) encodeField(value interface{}) (string, error) {
+	if stringValue, isString := value.(string); isString {
+		return stringValue, nil
+	}
+	data, err := e.encode(value)
+	return string(data), err
+}
+
+// DecodeValue decodes a stored value into value whatever codec wrote it, e.g. the fields returned by HGet or HGetAll.
+func DecodeValue(data []byte, value interface{}) error {
+	target, commit := decodeTarget(value)
+	if raw, ok := target.(*rawValue); ok {
+		*raw = append((*raw)[:0], data...)
+		return nil
+	}
+	if len(data) < valueHeaderSize || data[0] != valueHeaderMagic {
+		return json.Unmarshal(data, value)
+	}
+
+	codecId, compression := CodecId(data[1]), CompressionId(data[2])
+	registryMu.RLock()
+	codec, hasCodec := codecs[codecId]
+	compressor, hasCompressor := compressors[compression]
+	registryMu.RUnlock()
+	if !hasCodec {
+		return fmt.Errorf("%w: %d", ErrUnknownCodec, codecId)
+	}
+
+	data = data[valueHeaderSize:]
+	if compression != CompressionId_None {
+		if !hasCompressor {
+			return fmt.Errorf("%w: %d", ErrUnknownCompression, compression)
+		}
+		var err error
+		if data, err = compressor.Decompress(data); err != nil {
+			return err
+		}
+	}
+	if codecId == CodecId_JSON {
+		return codec.Unmarshal(data, value)
+	}
+	if err := codec.Unmarshal(data, target); err != nil {
+		return err
+	}
+	commit()
+	return nil
+}
+
+// decodeTarget unwraps the *interface{} the helpers pass to decoders, JSON decodes through it but the other codecs
+// need the pointer it holds. A non pointer held value is replaced by the decoded value of its type on commit.
+func decodeTarget(value interface{}) (interface{}, func()) {
+	holder, ok := value.(*interface{})
+	if !ok || *holder == nil {
+		return value, func() {}
+	}
+	if reflect.TypeOf(*holder).Kind() == reflect.Ptr {
+		return *holder, func() {}
+	}
+	target := reflect.New(reflect.TypeOf(*holder))
+	return target.Interface(), func() { *holder = target.Elem().Interface() }
+}
+
+func (c jsonCodec) Id() CodecId { return CodecId_JSON }
+
+func (c jsonCodec) Marshal(value interface{}) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (c jsonCodec) Unmarshal(data []byte, value interface{}) error {
+	return json.Unmarshal(data, value)
+}
+
+func (c msgpackCodec) Id() CodecId { return CodecId_Msgpack }
+
+func (c msgpackCodec) Marshal(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := msgpack.NewEncoder(&buf)
+	encoder.SetCustomStructTag("json")
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c msgpackCodec) Unmarshal(data []byte, value interface{}) error {
+	decoder := msgpack.NewDecoder(bytes.NewReader(data))
+	decoder.SetCustomStructTag("json")
+	return decoder.Decode(value)
+}
+
+func (c gobCodec) Id() CodecId { return CodecId_Gob }
+
+func (c gobCodec) Marshal(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c gobCodec) Unmarshal(data []byte, value interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(value)
+}
+
+func (c protobufCodec) Id() CodecId { return CodecId_Protobuf }
+
+func (c protobufCodec) Marshal(value interface{}) ([]byte, error) {
+	message, ok := value.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%w, got %T", ErrNotProtoMessage, value)
+	}
+	return proto.Marshal(message)
+}
+
+func (c protobufCodec) Unmarshal(data []byte, value interface{}) error {
+	message, ok := value.(proto.Message)
+	if !ok {
+		return fmt.Errorf("%w, got %T", ErrNotProtoMessage, value)
+	}
+	return proto.Unmarshal(data, message)
+}
+
+func (c *zstdCompressor) Id() CompressionId { return CompressionId_Zstd }
+
+func (c *zstdCompressor) Compress(data []byte) ([]byte, error) {
+	if err := c.init(); err != nil {
+		return nil, err
+	}
+	return c.encoder.EncodeAll(data, nil), nil
+}
+
+func (c *zstdCompressor) Decompress(data []byte) ([]byte, error) {
+	if err := c.init(); err != nil {
+		return nil, err
+	}
+	return c.decoder.DecodeAll(data, nil)
+}
+
+func (c *zstdCompressor) init() error {
+	c.once.Do(func() {
+		if c.encoder, c.err = zstd.NewWriter(nil); c.err != nil {
+			return
+		}
+		c.decoder, c.err = zstd.NewReader(nil)
+	})
+	return c.err
+}
+
+func (c snappyCompressor) Id() CompressionId { return CompressionId_Snappy }
+
+func (c snappyCompressor) Compress(data []byte) ([]byte, error) {
+	return snappy.Encode(nil, data), nil
+}
+
+func (c snappyCompressor) Decompress(data []byte) ([]byte, error) {
+	return snappy.Decode(nil, data)
+}
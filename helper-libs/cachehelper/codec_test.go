@@ -0,0 +1,88 @@
+package cachehelper
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type codecTestOrder struct {
+	Id        string    `json:"id"`
+	Note      string    `json:"note"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func TestCodecs(t *testing.T) {
+	redisClient, server := newTestRedisClientHelper(t)
+	ctx := context.Background()
+	createdAt := time.Date(2024, 5, 1, 10, 30, 0, 123456789, time.FixedZone("ICT", 7*3600))
+	order := codecTestOrder{Id: "o1", Note: strings.Repeat("note ", 500), CreatedAt: createdAt}
+
+	tests := []struct {
+		name       string
+		codec      Codec
+		compressor Compressor
+		header     []byte
+	}{
+		{name: "legacy json"},
+		{name: "json", codec: JSONCodec(), header: []byte{valueHeaderMagic, byte(CodecId_JSON), byte(CompressionId_None)}},
+		{name: "msgpack", codec: MsgpackCodec(), header: []byte{valueHeaderMagic, byte(CodecId_Msgpack), byte(CompressionId_None)}},
+		{name: "gob zstd", codec: GobCodec(), compressor: ZstdCompressor(), header: []byte{valueHeaderMagic, byte(CodecId_Gob), byte(CompressionId_Zstd)}},
+		{name: "snappy defaults to json", compressor: SnappyCompressor(), header: []byte{valueHeaderMagic, byte(CodecId_JSON), byte(CompressionId_Snappy)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewCacheHelper(&CacheConfigOptions{RedisClientHelper: redisClient, Codec: tt.codec, Compressor: tt.compressor})
+			if err := cache.Set(ctx, "order", order, time.Minute); err != nil {
+				t.Fatal(err)
+			}
+			stored, _ := server.Get("order")
+			if tt.header == nil && !strings.HasPrefix(stored, `{"id":"o1"`) {
+				t.Errorf("expected plain json, actual: %.20q", stored)
+			}
+			if tt.header != nil && !strings.HasPrefix(stored, string(tt.header)) {
+				t.Errorf("expected header %v, actual: %v", tt.header, []byte(stored[:valueHeaderSize]))
+			}
+
+			// Readers decode whatever codec wrote the value
+			reader := NewCacheHelper(&CacheConfigOptions{RedisClientHelper: redisClient, Codec: MsgpackCodec()})
+			actual := codecTestOrder{}
+			if err := reader.Get(ctx, "order", &actual); err != nil {
+				t.Fatal(err)
+			}
+			if actual.Note != order.Note || !actual.CreatedAt.Equal(createdAt) {
+				t.Errorf("expected %s, actual: %s", createdAt, actual.CreatedAt)
+			}
+			if value, err := reader.GetInterface(ctx, "order", codecTestOrder{}); err != nil || value.(codecTestOrder).Id != "o1" {
+				t.Errorf("expected order o1, actual: %v, %v", value, err)
+			}
+		})
+	}
+}
+
+func TestProtobufCodec(t *testing.T) {
+	redisClient, server := newTestRedisClientHelper(t)
+	ctx := context.Background()
+	cache := NewCacheHelper(&CacheConfigOptions{RedisClientHelper: redisClient, Codec: ProtobufCodec()})
+
+	if err := cache.Set(ctx, "order", codecTestOrder{}, time.Minute); !errors.Is(err, ErrNotProtoMessage) {
+		t.Errorf("expected %v, actual: %v", ErrNotProtoMessage, err)
+	}
+	expected := timestamppb.New(time.Unix(1714559400, 42))
+	if err := cache.Set(ctx, "timestamp", expected, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	actual := &timestamppb.Timestamp{}
+	if err := cache.Get(ctx, "timestamp", actual); err != nil || !actual.AsTime().Equal(expected.AsTime()) {
+		t.Errorf("expected %v, actual: %v, %v", expected, actual, err)
+	}
+
+	server.Set("unknown", string([]byte{valueHeaderMagic, 99, byte(CompressionId_None)}))
+	if err := cache.Get(ctx, "unknown", actual); !errors.Is(err, ErrUnknownCodec) {
+		t.Errorf("expected %v, actual: %v", ErrUnknownCodec, err)
+	}
+}
@@ -0,0 +1,428 @@
+package cachehelper
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// conformanceTarget is a CacheHelperEnhancement under test and the way to move its clock forward.
+type conformanceTarget struct {
+	helper      CacheHelperEnhancement
+	fastForward func(time.Duration)
+}
+
+func TestRedisCacheHelperConformance(t *testing.T) {
+	runCacheHelperConformance(t, func(t *testing.T) conformanceTarget {
+		redisClient, server := newTestRedisClientHelper(t)
+		return conformanceTarget{
+			helper:      NewCacheHelper(&CacheConfigOptions{RedisClientHelper: redisClient}).(CacheHelperEnhancement),
+			fastForward: server.FastForward,
+		}
+	})
+}
+
+func TestRedisClusterCacheHelperConformance(t *testing.T) {
+	runCacheHelperConformance(t, func(t *testing.T) conformanceTarget {
+		redisClient, servers := newTestRedisClusterHelper(t)
+		return conformanceTarget{
+			helper: NewCacheHelper(&CacheConfigOptions{RedisClientHelper: redisClient}).(CacheHelperEnhancement),
+			fastForward: func(d time.Duration) {
+				for _, server := range servers {
+					server.FastForward(d)
+				}
+			},
+		}
+	})
+}
+
+func TestMemoryCacheHelperConformance(t *testing.T) {
+	runCacheHelperConformance(t, func(t *testing.T) conformanceTarget {
+		var mu sync.Mutex
+		now := time.Now()
+		return conformanceTarget{
+			helper: NewMemoryCacheHelper(&MemoryCacheOptions{Now: func() time.Time {
+				mu.Lock()
+				defer mu.Unlock()
+				return now
+			}}),
+			fastForward: func(d time.Duration) {
+				mu.Lock()
+				defer mu.Unlock()
+				now = now.Add(d)
+			},
+		}
+	})
+}
+
+func runCacheHelperConformance(t *testing.T, newTarget func(t *testing.T) conformanceTarget) {
+	ctx := context.Background()
+
+	t.Run("strings", func(t *testing.T) {
+		cache := newTarget(t).helper
+		if err := cache.Get(ctx, "user:1", &loaderTestUser{}); !errors.Is(err, redis.Nil) {
+			t.Errorf("expected %v, actual: %v", redis.Nil, err)
+		}
+		if err := cache.Set(ctx, "user:1", loaderTestUser{Id: "1", Name: "Alice"}, 0); err != nil {
+			t.Fatal(err)
+		}
+		user := loaderTestUser{}
+		if err := cache.Get(ctx, "user:1", &user); err != nil || user.Name != "Alice" {
+			t.Errorf("expected Alice, actual: %+v, %v", user, err)
+		}
+		if value, err := cache.GetInterface(ctx, "user:1", loaderTestUser{}); err != nil || value.(loaderTestUser).Name != "Alice" {
+			t.Errorf("expected Alice, actual: %v, %v", value, err)
+		}
+		if isSet, err := cache.SetNX(ctx, "user:1", loaderTestUser{Name: "Bob"}, time.Minute); isSet || err != nil {
+			t.Errorf("expected an existing key not to be set, actual: %v, %v", isSet, err)
+		}
+		if isSet, err := cache.SetNX(ctx, "user:2", loaderTestUser{Name: "Bob"}, time.Minute); !isSet || err != nil {
+			t.Errorf("expected a missing key to be set, actual: %v, %v", isSet, err)
+		}
+		if values, err := cache.GetMulti(ctx, nil, "user:1", "user:3"); err != nil || len(values) != 2 || values[0] == nil || values[1] != nil {
+			t.Errorf("expected a value and a nil, actual: %v, %v", values, err)
+		}
+		if length, err := cache.GetStrLenght(ctx, "user:1"); err != nil || length != int64(len(`{"id":"1","name":"Alice"}`)) {
+			t.Errorf("expected the json length, actual: %d, %v", length, err)
+		}
+		if kind, _ := cache.GetType(ctx, "user:1"); kind != "string" {
+			t.Errorf("expected string, actual: %s", kind)
+		}
+		if err := cache.RenameKey(ctx, "user:1", "user:renamed"); err != nil {
+			t.Fatal(err)
+		}
+		if err := cache.RenameKey(ctx, "user:missing", "user:other"); err == nil {
+			t.Error("expected renaming a missing key to fail")
+		}
+		if err := cache.DelMulti(ctx, "user:renamed", "user:2"); err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range []string{"user:renamed", "user:2"} {
+			if err := cache.Exists(ctx, key); !errors.Is(err, redis.Nil) {
+				t.Errorf("expected %s to be deleted, actual: %v", key, err)
+			}
+		}
+	})
+
+	t.Run("ttl", func(t *testing.T) {
+		target := newTarget(t)
+		cache := target.helper
+		if ttl, _ := cache.TimeExpire(ctx, "session"); ttl != -2 {
+			t.Errorf("expected -2 for a missing key, actual: %v", ttl)
+		}
+		if err := cache.Set(ctx, "session", "s1", 0); err != nil {
+			t.Fatal(err)
+		}
+		if ttl, _ := cache.TimeExpire(ctx, "session"); ttl != -1 {
+			t.Errorf("expected -1 without expiry, actual: %v", ttl)
+		}
+		if err := cache.Expire(ctx, "session", 10*time.Second); err != nil {
+			t.Fatal(err)
+		}
+		if ttl, _ := cache.TimeExpire(ctx, "session"); ttl != 10*time.Second {
+			t.Errorf("expected 10s, actual: %v", ttl)
+		}
+		target.fastForward(11 * time.Second)
+		if err := cache.Exists(ctx, "session"); !errors.Is(err, redis.Nil) {
+			t.Errorf("expected the key to expire, actual: %v", err)
+		}
+	})
+
+	t.Run("hashes", func(t *testing.T) {
+		target := newTarget(t)
+		cache := target.helper
+		if isSet, err := cache.HSet(ctx, "merchant:1", "name", "Shop", 0); !isSet || err != nil {
+			t.Errorf("expected the field to be set, actual: %v, %v", isSet, err)
+		}
+		if isSet, err := cache.HSet(ctx, "merchant:1", "name", "Store", time.Minute); !isSet || err != nil {
+			t.Errorf("expected the field to be updated, actual: %v, %v", isSet, err)
+		}
+		if isSet, _ := cache.HSetNX(ctx, "merchant:1", "name", "Other", 0); isSet {
+			t.Error("expected an existing field not to be set")
+		}
+		if _, err := cache.HMSet(ctx, "merchant:1", map[string]interface{}{"limit": 10, "tags": []string{"a"}}, 0); err != nil {
+			t.Fatal(err)
+		}
+		if value, err := cache.HGet(ctx, "merchant:1", "name"); err != nil || value != "Store" {
+			t.Errorf("expected Store, actual: %q, %v", value, err)
+		}
+		if _, err := cache.HGet(ctx, "merchant:1", "missing"); !errors.Is(err, redis.Nil) {
+			t.Errorf("expected %v, actual: %v", redis.Nil, err)
+		}
+		if _, value, err := cache.HIncreaseBy(ctx, "merchant:1", "limit", 5); err != nil || value != "15" {
+			t.Errorf("expected 15, actual: %q, %v", value, err)
+		}
+		values, err := cache.HGetAll(ctx, "merchant:1", nil)
+		if err != nil || len(values) != 3 || values["tags"] != `["a"]` {
+			t.Errorf("expected 3 fields, actual: %v, %v", values, err)
+		}
+		if fields, err := cache.HMGet(ctx, "merchant:1", []string{"name", "missing"}); err != nil || fields["name"] != "Store" || fields["missing"] != nil {
+			t.Errorf("expected the name and a nil, actual: %v, %v", fields, err)
+		}
+		if kind, _ := cache.GetType(ctx, "merchant:1"); kind != "hash" {
+			t.Errorf("expected hash, actual: %s", kind)
+		}
+		if err := cache.Get(ctx, "merchant:1", &loaderTestUser{}); err == nil {
+			t.Error("expected reading a hash as a string to fail")
+		}
+		target.fastForward(2 * time.Minute)
+		if values, _ := cache.HGetAll(ctx, "merchant:1", nil); len(values) != 0 {
+			t.Errorf("expected the hash to expire, actual: %v", values)
+		}
+	})
+
+	t.Run("scan", func(t *testing.T) {
+		cache := newTarget(t).helper
+		for _, key := range []string{"order:1", "order:2", "order:3", "user:1", "order:10"} {
+			if err := cache.Set(ctx, key, 1, 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var (
+			keys   []string
+			cursor uint64
+		)
+		for {
+			page, next, err := cache.GetKeysByPattern(ctx, "order:?", cursor, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			keys = append(keys, page...)
+			if cursor = next; cursor == 0 {
+				break
+			}
+		}
+		sort.Strings(keys)
+		if len(keys) != 3 || keys[0] != "order:1" || keys[2] != "order:3" {
+			t.Errorf("expected order:1 to order:3, actual: %v", keys)
+		}
+	})
+
+	t.Run("pubsub", func(t *testing.T) {
+		cache := newTarget(t).helper
+		subscribeCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		received := make(chan CacheMessage, 1)
+		go cache.SubscribeMessage(subscribeCtx, "events", func(message CacheMessage) error {
+			received <- message
+			return nil
+		})
+		// Publishing fails until the subscription is registered
+		waitFor(t, func() bool { return cache.PublishMessage(ctx, "events", `{"event":"created"}`) == nil })
+		select {
+		case message := <-received:
+			if message.Channel != "events" || message.Payload != `{"event":"created"}` {
+				t.Errorf("expected the created event, actual: %+v", message)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected a message")
+		}
+	})
+
+	for _, name := range []string{"pipeline", "transaction"} {
+		t.Run(name, func(t *testing.T) {
+			cache := newTarget(t).helper
+			var builder CacheMutilCommandBuilder = cache.GetPipeline(ctx, "tx-1")
+			if name == "transaction" {
+				builder = cache.GetTransaction(ctx, "tx-1")
+			}
+			commands := []struct {
+				commandType CacheCommandType
+				data        []interface{}
+			}{
+				{CacheCommandTypeAddMemberWithScore, []interface{}{"ranking", "bob", float64(2)}},
+				{CacheCommandTypeAddMemberWithScore, []interface{}{"ranking", "alice", float64(1)}},
+				{CacheCommandTypeAddMemberWithScore, []interface{}{"ranking", "carol", float64(3)}},
+				{CacheCommandTypeRemoveMembersWithScore, []interface{}{"ranking", "(2", "+inf"}},
+				{CacheCommandTypeGetMembersWithScore, []interface{}{"ranking", "0", "-1"}},
+				{CacheCommandTypeIncrease, []interface{}{"counter"}},
+				{CacheCommandTypeIncrease, []interface{}{"counter"}},
+				{CacheCommandTypeSetNX, []interface{}{"lock", "owner", uint32(10), time.Second}},
+				{CacheCommandTypeGetInterface, []interface{}{"lock"}},
+				{CacheCommandTypeDel, []interface{}{"counter"}},
+			}
+			for _, command := range commands {
+				if err := builder.BuildCommand(ctx, command.commandType, command.data...); err != nil {
+					t.Fatal(err)
+				}
+			}
+			lazy, err := builder.GetCommands(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			results, err := lazy.Exec(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != len(commands) {
+				t.Fatalf("expected %d results, actual: %d", len(commands), len(results))
+			}
+			if removed := results[3].Result[0]; removed != int64(1) {
+				t.Errorf("expected 1 removed member, actual: %v", removed)
+			}
+			members := results[4].Result
+			if len(members) != 2 || members[0].(RedisZSliceResult).Member != "alice" || members[1].(RedisZSliceResult).Score != 2 {
+				t.Errorf("expected alice then bob, actual: %v", members)
+			}
+			if counter := results[6].Result[0]; counter != int64(2) {
+				t.Errorf("expected the counter at 2, actual: %v", counter)
+			}
+			if value := results[8].Result[0]; value != "owner" {
+				t.Errorf("expected owner, actual: %v", value)
+			}
+			if deleted := results[9].Result[0]; deleted != int64(1) {
+				t.Errorf("expected 1 deleted key, actual: %v", deleted)
+			}
+		})
+	}
+
+	t.Run("legacy builder arguments", func(t *testing.T) {
+		cache := newTarget(t).helper
+		if err := cache.Set(ctx, "lock", "owner", 0); err != nil {
+			t.Fatal(err)
+		}
+		builder := cache.GetPipeline(ctx, "tx-1")
+		if err := builder.BuildCommand(ctx, CacheCommandTypeGet, "lock"); err != nil {
+			t.Fatal(err)
+		}
+		badCommands := []struct {
+			commandType CacheCommandType
+			data        []interface{}
+		}{
+			{CacheCommandTypeGet, []interface{}{1}},
+			{CacheCommandTypeExpire, []interface{}{"lock", 10, time.Second}},
+			{CacheCommandTypeSetNX, []interface{}{"lock", "owner"}},
+			{CacheCommandTypeAddMemberWithScore, []interface{}{"ranking", "bob", 2}},
+		}
+		for _, command := range badCommands {
+			if err := builder.BuildCommand(ctx, command.commandType, command.data...); err == nil {
+				t.Errorf("expected an argument error for %v %v", command.commandType, command.data)
+			}
+		}
+		lazy, _ := builder.GetCommands(ctx)
+		results, err := lazy.Exec(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].Result[0] != `"owner"` {
+			t.Errorf("expected the encoded owner, actual: %v", results)
+		}
+	})
+
+	for _, name := range []string{"typed pipeline", "typed transaction"} {
+		t.Run(name, func(t *testing.T) {
+			cache := newTarget(t).helper
+			pipeline := cache.NewPipeline(ctx)
+			if name == "typed transaction" {
+				pipeline = cache.NewTransaction(ctx)
+			}
+			// The keys of the multi-key commands share a slot so they run in a cluster transaction
+			set := pipeline.Set(ctx, "{user}:1", loaderTestUser{Id: "1", Name: "Alice"}, time.Minute)
+			get := pipeline.Get(ctx, "{user}:1")
+			missing := pipeline.Get(ctx, "{user}:missing")
+			setNX := pipeline.SetNX(ctx, "{user}:1", "other", 0)
+			mget := pipeline.MGet(ctx, "{user}:1", "{user}:missing")
+			pipeline.Incr(ctx, "{user}:counter")
+			counter := pipeline.IncrBy(ctx, "{user}:counter", 4)
+			hset := pipeline.HSet(ctx, "merchant:1", "name", "Shop")
+			pipeline.HIncrBy(ctx, "merchant:1", "limit", 3)
+			hget := pipeline.HGet(ctx, "merchant:1", "name")
+			hgetAll := pipeline.HGetAll(ctx, "merchant:1")
+			hdel := pipeline.HDel(ctx, "merchant:1", "name", "missing")
+			zadd := pipeline.ZAdd(ctx, "ranking", redis.Z{Member: "bob", Score: 2}, redis.Z{Member: "alice", Score: 1}, redis.Z{Member: "carol", Score: 3})
+			byScore := pipeline.ZRangeByScore(ctx, "ranking", "(1", "+inf")
+			removed := pipeline.ZRemRangeByScore(ctx, "ranking", "3", "3")
+			ranking := pipeline.ZRangeWithScores(ctx, "ranking", 0, -1)
+			pipeline.RPush(ctx, "queue", "b", "c")
+			pushed := pipeline.LPush(ctx, "queue", "a")
+			lpop := pipeline.LPop(ctx, "queue")
+			rpop := pipeline.RPop(ctx, "queue")
+			lrange := pipeline.LRange(ctx, "queue", 0, -1)
+			llen := pipeline.LLen(ctx, "queue")
+			del := pipeline.Del(ctx, "{user}:counter", "{user}:missing")
+			expire := pipeline.Expire(ctx, "queue", time.Minute)
+
+			if err := get.Err(); !errors.Is(err, ErrPipelineNotExecuted) {
+				t.Errorf("expected %v before Exec, actual: %v", ErrPipelineNotExecuted, err)
+			}
+			if err := pipeline.Exec(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			if !set.Val() || setNX.Val() {
+				t.Errorf("expected set and not setNX, actual: %v, %v", set.Val(), setNX.Val())
+			}
+			if user, err := DecodeFuture[loaderTestUser](get); err != nil || user.Name != "Alice" {
+				t.Errorf("expected Alice, actual: %+v, %v", user, err)
+			}
+			if err := missing.Err(); !errors.Is(err, redis.Nil) {
+				t.Errorf("expected %v, actual: %v", redis.Nil, err)
+			}
+			if values := mget.Val(); len(values) != 2 || values[0] == nil || values[1] != nil {
+				t.Errorf("expected a value and a nil, actual: %v", values)
+			}
+			if counter.Val() != 5 || hset.Val() != 1 || hget.Val() != "Shop" || hdel.Val() != 1 {
+				t.Errorf("expected 5, 1, Shop, 1, actual: %d, %d, %q, %d", counter.Val(), hset.Val(), hget.Val(), hdel.Val())
+			}
+			if fields := hgetAll.Val(); len(fields) != 2 || fields["limit"] != "3" {
+				t.Errorf("expected name and limit, actual: %v", fields)
+			}
+			if members := byScore.Val(); zadd.Val() != 3 || len(members) != 2 || members[0] != "bob" {
+				t.Errorf("expected bob and carol, actual: %v", members)
+			}
+			if members := ranking.Val(); removed.Val() != 1 || len(members) != 2 || members[0].Member != "alice" || members[1].Score != 2 {
+				t.Errorf("expected alice then bob, actual: %v", members)
+			}
+			if pushed.Val() != 3 || lpop.Val() != "a" || rpop.Val() != "c" || llen.Val() != 1 {
+				t.Errorf("expected 3, a, c, 1, actual: %d, %q, %q, %d", pushed.Val(), lpop.Val(), rpop.Val(), llen.Val())
+			}
+			if values := lrange.Val(); len(values) != 1 || values[0] != "b" {
+				t.Errorf("expected b, actual: %v", values)
+			}
+			if del.Val() != 1 || !expire.Val() {
+				t.Errorf("expected 1 deleted key and the expiry set, actual: %d, %v", del.Val(), expire.Val())
+			}
+			if kind, _ := cache.GetType(ctx, "queue"); kind != "list" {
+				t.Errorf("expected list, actual: %s", kind)
+			}
+		})
+	}
+
+	t.Run("typed pipeline arguments", func(t *testing.T) {
+		cache := newTarget(t).helper
+		pipeline := cache.NewPipeline(ctx)
+		badSet := pipeline.Set(ctx, "bad", func() {}, 0)
+		noKeys := pipeline.Del(ctx)
+		badScore := pipeline.ZRangeByScore(ctx, "ranking", "low", "+inf")
+		noValues := pipeline.LPush(ctx, "queue")
+		incr := pipeline.Incr(ctx, "counter")
+		for _, err := range []error{badSet.Err(), noKeys.Err(), noValues.Err()} {
+			if err == nil {
+				t.Error("expected the bad command to fail before Exec")
+			}
+		}
+		if err := pipeline.Exec(ctx); err == nil {
+			t.Error("expected Exec to report the argument error")
+		}
+		if err := badScore.Err(); err == nil {
+			t.Error("expected a bad score to fail")
+		}
+		if incr.Val() != 1 {
+			t.Errorf("expected the other commands to run, actual: %v", incr.Val())
+		}
+
+		pipeline.Set(ctx, "discarded", "value", 0)
+		pipeline.Discard()
+		if err := pipeline.Exec(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if err := cache.Exists(ctx, "discarded"); !errors.Is(err, redis.Nil) {
+			t.Errorf("expected the discarded command not to run, actual: %v", err)
+		}
+	})
+}
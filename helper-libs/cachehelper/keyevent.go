@@ -0,0 +1,316 @@
+package cachehelper
+
+import (
+	"context"
+	"fmt"
+	"go-clean-arch/helper-libs/loghelper"
+	"go-clean-arch/helper-libs/redisclienthelper"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const (
+	KeyEventOp_Set        KeyEventOp = "set"
+	KeyEventOp_Del        KeyEventOp = "del"
+	KeyEventOp_Expire     KeyEventOp = "expire"
+	KeyEventOp_Expired    KeyEventOp = "expired"
+	KeyEventOp_Evicted    KeyEventOp = "evicted"
+	KeyEventOp_RenameFrom KeyEventOp = "rename_from"
+	KeyEventOp_RenameTo   KeyEventOp = "rename_to"
+
+	keyspacePrefix     = "__keyspace@"
+	keyeventPrefix     = "__keyevent@"
+	notifyKeyspaceKey  = "notify-keyspace-events"
+	notifyKeyspaceFlag = "K"
+
+	defaultTopologyCheckInterval = 30 * time.Second
+)
+
+// keyEventClasses are the notify-keyspace-events classes publishing each operation.
+var keyEventClasses = map[KeyEventOp]string{
+	KeyEventOp_Set:        "$",
+	KeyEventOp_Del:        "g",
+	KeyEventOp_Expire:     "g",
+	KeyEventOp_Expired:    "x",
+	KeyEventOp_Evicted:    "e",
+	KeyEventOp_RenameFrom: "g",
+	KeyEventOp_RenameTo:   "g",
+}
+
+type (
+	KeyEventOp string
+
+	// KeyEvent is a keyspace notification of Redis.
+	KeyEvent struct {
+		Key string
+		Op  KeyEventOp
+		DB  int
+	}
+
+	KeyEventFunc func(ctx context.Context, event KeyEvent) error
+
+	KeyEventOptions struct {
+		RedisClientHelper *redisclienthelper.RedisClientHelper
+		// ConfigureNotifications adds the classes of the subscribed operations to notify-keyspace-events with
+		// CONFIG SET. Leave it off for managed Redis forbidding CONFIG, the notifications must then be enabled
+		// by the provider.
+		ConfigureNotifications bool
+		// TopologyCheckInterval is how often the masters are re-read to subscribe the new ones and resubscribe
+		// the ended subscriptions, 30s by default.
+		TopologyCheckInterval time.Duration
+	}
+
+	// KeyEventHelper subscribes to the keyspace notifications of every master. Notifications are pub/sub
+	// messages: events happening while no subscriber runs are lost, and expired events are only sent once Redis
+	// expires the key, which can lag behind its TTL. Every replica of the app subscribing a pattern receives
+	// each event, so handlers must dedupe, e.g. run an expired timer under redislockhelper WithLock on its key.
+	KeyEventHelper interface {
+		// SubscribeKeyEvents calls eventFunc for the ops of the keys matching pattern, all ops when empty.
+		// It blocks until ctx is done.
+		SubscribeKeyEvents(ctx context.Context, pattern string, ops []KeyEventOp, eventFunc KeyEventFunc) error
+		// StartTimer sets key to expire after duration, subscribe to KeyEventOp_Expired on its pattern to be
+		// notified, e.g. `timer:transaction:*` for the timeouts of pending transactions.
+		StartTimer(ctx context.Context, key string, duration time.Duration) error
+		// StopTimer deletes key so it never expires.
+		StopTimer(ctx context.Context, key string) error
+	}
+
+	keyEventHelper struct {
+		client        *redis.Client
+		clusterClient *redis.ClusterClient
+		configure     bool
+		checkInterval time.Duration
+	}
+
+	// keyEventSubscription is the subscription of a master, identified by its address.
+	keyEventSubscription struct {
+		addr   string
+		cancel context.CancelFunc
+	}
+)
+
+func NewKeyEventHelper(opts *KeyEventOptions) KeyEventHelper {
+	if opts.RedisClientHelper == nil || (opts.RedisClientHelper.ClusterClient == nil && opts.RedisClientHelper.Client == nil) {
+		loghelper.Logger.Panic("redis client must specific")
+	}
+	checkInterval := opts.TopologyCheckInterval
+	if checkInterval <= 0 {
+		checkInterval = defaultTopologyCheckInterval
+	}
+	return &keyEventHelper{
+		client:        opts.RedisClientHelper.Client,
+		clusterClient: opts.RedisClientHelper.ClusterClient,
+		configure:     opts.ConfigureNotifications,
+		checkInterval: checkInterval,
+	}
+}
+
+func (h *keyEventHelper) SubscribeKeyEvents(ctx context.Context, pattern string, ops []KeyEventOp, eventFunc KeyEventFunc) error {
+	for _, op := range ops {
+		if _, exists := keyEventClasses[op]; !exists {
+			return fmt.Errorf("unsupported key event op: %s", op)
+		}
+	}
+	nodes, err := h.nodes(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Notifications are local to each node so every master is subscribed
+	var (
+		wg            sync.WaitGroup
+		subscriptions = map[string]*keyEventSubscription{}
+		ended         = make(chan *keyEventSubscription)
+	)
+	defer func() {
+		for _, subscription := range subscriptions {
+			subscription.cancel()
+		}
+		wg.Wait()
+	}()
+	subscribe := func(nodes []*redis.Client) error {
+		masters := map[string]bool{}
+		for _, node := range nodes {
+			addr := node.Options().Addr
+			masters[addr] = true
+			if _, exists := subscriptions[addr]; exists {
+				continue
+			}
+			if h.configure {
+				if err := enableKeyEvents(ctx, node, ops); err != nil {
+					return err
+				}
+			}
+			nodeCtx, cancel := context.WithCancel(ctx)
+			subscription := &keyEventSubscription{addr: addr, cancel: cancel}
+			subscriptions[addr] = subscription
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				subscribeKeyEvents(nodeCtx, node, pattern, ops, eventFunc)
+				select {
+				case ended <- subscription:
+				case <-nodeCtx.Done():
+				}
+			}()
+		}
+		// Nodes that aren't masters anymore, e.g. failed over, are unsubscribed
+		for addr, subscription := range subscriptions {
+			if !masters[addr] {
+				subscription.cancel()
+				delete(subscriptions, addr)
+			}
+		}
+		return nil
+	}
+	if err := subscribe(nodes); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(h.checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case subscription := <-ended:
+			// The next check resubscribes the node
+			if subscriptions[subscription.addr] == subscription {
+				subscription.cancel()
+				delete(subscriptions, subscription.addr)
+			}
+		case <-ticker.C:
+			nodes, err := h.nodes(ctx)
+			if err == nil {
+				err = subscribe(nodes)
+			}
+			if err != nil {
+				loghelper.Logger.Warnw("failed to check the masters of key events", zap.Error(err))
+			}
+		}
+	}
+}
+
+func (h *keyEventHelper) StartTimer(ctx context.Context, key string, duration time.Duration) error {
+	if h.clusterClient != nil {
+		return h.clusterClient.Set(ctx, key, "", duration).Err()
+	}
+	return h.client.Set(ctx, key, "", duration).Err()
+}
+
+func (h *keyEventHelper) StopTimer(ctx context.Context, key string) error {
+	if h.clusterClient != nil {
+		return h.clusterClient.Del(ctx, key).Err()
+	}
+	return h.client.Del(ctx, key).Err()
+}
+
+// nodes returns the masters of the cluster, reloading its state, or the single client.
+func (h *keyEventHelper) nodes(ctx context.Context) ([]*redis.Client, error) {
+	if h.clusterClient == nil {
+		return []*redis.Client{h.client}, nil
+	}
+	var (
+		mu    sync.Mutex
+		nodes []*redis.Client
+	)
+	err := h.clusterClient.ForEachMaster(ctx, func(ctx context.Context, master *redis.Client) error {
+		mu.Lock()
+		defer mu.Unlock()
+		nodes = append(nodes, master)
+		return nil
+	})
+	return nodes, err
+}
+
+func subscribeKeyEvents(ctx context.Context, node *redis.Client, pattern string, ops []KeyEventOp, eventFunc KeyEventFunc) {
+	channel := fmt.Sprintf("%s%d__:%s", keyspacePrefix, node.Options().DB, pattern)
+	subscribes := node.PSubscribe(ctx, channel)
+	defer subscribes.Close()
+	messageChan := subscribes.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message, ok := <-messageChan:
+			if !ok {
+				return
+			}
+			event, ok := ParseKeyEvent(message.Channel, message.Payload)
+			if !ok || !matchKeyEventOp(ops, event.Op) {
+				continue
+			}
+			go func() {
+				if err := eventFunc(ctx, event); err != nil {
+					loghelper.Logger.Warnw("failed to handle key event", zap.String("key", event.Key), zap.String("op", string(event.Op)), zap.Error(err))
+				}
+			}()
+		}
+	}
+}
+
+// enableKeyEvents adds the classes of ops to the notify-keyspace-events of node, keeping the enabled ones.
+func enableKeyEvents(ctx context.Context, node *redis.Client, ops []KeyEventOp) error {
+	config, err := node.ConfigGet(ctx, notifyKeyspaceKey).Result()
+	if err != nil {
+		return err
+	}
+	current := config[notifyKeyspaceKey]
+	flags := notifyKeyspaceFlag
+	if len(ops) == 0 {
+		// A enables every class but the key miss and new key ones
+		flags += "A"
+	}
+	for _, op := range ops {
+		flags += keyEventClasses[op]
+	}
+	missing := ""
+	for _, flag := range flags {
+		if !strings.ContainsRune(current+missing, flag) {
+			missing += string(flag)
+		}
+	}
+	if missing == "" {
+		return nil
+	}
+	return node.ConfigSet(ctx, notifyKeyspaceKey, current+missing).Err()
+}
+
+// ParseKeyEvent parses a message of a `__keyspace@<db>__:<key>` or `__keyevent@<db>__:<op>` channel.
+func ParseKeyEvent(channel, payload string) (KeyEvent, bool) {
+	prefix := keyspacePrefix
+	if strings.HasPrefix(channel, keyeventPrefix) {
+		prefix = keyeventPrefix
+	} else if !strings.HasPrefix(channel, keyspacePrefix) {
+		return KeyEvent{}, false
+	}
+	db, name, found := strings.Cut(strings.TrimPrefix(channel, prefix), "__:")
+	if !found {
+		return KeyEvent{}, false
+	}
+	dbIndex, err := strconv.Atoi(db)
+	if err != nil {
+		return KeyEvent{}, false
+	}
+	if prefix == keyeventPrefix {
+		return KeyEvent{Key: payload, Op: KeyEventOp(name), DB: dbIndex}, true
+	}
+	return KeyEvent{Key: name, Op: KeyEventOp(payload), DB: dbIndex}, true
+}
+
+func matchKeyEventOp(ops []KeyEventOp, op KeyEventOp) bool {
+	if len(ops) == 0 {
+		return true
+	}
+	for _, expected := range ops {
+		if expected == op {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package cachehelper
+
+import (
+	"context"
+	"go-clean-arch/helper-libs/redisclienthelper"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestParseKeyEvent(t *testing.T) {
+	tests := []struct {
+		channel  string
+		payload  string
+		expected KeyEvent
+		ok       bool
+	}{
+		{channel: "__keyspace@0__:order:1", payload: "expired", expected: KeyEvent{Key: "order:1", Op: KeyEventOp_Expired}, ok: true},
+		{channel: "__keyevent@3__:del", payload: "order:__:1", expected: KeyEvent{Key: "order:__:1", Op: KeyEventOp_Del, DB: 3}, ok: true},
+		{channel: "__keyspace@0__:a__:b", payload: "set", expected: KeyEvent{Key: "a__:b", Op: KeyEventOp_Set}, ok: true},
+		{channel: "__keyspace@x__:order:1", payload: "set"},
+		{channel: "events", payload: "set"},
+	}
+	for _, tt := range tests {
+		event, ok := ParseKeyEvent(tt.channel, tt.payload)
+		if ok != tt.ok || event != tt.expected {
+			t.Errorf("expected %+v %v for %s, actual: %+v %v", tt.expected, tt.ok, tt.channel, event, ok)
+		}
+	}
+}
+
+func TestSubscribeKeyEvents(t *testing.T) {
+	redisClient, server := newTestRedisClientHelper(t)
+	clusterClient, clusterServers := newTestRedisClusterHelper(t)
+	tests := []struct {
+		name    string
+		helper  KeyEventHelper
+		servers []*miniredis.Miniredis
+	}{
+		{name: "single node", helper: NewKeyEventHelper(&KeyEventOptions{RedisClientHelper: redisClient}), servers: []*miniredis.Miniredis{server}},
+		{name: "cluster", helper: NewKeyEventHelper(&KeyEventOptions{RedisClientHelper: clusterClient}), servers: clusterServers},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			events := make(chan KeyEvent, 10)
+			go tt.helper.SubscribeKeyEvents(ctx, "timer:transaction:*", []KeyEventOp{KeyEventOp_Expired}, func(ctx context.Context, event KeyEvent) error {
+				events <- event
+				return nil
+			})
+			for _, server := range tt.servers {
+				waitFor(t, func() bool { return server.PubSubNumPat() == 1 })
+			}
+
+			// miniredis doesn't send notifications, they are published the way Redis does
+			for i, server := range tt.servers {
+				channel := "__keyspace@0__:timer:transaction:" + strconv.Itoa(i)
+				server.Publish(channel, "set")
+				server.Publish(channel, "expired")
+			}
+			received := map[string]bool{}
+			for range tt.servers {
+				select {
+				case event := <-events:
+					if event.Op != KeyEventOp_Expired {
+						t.Errorf("expected only expired events, actual: %+v", event)
+					}
+					received[event.Key] = true
+				case <-time.After(time.Second):
+					t.Fatal("expected an expired event from every master")
+				}
+			}
+			if len(received) != len(tt.servers) {
+				t.Errorf("expected an event per master, actual: %v", received)
+			}
+		})
+	}
+}
+
+func TestSubscribeKeyEventsFollowsMasters(t *testing.T) {
+	servers := []*miniredis.Miniredis{miniredis.RunT(t), miniredis.RunT(t)}
+	var master atomic.Int64
+	client := redis.NewClusterClient(&redis.ClusterOptions{
+		ClusterSlots: func(ctx context.Context) ([]redis.ClusterSlot, error) {
+			addr := servers[master.Load()].Addr()
+			return []redis.ClusterSlot{{Start: 0, End: ClusterSlots - 1, Nodes: []redis.ClusterNode{{Addr: addr}}}}, nil
+		},
+	})
+	t.Cleanup(func() { client.Close() })
+	helper := NewKeyEventHelper(&KeyEventOptions{
+		RedisClientHelper:     &redisclienthelper.RedisClientHelper{ClusterClient: client},
+		TopologyCheckInterval: 10 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- helper.SubscribeKeyEvents(ctx, "timer:*", []KeyEventOp{KeyEventOp_Expired}, func(ctx context.Context, event KeyEvent) error {
+			return nil
+		})
+	}()
+	waitFor(t, func() bool { return servers[0].PubSubNumPat() == 1 })
+
+	// A failover moves the slots to the other server
+	master.Store(1)
+	waitFor(t, func() bool { return servers[1].PubSubNumPat() == 1 && servers[0].PubSubNumPat() == 0 })
+	select {
+	case err := <-done:
+		t.Fatalf("expected the subscription to keep running, actual: %v", err)
+	default:
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the subscription to stop once ctx is done")
+	}
+}
+
+func TestKeyEventTimers(t *testing.T) {
+	redisClient, server := newTestRedisClientHelper(t)
+	helper := NewKeyEventHelper(&KeyEventOptions{RedisClientHelper: redisClient})
+	ctx := context.Background()
+
+	if err := helper.SubscribeKeyEvents(ctx, "*", []KeyEventOp{"unknown"}, nil); err == nil {
+		t.Error("expected an unsupported op to fail")
+	}
+	if err := helper.StartTimer(ctx, "timer:transaction:1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := server.TTL("timer:transaction:1"); ttl != time.Minute {
+		t.Errorf("expected the timer to expire in 1m, actual: %v", ttl)
+	}
+	if err := helper.StopTimer(ctx, "timer:transaction:1"); err != nil {
+		t.Fatal(err)
+	}
+	if server.Exists("timer:transaction:1") {
+		t.Error("expected the stopped timer to be deleted")
+	}
+}
@@ -0,0 +1,337 @@
+package cachehelper
+
+import (
+	"context"
+	"errors"
+	"go-clean-arch/helper-libs/loghelper"
+	"go-clean-arch/helper-libs/redislockhelper"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/bsm/redislock"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	defaultLoaderKeyPrefix      = "cache:"
+	defaultLoaderLockTimeout    = 10 * time.Second
+	defaultLoaderLockWait       = 3 * time.Second
+	defaultLoaderTagTTL         = 24 * time.Hour
+	defaultLoaderEarlyBeta      = 1.0
+	defaultWriteBehindQueueSize = 1024
+	loaderLockRetryInterval     = 20 * time.Millisecond
+)
+
+var (
+	// ErrNotFound is returned by the loads of missing values, and by negative cache hits unless LoaderOptions.NotFoundErr is set.
+	ErrNotFound     = errors.New("cache: not found")
+	ErrLoaderClosed = errors.New("cache: loader is closed")
+)
+
+type (
+	LoadFunc[T any] func(ctx context.Context) (T, error)
+
+	WriteFunc[T any] func(ctx context.Context, key string, value T) error
+
+	LoaderOptions[T any] struct {
+		CacheHelper CacheHelper
+		// LockHelper deduplicates the loads of every replica, without it loads are only deduplicated within the process.
+		LockHelper *redislockhelper.RedisLockHelper
+		// KeyPrefix falls back to `cache:`.
+		KeyPrefix string
+		// EarlyExpirationBeta tunes the probabilistic early expiration (XFetch), values above 1 refresh earlier.
+		// It falls back to 1, a negative value disables early expiration.
+		EarlyExpirationBeta float64
+		// NegativeTTL caches the loads failing with NotFoundErr, zero disables negative caching.
+		NegativeTTL time.Duration
+		// NotFoundErr is the error of missing values, it falls back to ErrNotFound.
+		NotFoundErr error
+		// TagTTL keeps the key lists of tags at least that long, it falls back to 24h.
+		TagTTL      time.Duration
+		LockTimeout time.Duration
+		LockWait    time.Duration
+		// WriteBehind persists the values of Put asynchronously, after the cache is updated.
+		WriteBehind          WriteFunc[T]
+		WriteBehindQueueSize int
+	}
+
+	// Loader is a read-through cache of T, loads of a key are deduplicated and expiring values are refreshed early
+	// by a single caller, so an expiry doesn't send every caller to the source at once.
+	Loader[T any] interface {
+		// GetOrLoad returns the cached value of key, loadFn is called on miss and its result cached for ttl under tags.
+		GetOrLoad(ctx context.Context, key string, ttl time.Duration, loadFn LoadFunc[T], tags ...string) (T, error)
+		// Put caches value and hands it to the write behind function if any.
+		Put(ctx context.Context, key string, value T, ttl time.Duration, tags ...string) error
+		Invalidate(ctx context.Context, keys ...string) error
+		// InvalidateTags removes every key cached under tags.
+		InvalidateTags(ctx context.Context, tags ...string) error
+		// Close flushes the pending writes behind.
+		Close(ctx context.Context) error
+	}
+
+	loaderEntry[T any] struct {
+		Value    T    `json:"value"`
+		NotFound bool `json:"notFound,omitempty"`
+		// Delta is the load duration in milliseconds, expensive loads are refreshed earlier
+		Delta int64 `json:"delta"`
+		// Expiry in unix milliseconds
+		Expiry int64 `json:"expiry"`
+	}
+
+	writeBehindItem[T any] struct {
+		key   string
+		value T
+	}
+
+	loader[T any] struct {
+		opts   LoaderOptions[T]
+		group  singleflight.Group
+		mu     sync.RWMutex
+		closed bool
+		queue  chan writeBehindItem[T]
+		done   chan struct{}
+	}
+)
+
+func NewLoader[T any](opts *LoaderOptions[T]) Loader[T] {
+	if opts.CacheHelper == nil {
+		loghelper.Logger.Panic("cache helper must specific")
+	}
+	l := &loader[T]{
+		opts: *opts,
+	}
+	if l.opts.KeyPrefix == "" {
+		l.opts.KeyPrefix = defaultLoaderKeyPrefix
+	}
+	if l.opts.EarlyExpirationBeta == 0 {
+		l.opts.EarlyExpirationBeta = defaultLoaderEarlyBeta
+	}
+	if l.opts.NotFoundErr == nil {
+		l.opts.NotFoundErr = ErrNotFound
+	}
+	if l.opts.TagTTL <= 0 {
+		l.opts.TagTTL = defaultLoaderTagTTL
+	}
+	if l.opts.LockTimeout <= 0 {
+		l.opts.LockTimeout = defaultLoaderLockTimeout
+	}
+	if l.opts.LockWait <= 0 {
+		l.opts.LockWait = defaultLoaderLockWait
+	}
+	if l.opts.WriteBehind != nil {
+		if l.opts.WriteBehindQueueSize <= 0 {
+			l.opts.WriteBehindQueueSize = defaultWriteBehindQueueSize
+		}
+		l.queue = make(chan writeBehindItem[T], l.opts.WriteBehindQueueSize)
+		l.done = make(chan struct{})
+		go l.writeBehind()
+	}
+	return l
+}
+
+func (l *loader[T]) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loadFn LoadFunc[T], tags ...string) (T, error) {
+	entry, err := l.get(ctx, key)
+	if err != nil {
+		loghelper.Logger.WithContext(ctx).Warnw("read cache failed, load from the source", zap.String("key", key), zap.Error(err))
+	}
+	if entry != nil && !l.expiresEarly(entry) {
+		return l.result(entry)
+	}
+
+	// The shared load outlives the caller that started it since concurrent callers wait for its result, each
+	// caller stops waiting once its own ctx is done
+	results := l.group.DoChan(key, func() (interface{}, error) {
+		return l.load(context.WithoutCancel(ctx), key, ttl, loadFn, entry, tags)
+	})
+	var zero T
+	select {
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	case result := <-results:
+		if result.Err != nil {
+			return zero, result.Err
+		}
+		return l.result(result.Val.(*loaderEntry[T]))
+	}
+}
+
+func (l *loader[T]) Put(ctx context.Context, key string, value T, ttl time.Duration, tags ...string) error {
+	if err := l.set(ctx, key, &loaderEntry[T]{Value: value, Expiry: time.Now().Add(ttl).UnixMilli()}, ttl, tags); err != nil {
+		return err
+	}
+	if l.queue == nil {
+		return nil
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.closed {
+		return ErrLoaderClosed
+	}
+	select {
+	case l.queue <- writeBehindItem[T]{key: key, value: value}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *loader[T]) Invalidate(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	cacheKeys := make([]string, len(keys))
+	for i, key := range keys {
+		cacheKeys[i] = l.opts.KeyPrefix + key
+	}
+	return l.opts.CacheHelper.DelMulti(ctx, cacheKeys...)
+}
+
+func (l *loader[T]) InvalidateTags(ctx context.Context, tags ...string) error {
+	for _, tag := range tags {
+		members, err := l.opts.CacheHelper.HGetAll(ctx, l.tagKey(tag), nil)
+		if err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(members)+1)
+		for key := range members {
+			keys = append(keys, key)
+		}
+		keys = append(keys, l.tagKey(tag))
+		if err = l.opts.CacheHelper.DelMulti(ctx, keys...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *loader[T]) Close(ctx context.Context) error {
+	if l.queue == nil {
+		return nil
+	}
+	l.mu.Lock()
+	if !l.closed {
+		l.closed = true
+		close(l.queue)
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-l.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// load runs once per key and process, the distributed lock makes it once per key across replicas.
+func (l *loader[T]) load(ctx context.Context, key string, ttl time.Duration, loadFn LoadFunc[T], stale *loaderEntry[T], tags []string) (*loaderEntry[T], error) {
+	if l.opts.LockHelper != nil {
+		// A stale value is served while another caller refreshes it, waiting is only worth it on miss
+		wait := l.opts.LockWait
+		if stale != nil {
+			wait = 0
+		}
+		lock, err := l.opts.LockHelper.ObtainWithWait(ctx, l.opts.KeyPrefix+"lock:"+key, l.opts.LockTimeout, wait, loaderLockRetryInterval)
+		switch {
+		case err == nil:
+			defer func() {
+				if err := lock.Release(ctx); err != nil && !errors.Is(err, redislock.ErrLockNotHeld) {
+					loghelper.Logger.WithContext(ctx).Warnw("release cache lock failed", zap.String("key", key), zap.Error(err))
+				}
+			}()
+			// Another replica may have loaded the value while this one was waiting for the lock
+			if fresh, _ := l.get(ctx, key); fresh != nil && (stale == nil || fresh.Expiry != stale.Expiry) {
+				return fresh, nil
+			}
+		case errors.Is(err, redislock.ErrNotObtained):
+			if stale != nil {
+				return stale, nil
+			}
+			if fresh, _ := l.get(ctx, key); fresh != nil {
+				return fresh, nil
+			}
+		default:
+			loghelper.Logger.WithContext(ctx).Warnw("obtain cache lock failed, load without lock", zap.String("key", key), zap.Error(err))
+		}
+	}
+
+	start := time.Now()
+	value, err := loadFn(ctx)
+	entry := &loaderEntry[T]{Delta: time.Since(start).Milliseconds()}
+	switch {
+	case err == nil:
+		entry.Value = value
+	case l.opts.NegativeTTL > 0 && errors.Is(err, l.opts.NotFoundErr):
+		entry.NotFound = true
+		ttl = l.opts.NegativeTTL
+	default:
+		return nil, err
+	}
+	entry.Expiry = time.Now().Add(ttl).UnixMilli()
+
+	if err = l.set(ctx, key, entry, ttl, tags); err != nil {
+		loghelper.Logger.WithContext(ctx).Warnw("write cache failed", zap.String("key", key), zap.Error(err))
+	}
+	return entry, nil
+}
+
+func (l *loader[T]) get(ctx context.Context, key string) (*loaderEntry[T], error) {
+	entry := &loaderEntry[T]{}
+	err := l.opts.CacheHelper.Get(ctx, l.opts.KeyPrefix+key, entry)
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (l *loader[T]) set(ctx context.Context, key string, entry *loaderEntry[T], ttl time.Duration, tags []string) error {
+	cacheKey := l.opts.KeyPrefix + key
+	if err := l.opts.CacheHelper.Set(ctx, cacheKey, entry, ttl); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := l.opts.CacheHelper.HSet(ctx, l.tagKey(tag), cacheKey, 1, max(ttl, l.opts.TagTTL)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expiresEarly draws whether this caller refreshes the entry before its expiry, see "Optimal Probabilistic Cache
+// Stampede Prevention" (Vattani et al.). The closer to expiry and the longer the load, the likelier the refresh.
+func (l *loader[T]) expiresEarly(entry *loaderEntry[T]) bool {
+	if l.opts.EarlyExpirationBeta < 0 || entry.NotFound {
+		return false
+	}
+	gap := float64(entry.Delta) * l.opts.EarlyExpirationBeta * -math.Log(1-rand.Float64())
+	return float64(time.Now().UnixMilli())+gap >= float64(entry.Expiry)
+}
+
+func (l *loader[T]) result(entry *loaderEntry[T]) (T, error) {
+	if entry.NotFound {
+		var zero T
+		return zero, l.opts.NotFoundErr
+	}
+	return entry.Value, nil
+}
+
+func (l *loader[T]) tagKey(tag string) string {
+	return l.opts.KeyPrefix + "tag:" + tag
+}
+
+func (l *loader[T]) writeBehind() {
+	defer close(l.done)
+	for item := range l.queue {
+		if err := l.opts.WriteBehind(context.Background(), item.key, item.value); err != nil {
+			loghelper.Logger.Errorw("write behind failed", zap.String("key", item.key), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package cachehelper
+
+import (
+	"context"
+	"errors"
+	"go-clean-arch/helper-libs/loghelper"
+	"go-clean-arch/helper-libs/redisclienthelper"
+	"go-clean-arch/helper-libs/redislockhelper"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+type loaderTestUser struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func newTestRedisClientHelper(t *testing.T) (*redisclienthelper.RedisClientHelper, *miniredis.Miniredis) {
+	t.Helper()
+	if err := loghelper.InitZap("test", "dev", nil); err != nil {
+		t.Fatal(err)
+	}
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return &redisclienthelper.RedisClientHelper{Client: client}, server
+}
+
+func TestLoaderDeduplicatesLoads(t *testing.T) {
+	redisClient, _ := newTestRedisClientHelper(t)
+	cache := NewCacheHelper(&CacheConfigOptions{RedisClientHelper: redisClient})
+	locker := redislockhelper.NewRedisLockerHelper(&redislockhelper.RedisLockerOptions{RedisClientHelper: redisClient})
+	// Two loaders sharing redis stand for two replicas
+	replicas := []Loader[loaderTestUser]{
+		NewLoader(&LoaderOptions[loaderTestUser]{CacheHelper: cache, LockHelper: locker}),
+		NewLoader(&LoaderOptions[loaderTestUser]{CacheHelper: cache, LockHelper: locker}),
+	}
+
+	var loads atomic.Int32
+	loadFn := func(ctx context.Context) (loaderTestUser, error) {
+		loads.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		return loaderTestUser{Id: "u1", Name: "Alice"}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(replica Loader[loaderTestUser]) {
+			defer wg.Done()
+			user, err := replica.GetOrLoad(context.Background(), "user:u1", time.Minute, loadFn)
+			if err != nil || user.Name != "Alice" {
+				t.Errorf("expected Alice, actual: %+v, %v", user, err)
+			}
+		}(replicas[i%2])
+	}
+	wg.Wait()
+	if loads.Load() != 1 {
+		t.Errorf("expected a single load, actual: %d", loads.Load())
+	}
+}
+
+func TestLoaderCallersStopWaitingOnTheirContext(t *testing.T) {
+	redisClient, _ := newTestRedisClientHelper(t)
+	loader := NewLoader(&LoaderOptions[loaderTestUser]{
+		CacheHelper: NewCacheHelper(&CacheConfigOptions{RedisClientHelper: redisClient}),
+	})
+
+	release := make(chan struct{})
+	var loads atomic.Int32
+	loadFn := func(ctx context.Context) (loaderTestUser, error) {
+		loads.Add(1)
+		<-release
+		return loaderTestUser{Id: "u1", Name: "Alice"}, nil
+	}
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		_, err := loader.GetOrLoad(ctx, "user:u1", time.Minute, loadFn)
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected %v while the load hangs, actual: %v", context.DeadlineExceeded, err)
+		}
+	}
+
+	close(release)
+	user, err := loader.GetOrLoad(context.Background(), "user:u1", time.Minute, loadFn)
+	if err != nil || user.Name != "Alice" {
+		t.Errorf("expected Alice, actual: %+v, %v", user, err)
+	}
+	if loads.Load() != 1 {
+		t.Errorf("expected the hung load to be shared, actual: %d loads", loads.Load())
+	}
+}
+
+func TestLoaderNegativeCachingAndTags(t *testing.T) {
+	redisClient, server := newTestRedisClientHelper(t)
+	loader := NewLoader(&LoaderOptions[loaderTestUser]{
+		CacheHelper: NewCacheHelper(&CacheConfigOptions{RedisClientHelper: redisClient}),
+		NegativeTTL: time.Second,
+	})
+	ctx := context.Background()
+
+	var loads atomic.Int32
+	notFound := func(ctx context.Context) (loaderTestUser, error) {
+		loads.Add(1)
+		return loaderTestUser{}, ErrNotFound
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := loader.GetOrLoad(ctx, "user:missing", time.Minute, notFound); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected %v, actual: %v", ErrNotFound, err)
+		}
+	}
+	if loads.Load() != 1 {
+		t.Errorf("expected the not found result to be cached, actual loads: %d", loads.Load())
+	}
+	server.FastForward(2 * time.Second)
+	_, _ = loader.GetOrLoad(ctx, "user:missing", time.Minute, notFound)
+	if loads.Load() != 2 {
+		t.Errorf("expected the not found result to expire with the negative ttl, actual loads: %d", loads.Load())
+	}
+
+	if err := loader.Put(ctx, "user:u1", loaderTestUser{Id: "u1", Name: "Alice"}, time.Minute, "org:1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := loader.Put(ctx, "user:u2", loaderTestUser{Id: "u2", Name: "Bob"}, time.Minute, "org:1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := loader.InvalidateTags(ctx, "org:1"); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"cache:user:u1", "cache:user:u2", "cache:tag:org:1"} {
+		if server.Exists(key) {
+			t.Errorf("expected %s to be invalidated", key)
+		}
+	}
+}
+
+func TestLoaderEarlyExpiration(t *testing.T) {
+	redisClient, _ := newTestRedisClientHelper(t)
+	cache := NewCacheHelper(&CacheConfigOptions{RedisClientHelper: redisClient})
+	// A slow load close to its expiry is always refreshed early with a huge beta
+	loader := NewLoader(&LoaderOptions[int]{CacheHelper: cache, EarlyExpirationBeta: 1e9})
+	ctx := context.Background()
+	if err := cache.Set(ctx, "cache:counter", &loaderEntry[int]{Value: 1, Delta: 100, Expiry: time.Now().Add(time.Minute).UnixMilli()}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := loader.GetOrLoad(ctx, "counter", time.Minute, func(ctx context.Context) (int, error) {
+		return 2, nil
+	})
+	if err != nil || value != 2 {
+		t.Errorf("expected the value to be refreshed early, actual: %d, %v", value, err)
+	}
+}
+
+func TestLoaderWriteBehind(t *testing.T) {
+	redisClient, _ := newTestRedisClientHelper(t)
+	var mu sync.Mutex
+	written := map[string]string{}
+	loader := NewLoader(&LoaderOptions[string]{
+		CacheHelper: NewCacheHelper(&CacheConfigOptions{RedisClientHelper: redisClient}),
+		WriteBehind: func(ctx context.Context, key string, value string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			written[key] = value
+			return nil
+		},
+	})
+	ctx := context.Background()
+	if err := loader.Put(ctx, "setting:theme", "dark", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := loader.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if written["setting:theme"] != "dark" {
+		t.Errorf("expected the write behind to be flushed on close, actual: %v", written)
+	}
+	if err := loader.Put(ctx, "setting:theme", "light", time.Minute); !errors.Is(err, ErrLoaderClosed) {
+		t.Errorf("expected %v, actual: %v", ErrLoaderClosed, err)
+	}
+}
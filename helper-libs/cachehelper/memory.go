@@ -0,0 +1,894 @@
+package cachehelper
+
+import (
+	"context"
+	"encoding"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const memorySubscriptionBuffer = 100
+
+var (
+	ErrWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	ErrNoSuchKey = errors.New("ERR no such key")
+	ErrNotInt    = errors.New("ERR value is not an integer or out of range")
+)
+
+type (
+	MemoryCacheOptions struct {
+		// Now is the clock of the TTLs, it falls back to time.Now. Tests move it forward to expire keys.
+		Now                  func() time.Time
+		Codec                Codec
+		Compressor           Compressor
+		CompressionThreshold int
+	}
+
+	// memoryEntry holds a string, a hash as map[string]string or a sorted set as map[string]float64.
+	memoryEntry struct {
+		value     interface{}
+		expiresAt time.Time
+	}
+
+	memoryCacheHelper struct {
+		mu          sync.Mutex
+		now         func() time.Time
+		values      *valueEncoder
+		entries     map[string]*memoryEntry
+		subscribers map[string]map[chan CacheMessage]struct{}
+	}
+
+	memoryCommand func(h *memoryCacheHelper) CachePipelineResult
+
+	// memoryCachePipeline queues the commands and runs them at once on Exec, so pipelines and transactions are atomic.
+	memoryCachePipeline struct {
+		helper        *memoryCacheHelper
+		transactionID string
+		mu            sync.Mutex
+		commands      []memoryCommand
+	}
+)
+
+// NewMemoryCacheHelper creates an in-process CacheHelperEnhancement behaving like the redis helper, for tests and
+// local development. Values aren't shared between processes.
+func NewMemoryCacheHelper(opts *MemoryCacheOptions) CacheHelperEnhancement {
+	now := opts.Now
+	if now == nil {
+		now = time.Now
+	}
+	return &memoryCacheHelper{
+		now: now,
+		values: newValueEncoder(&CacheConfigOptions{
+			Codec:                opts.Codec,
+			Compressor:           opts.Compressor,
+			CompressionThreshold: opts.CompressionThreshold,
+		}),
+		entries:     map[string]*memoryEntry{},
+		subscribers: map[string]map[chan CacheMessage]struct{}{},
+	}
+}
+
+func (h *memoryCacheHelper) GetTransaction(ctx context.Context, transactionID string) CacheTransactionExecution {
+	return &memoryCachePipeline{helper: h, transactionID: transactionID}
+}
+
+func (h *memoryCacheHelper) GetPipeline(ctx context.Context, transactionID string) CachePipelineExecution {
+	return &memoryCachePipeline{helper: h, transactionID: transactionID}
+}
+
+func (h *memoryCacheHelper) Exists(ctx context.Context, key string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.entry(key) == nil {
+		return redis.Nil
+	}
+	return nil
+}
+
+func (h *memoryCacheHelper) Get(ctx context.Context, key string, value interface{}) error {
+	h.mu.Lock()
+	data, err := h.getString(key)
+	h.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return DecodeValue([]byte(data), &value)
+}
+
+func (h *memoryCacheHelper) GetInterface(ctx context.Context, key string, value interface{}) (interface{}, error) {
+	h.mu.Lock()
+	data, err := h.getString(key)
+	h.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return decodeInterface(data, value)
+}
+
+func (h *memoryCacheHelper) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	data, err := h.values.encode(value)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.set(key, string(data), expiration)
+	return nil
+}
+
+func (h *memoryCacheHelper) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	data, err := h.values.encode(value)
+	if err != nil {
+		return false, err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.setNX(key, string(data), expiration), nil
+}
+
+func (h *memoryCacheHelper) Del(ctx context.Context, key string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.entries, key)
+	return nil
+}
+
+func (h *memoryCacheHelper) Expire(ctx context.Context, key string, expiration time.Duration) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.expire(key, expiration)
+	return nil
+}
+
+func (h *memoryCacheHelper) DelMulti(ctx context.Context, keys ...string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range keys {
+		delete(h.entries, key)
+	}
+	return nil
+}
+
+// GetKeysByPattern pages through the sorted keys matching the glob pattern, the cursor is the offset of the next page.
+func (h *memoryCacheHelper) GetKeysByPattern(ctx context.Context, pattern string, cursor uint64, limit int64) ([]string, uint64, error) {
+	matcher, err := globToRegexp(pattern)
+	if err != nil {
+		return nil, 0, err
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+
+	h.mu.Lock()
+	keys := make([]string, 0, len(h.entries))
+	for key := range h.entries {
+		if h.entry(key) != nil {
+			keys = append(keys, key)
+		}
+	}
+	h.mu.Unlock()
+	sort.Strings(keys)
+
+	if cursor >= uint64(len(keys)) {
+		return []string{}, 0, nil
+	}
+	end := min(cursor+uint64(limit), uint64(len(keys)))
+	matches := make([]string, 0, end-cursor)
+	for _, key := range keys[cursor:end] {
+		if matcher.MatchString(key) {
+			matches = append(matches, key)
+		}
+	}
+	if end == uint64(len(keys)) {
+		end = 0
+	}
+	return matches, end, nil
+}
+
+func (h *memoryCacheHelper) SubscribeMessage(ctx context.Context, keySpace string, subscribeFunc SubscribeFunc) {
+	messages := make(chan CacheMessage, memorySubscriptionBuffer)
+	h.mu.Lock()
+	if h.subscribers[keySpace] == nil {
+		h.subscribers[keySpace] = map[chan CacheMessage]struct{}{}
+	}
+	h.subscribers[keySpace][messages] = struct{}{}
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		delete(h.subscribers[keySpace], messages)
+		h.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message := <-messages:
+			go func() {
+				handleCacheMessage(subscribeFunc, message)
+			}()
+		}
+	}
+}
+
+// PublishMessage drops the message for subscribers lagging more than 100 messages behind, as redis drops slow clients.
+func (h *memoryCacheHelper) PublishMessage(ctx context.Context, keySpace string, message interface{}) error {
+	payload, err := formatArg(withTraceId(ctx, message))
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.subscribers[keySpace]) == 0 {
+		return fmt.Errorf("published message with response:  %v", 0)
+	}
+	for subscriber := range h.subscribers[keySpace] {
+		select {
+		case subscriber <- CacheMessage{Message: redis.Message{Channel: keySpace, Payload: payload}}:
+		default:
+		}
+	}
+	return nil
+}
+
+// GetMulti returns the stored value of each key, nil for the missing ones, like MGET.
+func (h *memoryCacheHelper) GetMulti(ctx context.Context, data interface{}, keys ...string) ([]interface{}, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	result := make([]interface{}, len(keys))
+	for i, key := range keys {
+		if value, ok := h.entryValue(key).(string); ok {
+			result[i] = value
+		}
+	}
+	return result, nil
+}
+
+func (h *memoryCacheHelper) RenameKey(ctx context.Context, oldKey, newKey string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entry := h.entry(oldKey)
+	if entry == nil {
+		return ErrNoSuchKey
+	}
+	delete(h.entries, oldKey)
+	h.entries[newKey] = entry
+	return nil
+}
+
+func (h *memoryCacheHelper) GetStrLenght(ctx context.Context, key string) (int64, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	data, err := h.getString(key)
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return int64(len(data)), err
+}
+
+func (h *memoryCacheHelper) GetType(ctx context.Context, key string) (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	switch h.entryValue(key).(type) {
+	case string:
+		return "string", nil
+	case map[string]string:
+		return "hash", nil
+	case map[string]float64:
+		return "zset", nil
+	case []string:
+		return "list", nil
+	}
+	return "none", nil
+}
+
+func (h *memoryCacheHelper) DebugObjectByKey(ctx context.Context, key string) (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entry := h.entry(key)
+	if entry == nil {
+		return "", ErrNoSuchKey
+	}
+	length := 0
+	switch value := entry.value.(type) {
+	case string:
+		length = len(value)
+	case map[string]string:
+		length = len(value)
+	case map[string]float64:
+		length = len(value)
+	case []string:
+		length = len(value)
+	}
+	return fmt.Sprintf("Value at:0x0 refcount:1 encoding:memory serializedlength:%d lru:0 lru_seconds_idle:0", length), nil
+}
+
+// TimeExpire returns -2 for missing keys and -1 for keys without expiry, like TTL.
+func (h *memoryCacheHelper) TimeExpire(ctx context.Context, key string) (time.Duration, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entry := h.entry(key)
+	switch {
+	case entry == nil:
+		return -2, nil
+	case entry.expiresAt.IsZero():
+		return -1, nil
+	}
+	return entry.expiresAt.Sub(h.now()).Round(time.Second), nil
+}
+
+func (h *memoryCacheHelper) HSet(ctx context.Context, key, mapKey string, mapValue interface{}, expiration time.Duration) (bool, error) {
+	fieldValue, err := h.values.encodeField(mapValue)
+	if err != nil {
+		return false, err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hash, err := h.hash(key, true)
+	if err != nil {
+		return false, err
+	}
+	hash[mapKey] = fieldValue
+	h.expireField(key, expiration)
+	return true, nil
+}
+
+func (h *memoryCacheHelper) HSetNX(ctx context.Context, key string, mapKey string, mapValue interface{}, expiration time.Duration) (bool, error) {
+	fieldValue, err := h.values.encodeField(mapValue)
+	if err != nil {
+		return false, err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hash, err := h.hash(key, true)
+	if err != nil {
+		return false, err
+	}
+	if _, exists := hash[mapKey]; exists {
+		return false, nil
+	}
+	hash[mapKey] = fieldValue
+	h.expireField(key, expiration)
+	return true, nil
+}
+
+func (h *memoryCacheHelper) HGet(ctx context.Context, key, mapKey string) (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hash, err := h.hash(key, false)
+	if err != nil {
+		return "", err
+	}
+	value, exists := hash[mapKey]
+	if !exists {
+		return "", redis.Nil
+	}
+	return value, nil
+}
+
+func (h *memoryCacheHelper) HGetAll(ctx context.Context, key string, mapKeys []string) (map[string]string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hash, err := h.hash(key, false)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(hash))
+	for field, value := range hash {
+		values[field] = value
+	}
+	return values, nil
+}
+
+func (h *memoryCacheHelper) HIncreaseBy(ctx context.Context, key, mapKey string, increase int64) (bool, string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	value, err := h.hincrBy(key, mapKey, increase)
+	if err != nil {
+		return false, "", err
+	}
+	return true, strconv.FormatInt(value, 10), nil
+}
+
+func (h *memoryCacheHelper) HMSet(ctx context.Context, key string, mapData map[string]interface{}, expiration time.Duration) (bool, error) {
+	fields := make(map[string]string, len(mapData))
+	for field, value := range mapData {
+		fieldValue, err := h.values.encodeField(value)
+		if err != nil {
+			return false, err
+		}
+		fields[field] = fieldValue
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hash, err := h.hash(key, true)
+	if err != nil {
+		return false, err
+	}
+	for field, value := range fields {
+		hash[field] = value
+	}
+	h.expireField(key, expiration)
+	return true, nil
+}
+
+func (h *memoryCacheHelper) HMGet(ctx context.Context, key string, fields []string) (map[string]interface{}, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hash, err := h.hash(key, false)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		if value, exists := hash[field]; exists {
+			result[field] = value
+		} else {
+			result[field] = nil
+		}
+	}
+	return result, nil
+}
+
+// entry returns the live entry of key, expired entries are removed on access. Callers hold mu.
+func (h *memoryCacheHelper) entry(key string) *memoryEntry {
+	entry, exists := h.entries[key]
+	if !exists {
+		return nil
+	}
+	if !entry.expiresAt.IsZero() && !h.now().Before(entry.expiresAt) {
+		delete(h.entries, key)
+		return nil
+	}
+	return entry
+}
+
+func (h *memoryCacheHelper) entryValue(key string) interface{} {
+	if entry := h.entry(key); entry != nil {
+		return entry.value
+	}
+	return nil
+}
+
+func (h *memoryCacheHelper) getString(key string) (string, error) {
+	entry := h.entry(key)
+	if entry == nil {
+		return "", redis.Nil
+	}
+	value, ok := entry.value.(string)
+	if !ok {
+		return "", ErrWrongType
+	}
+	return value, nil
+}
+
+func (h *memoryCacheHelper) set(key, value string, expiration time.Duration) {
+	entry := &memoryEntry{value: value}
+	if expiration > 0 {
+		entry.expiresAt = h.now().Add(expiration)
+	}
+	h.entries[key] = entry
+}
+
+func (h *memoryCacheHelper) setNX(key, value string, expiration time.Duration) bool {
+	if h.entry(key) != nil {
+		return false
+	}
+	h.set(key, value, expiration)
+	return true
+}
+
+// expire sets the TTL of key, a non positive expiration deletes it like EXPIRE.
+func (h *memoryCacheHelper) expire(key string, expiration time.Duration) bool {
+	entry := h.entry(key)
+	switch {
+	case entry == nil:
+		return false
+	case expiration <= 0:
+		delete(h.entries, key)
+	default:
+		entry.expiresAt = h.now().Add(expiration)
+	}
+	return true
+}
+
+// expireField applies the expiration of the hash setters, zero keeps the current TTL.
+func (h *memoryCacheHelper) expireField(key string, expiration time.Duration) {
+	if expiration != 0 {
+		h.expire(key, expiration)
+	}
+}
+
+func (h *memoryCacheHelper) hash(key string, create bool) (map[string]string, error) {
+	entry := h.entry(key)
+	if entry == nil {
+		hash := map[string]string{}
+		if create {
+			h.entries[key] = &memoryEntry{value: hash}
+		}
+		return hash, nil
+	}
+	hash, ok := entry.value.(map[string]string)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	return hash, nil
+}
+
+func (h *memoryCacheHelper) del(keys ...string) int64 {
+	deleted := int64(0)
+	for _, key := range keys {
+		if h.entry(key) != nil {
+			delete(h.entries, key)
+			deleted++
+		}
+	}
+	return deleted
+}
+
+func (h *memoryCacheHelper) hincrBy(key, field string, increase int64) (int64, error) {
+	hash, err := h.hash(key, true)
+	if err != nil {
+		return 0, err
+	}
+	current := int64(0)
+	if value, exists := hash[field]; exists {
+		if current, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return 0, ErrNotInt
+		}
+	}
+	hash[field] = strconv.FormatInt(current+increase, 10)
+	return current + increase, nil
+}
+
+// incrBy keeps the TTL of key like INCRBY.
+func (h *memoryCacheHelper) incrBy(key string, increase int64) (int64, error) {
+	entry := h.entry(key)
+	if entry == nil {
+		h.set(key, strconv.FormatInt(increase, 10), 0)
+		return increase, nil
+	}
+	value, ok := entry.value.(string)
+	if !ok {
+		return 0, ErrWrongType
+	}
+	current, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, ErrNotInt
+	}
+	entry.value = strconv.FormatInt(current+increase, 10)
+	return current + increase, nil
+}
+
+func (h *memoryCacheHelper) zadd(key string, members []redis.Z) (int64, error) {
+	set, err := h.sortedSet(key, true)
+	if err != nil {
+		return 0, err
+	}
+	added := int64(0)
+	for _, member := range members {
+		name, err := formatArg(member.Member)
+		if err != nil {
+			return added, err
+		}
+		if _, exists := set[name]; !exists {
+			added++
+		}
+		set[name] = member.Score
+	}
+	return added, nil
+}
+
+func (h *memoryCacheHelper) zrangeWithScores(key string, start, stop int64) ([]redis.Z, error) {
+	set, err := h.sortedSet(key, false)
+	if err != nil {
+		return nil, err
+	}
+	members := sortedMembers(set)
+	start, stop = rankRange(int64(len(members)), start, stop)
+	if start > stop {
+		return []redis.Z{}, nil
+	}
+	return members[start : stop+1], nil
+}
+
+func (h *memoryCacheHelper) zremRangeByScore(key string, inRange func(float64) bool) (int64, error) {
+	set, err := h.sortedSet(key, false)
+	if err != nil {
+		return 0, err
+	}
+	removed := int64(0)
+	for member, score := range set {
+		if inRange(score) {
+			delete(set, member)
+			removed++
+		}
+	}
+	if len(set) == 0 {
+		delete(h.entries, key)
+	}
+	return removed, nil
+}
+
+func (h *memoryCacheHelper) sortedSet(key string, create bool) (map[string]float64, error) {
+	entry := h.entry(key)
+	if entry == nil {
+		set := map[string]float64{}
+		if create {
+			h.entries[key] = &memoryEntry{value: set}
+		}
+		return set, nil
+	}
+	set, ok := entry.value.(map[string]float64)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	return set, nil
+}
+
+func (p *memoryCachePipeline) BuildCommand(ctx context.Context, cacheCommandType CacheCommandType, data ...interface{}) error {
+	if len(data) == 0 {
+		return errors.New("missing data to process")
+	}
+	key, err := commandArg[string](data, 0)
+	if err != nil {
+		return err
+	}
+
+	var command memoryCommand
+	switch cacheCommandType {
+	case CacheCommandTypeGet, CacheCommandTypeGetInterface:
+		command = func(h *memoryCacheHelper) CachePipelineResult {
+			value, err := h.getString(key)
+			return CachePipelineResult{Result: []interface{}{value}, Err: err}
+		}
+	case CacheCommandTypeAddMemberWithScore:
+		member, err := commandArg[string](data, 1)
+		if err != nil {
+			return err
+		}
+		score, err := commandArg[float64](data, 2)
+		if err != nil {
+			return err
+		}
+		command = func(h *memoryCacheHelper) CachePipelineResult {
+			added, err := h.zadd(key, []redis.Z{{Score: score, Member: member}})
+			return CachePipelineResult{Result: []interface{}{added}, Err: err}
+		}
+	case CacheCommandTypeGetMembersWithScore:
+		start, stop, err := commandRange(data)
+		if err != nil {
+			return err
+		}
+		command = func(h *memoryCacheHelper) CachePipelineResult {
+			members, err := h.zrangeWithScores(key, start, stop)
+			result := make([]interface{}, len(members))
+			for i, member := range members {
+				result[i] = RedisZSliceResult{Z: member}
+			}
+			return CachePipelineResult{Result: result, Err: err}
+		}
+	case CacheCommandTypeRemoveMembersWithScore:
+		min, err := commandArg[string](data, 1)
+		if err != nil {
+			return err
+		}
+		max, err := commandArg[string](data, 2)
+		if err != nil {
+			return err
+		}
+		inRange, err := scoreRange(min, max)
+		if err != nil {
+			return err
+		}
+		command = func(h *memoryCacheHelper) CachePipelineResult {
+			removed, err := h.zremRangeByScore(key, inRange)
+			return CachePipelineResult{Result: []interface{}{removed}, Err: err}
+		}
+	case CacheCommandTypeExpire:
+		expiration, err := commandDuration(data, 1)
+		if err != nil {
+			return err
+		}
+		command = func(h *memoryCacheHelper) CachePipelineResult {
+			h.expire(key, expiration)
+			return CachePipelineResult{Result: []interface{}{"expire", key, int64(expiration / time.Second)}}
+		}
+	case CacheCommandTypeSetNX:
+		if len(data) < 2 {
+			return errors.New("missing argument 1")
+		}
+		value, err := formatArg(data[1])
+		if err != nil {
+			return err
+		}
+		expiration, err := commandDuration(data, 2)
+		if err != nil {
+			return err
+		}
+		command = func(h *memoryCacheHelper) CachePipelineResult {
+			h.setNX(key, value, expiration)
+			if expiration == 0 {
+				return CachePipelineResult{Result: []interface{}{"setnx", key, data[1]}}
+			}
+			return CachePipelineResult{Result: []interface{}{"set", key, data[1], "ex", int64(expiration / time.Second), "nx"}}
+		}
+	case CacheCommandTypeIncrease:
+		command = func(h *memoryCacheHelper) CachePipelineResult {
+			value, err := h.incrBy(key, 1)
+			return CachePipelineResult{Result: []interface{}{value}, Err: err}
+		}
+	case CacheCommandTypeDel:
+		command = func(h *memoryCacheHelper) CachePipelineResult {
+			return CachePipelineResult{Result: []interface{}{h.del(key)}}
+		}
+	default:
+		return errors.New("not found any matched command type to process")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.commands = append(p.commands, command)
+	return nil
+}
+
+func (p *memoryCachePipeline) GetCommands(context.Context) (CacheLazyExecute, error) {
+	return p, nil
+}
+
+// Exec runs the queued commands, it returns the first command error like the redis pipelines.
+func (p *memoryCachePipeline) Exec(ctx context.Context) ([]CachePipelineResult, error) {
+	p.mu.Lock()
+	commands := p.commands
+	p.commands = nil
+	p.mu.Unlock()
+
+	p.helper.mu.Lock()
+	defer p.helper.mu.Unlock()
+	result := make([]CachePipelineResult, len(commands))
+	for i, command := range commands {
+		result[i] = command(p.helper)
+	}
+	for _, item := range result {
+		if item.Err != nil {
+			return nil, item.Err
+		}
+	}
+	return result, nil
+}
+
+func (p *memoryCachePipeline) Discard(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.commands = nil
+	return nil
+}
+
+// scoreRange parses the min and max scores of the sorted set commands, e.g. `-inf`, `(1` or `5`.
+func scoreRange(min, max string) (func(float64) bool, error) {
+	lower, err := parseScoreBound(min, func(score, bound float64) bool { return score > bound }, func(score, bound float64) bool { return score >= bound })
+	if err != nil {
+		return nil, err
+	}
+	upper, err := parseScoreBound(max, func(score, bound float64) bool { return score < bound }, func(score, bound float64) bool { return score <= bound })
+	if err != nil {
+		return nil, err
+	}
+	return func(score float64) bool { return lower(score) && upper(score) }, nil
+}
+
+func parseScoreBound(bound string, exclusive, inclusive func(score, bound float64) bool) (func(float64) bool, error) {
+	compare := inclusive
+	if strings.HasPrefix(bound, "(") {
+		bound, compare = bound[1:], exclusive
+	}
+	value, err := strconv.ParseFloat(bound, 64)
+	if err != nil {
+		return nil, errors.New("ERR min or max is not a float")
+	}
+	return func(score float64) bool { return compare(score, value) }, nil
+}
+
+// sortedMembers returns the members ordered by score then member.
+func sortedMembers(set map[string]float64) []redis.Z {
+	members := make([]redis.Z, 0, len(set))
+	for member, score := range set {
+		members = append(members, redis.Z{Score: score, Member: member})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].Score != members[j].Score {
+			return members[i].Score < members[j].Score
+		}
+		return members[i].Member.(string) < members[j].Member.(string)
+	})
+	return members
+}
+
+// rankRange bounds the start and stop ranks to size, negative ranks count from the end. The range is empty when
+// start is after stop.
+func rankRange(size, start, stop int64) (int64, int64) {
+	if start < 0 {
+		start = max(size+start, 0)
+	}
+	if stop < 0 {
+		stop = size + stop
+	}
+	return start, min(stop, size-1)
+}
+
+// formatArg converts a value the way the redis client writes command arguments.
+func formatArg(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		if v {
+			return "1", nil
+		}
+		return "0", nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case time.Duration:
+		return strconv.FormatInt(v.Nanoseconds(), 10), nil
+	case encoding.BinaryMarshaler:
+		data, err := v.MarshalBinary()
+		return string(data), err
+	}
+	return "", fmt.Errorf("redis: can't marshal %T (implement encoding.BinaryMarshaler)", value)
+}
+
+// globToRegexp compiles a redis glob pattern, `*`, `?`, `[...]` and `\` escapes are supported.
+func globToRegexp(pattern string) (*regexp.Regexp, error) {
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				expr.WriteString(regexp.QuoteMeta(pattern[i:]))
+				i = len(pattern)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "^") {
+				class = "^" + regexp.QuoteMeta(class[1:])
+			} else {
+				class = regexp.QuoteMeta(class)
+			}
+			expr.WriteString("[" + strings.ReplaceAll(class, `\-`, "-") + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.Compile(expr.String())
+}
@@ -0,0 +1,379 @@
+package cachehelper
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type (
+	// memoryTypedPipeline runs its commands at once under the lock of the helper, so pipelines and transactions
+	// are both atomic.
+	memoryTypedPipeline struct {
+		mu       sync.Mutex
+		helper   *memoryCacheHelper
+		commands []func(h *memoryCacheHelper) error
+		err      error
+	}
+)
+
+func (h *memoryCacheHelper) NewPipeline(ctx context.Context) CachePipeline {
+	return &memoryTypedPipeline{helper: h}
+}
+
+func (h *memoryCacheHelper) NewTransaction(ctx context.Context) CachePipeline {
+	return &memoryTypedPipeline{helper: h}
+}
+
+// queueMemory resolves the future with the result of run on Exec.
+func queueMemory[T any](p *memoryTypedPipeline, run func(h *memoryCacheHelper) (T, error)) *Future[T] {
+	future := &Future[T]{}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.commands = append(p.commands, func(h *memoryCacheHelper) error {
+		value, err := run(h)
+		future.resolve(value, err)
+		return err
+	})
+	return future
+}
+
+func failMemory[T any](p *memoryTypedPipeline, err error) *Future[T] {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err == nil {
+		p.err = err
+	}
+	return failedFuture[T](err)
+}
+
+func (p *memoryTypedPipeline) Get(ctx context.Context, key string) *Future[string] {
+	return queueMemory(p, func(h *memoryCacheHelper) (string, error) {
+		return h.getString(key)
+	})
+}
+
+func (p *memoryTypedPipeline) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *Future[bool] {
+	data, err := p.helper.values.encode(value)
+	if err != nil {
+		return failMemory[bool](p, err)
+	}
+	return queueMemory(p, func(h *memoryCacheHelper) (bool, error) {
+		h.set(key, string(data), expiration)
+		return true, nil
+	})
+}
+
+func (p *memoryTypedPipeline) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *Future[bool] {
+	data, err := p.helper.values.encode(value)
+	if err != nil {
+		return failMemory[bool](p, err)
+	}
+	return queueMemory(p, func(h *memoryCacheHelper) (bool, error) {
+		return h.setNX(key, string(data), expiration), nil
+	})
+}
+
+func (p *memoryTypedPipeline) MGet(ctx context.Context, keys ...string) *Future[[]interface{}] {
+	if len(keys) == 0 {
+		return failMemory[[]interface{}](p, ErrMissingArgument)
+	}
+	return queueMemory(p, func(h *memoryCacheHelper) ([]interface{}, error) {
+		values := make([]interface{}, len(keys))
+		for i, key := range keys {
+			if value, ok := h.entryValue(key).(string); ok {
+				values[i] = value
+			}
+		}
+		return values, nil
+	})
+}
+
+func (p *memoryTypedPipeline) Del(ctx context.Context, keys ...string) *Future[int64] {
+	if len(keys) == 0 {
+		return failMemory[int64](p, ErrMissingArgument)
+	}
+	return queueMemory(p, func(h *memoryCacheHelper) (int64, error) {
+		return h.del(keys...), nil
+	})
+}
+
+func (p *memoryTypedPipeline) Expire(ctx context.Context, key string, expiration time.Duration) *Future[bool] {
+	return queueMemory(p, func(h *memoryCacheHelper) (bool, error) {
+		return h.expire(key, expiration), nil
+	})
+}
+
+func (p *memoryTypedPipeline) Incr(ctx context.Context, key string) *Future[int64] {
+	return p.IncrBy(ctx, key, 1)
+}
+
+func (p *memoryTypedPipeline) IncrBy(ctx context.Context, key string, value int64) *Future[int64] {
+	return queueMemory(p, func(h *memoryCacheHelper) (int64, error) {
+		return h.incrBy(key, value)
+	})
+}
+
+func (p *memoryTypedPipeline) HSet(ctx context.Context, key, field string, value interface{}) *Future[int64] {
+	fieldValue, err := p.helper.values.encodeField(value)
+	if err != nil {
+		return failMemory[int64](p, err)
+	}
+	return queueMemory(p, func(h *memoryCacheHelper) (int64, error) {
+		hash, err := h.hash(key, true)
+		if err != nil {
+			return 0, err
+		}
+		_, exists := hash[field]
+		hash[field] = fieldValue
+		if exists {
+			return 0, nil
+		}
+		return 1, nil
+	})
+}
+
+func (p *memoryTypedPipeline) HGet(ctx context.Context, key, field string) *Future[string] {
+	return queueMemory(p, func(h *memoryCacheHelper) (string, error) {
+		hash, err := h.hash(key, false)
+		if err != nil {
+			return "", err
+		}
+		value, exists := hash[field]
+		if !exists {
+			return "", redis.Nil
+		}
+		return value, nil
+	})
+}
+
+func (p *memoryTypedPipeline) HGetAll(ctx context.Context, key string) *Future[map[string]string] {
+	return queueMemory(p, func(h *memoryCacheHelper) (map[string]string, error) {
+		hash, err := h.hash(key, false)
+		if err != nil {
+			return nil, err
+		}
+		values := make(map[string]string, len(hash))
+		for field, value := range hash {
+			values[field] = value
+		}
+		return values, nil
+	})
+}
+
+func (p *memoryTypedPipeline) HDel(ctx context.Context, key string, fields ...string) *Future[int64] {
+	if len(fields) == 0 {
+		return failMemory[int64](p, ErrMissingArgument)
+	}
+	return queueMemory(p, func(h *memoryCacheHelper) (int64, error) {
+		hash, err := h.hash(key, false)
+		if err != nil {
+			return 0, err
+		}
+		deleted := int64(0)
+		for _, field := range fields {
+			if _, exists := hash[field]; exists {
+				delete(hash, field)
+				deleted++
+			}
+		}
+		if len(hash) == 0 {
+			delete(h.entries, key)
+		}
+		return deleted, nil
+	})
+}
+
+func (p *memoryTypedPipeline) HIncrBy(ctx context.Context, key, field string, value int64) *Future[int64] {
+	return queueMemory(p, func(h *memoryCacheHelper) (int64, error) {
+		return h.hincrBy(key, field, value)
+	})
+}
+
+func (p *memoryTypedPipeline) ZAdd(ctx context.Context, key string, members ...redis.Z) *Future[int64] {
+	if len(members) == 0 {
+		return failMemory[int64](p, ErrMissingArgument)
+	}
+	return queueMemory(p, func(h *memoryCacheHelper) (int64, error) {
+		return h.zadd(key, members)
+	})
+}
+
+func (p *memoryTypedPipeline) ZRangeWithScores(ctx context.Context, key string, start, stop int64) *Future[[]redis.Z] {
+	return queueMemory(p, func(h *memoryCacheHelper) ([]redis.Z, error) {
+		return h.zrangeWithScores(key, start, stop)
+	})
+}
+
+func (p *memoryTypedPipeline) ZRangeByScore(ctx context.Context, key string, min, max string) *Future[[]string] {
+	inRange, err := scoreRange(min, max)
+	if err != nil {
+		return failMemory[[]string](p, err)
+	}
+	return queueMemory(p, func(h *memoryCacheHelper) ([]string, error) {
+		set, err := h.sortedSet(key, false)
+		if err != nil {
+			return nil, err
+		}
+		members := []string{}
+		for _, member := range sortedMembers(set) {
+			if inRange(member.Score) {
+				members = append(members, member.Member.(string))
+			}
+		}
+		return members, nil
+	})
+}
+
+func (p *memoryTypedPipeline) ZRemRangeByScore(ctx context.Context, key string, min, max string) *Future[int64] {
+	inRange, err := scoreRange(min, max)
+	if err != nil {
+		return failMemory[int64](p, err)
+	}
+	return queueMemory(p, func(h *memoryCacheHelper) (int64, error) {
+		return h.zremRangeByScore(key, inRange)
+	})
+}
+
+func (p *memoryTypedPipeline) LPush(ctx context.Context, key string, values ...interface{}) *Future[int64] {
+	return p.push(key, true, values)
+}
+
+func (p *memoryTypedPipeline) RPush(ctx context.Context, key string, values ...interface{}) *Future[int64] {
+	return p.push(key, false, values)
+}
+
+func (p *memoryTypedPipeline) LPop(ctx context.Context, key string) *Future[string] {
+	return queueMemory(p, func(h *memoryCacheHelper) (string, error) {
+		return h.pop(key, true)
+	})
+}
+
+func (p *memoryTypedPipeline) RPop(ctx context.Context, key string) *Future[string] {
+	return queueMemory(p, func(h *memoryCacheHelper) (string, error) {
+		return h.pop(key, false)
+	})
+}
+
+func (p *memoryTypedPipeline) LRange(ctx context.Context, key string, start, stop int64) *Future[[]string] {
+	return queueMemory(p, func(h *memoryCacheHelper) ([]string, error) {
+		list, err := h.list(key)
+		if err != nil {
+			return nil, err
+		}
+		start, stop := rankRange(int64(len(list)), start, stop)
+		if start > stop {
+			return []string{}, nil
+		}
+		return append([]string{}, list[start:stop+1]...), nil
+	})
+}
+
+func (p *memoryTypedPipeline) LLen(ctx context.Context, key string) *Future[int64] {
+	return queueMemory(p, func(h *memoryCacheHelper) (int64, error) {
+		list, err := h.list(key)
+		return int64(len(list)), err
+	})
+}
+
+// Exec returns the first error of the commands, redis.Nil aside, like the redis pipelines.
+func (p *memoryTypedPipeline) Exec(ctx context.Context) error {
+	p.mu.Lock()
+	commands, err := p.commands, p.err
+	p.commands, p.err = nil, nil
+	p.mu.Unlock()
+
+	p.helper.mu.Lock()
+	defer p.helper.mu.Unlock()
+	var commandErr error
+	for _, command := range commands {
+		if err := command(p.helper); err != nil && !errors.Is(err, redis.Nil) && commandErr == nil {
+			commandErr = err
+		}
+	}
+	if commandErr != nil {
+		return commandErr
+	}
+	return err
+}
+
+func (p *memoryTypedPipeline) Discard() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.commands, p.err = nil, nil
+}
+
+func (p *memoryTypedPipeline) push(key string, left bool, values []interface{}) *Future[int64] {
+	if len(values) == 0 {
+		return failMemory[int64](p, ErrMissingArgument)
+	}
+	fields := make([]string, len(values))
+	for i, value := range values {
+		field, err := p.helper.values.encodeField(value)
+		if err != nil {
+			return failMemory[int64](p, err)
+		}
+		fields[i] = field
+	}
+	return queueMemory(p, func(h *memoryCacheHelper) (int64, error) {
+		list, err := h.list(key)
+		if err != nil {
+			return 0, err
+		}
+		for _, field := range fields {
+			if left {
+				list = append([]string{field}, list...)
+			} else {
+				list = append(list, field)
+			}
+		}
+		h.setList(key, list)
+		return int64(len(list)), nil
+	})
+}
+
+func (h *memoryCacheHelper) list(key string) ([]string, error) {
+	entry := h.entry(key)
+	if entry == nil {
+		return nil, nil
+	}
+	list, ok := entry.value.([]string)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	return list, nil
+}
+
+// setList stores list under key keeping its TTL, an empty list removes the key like redis.
+func (h *memoryCacheHelper) setList(key string, list []string) {
+	if len(list) == 0 {
+		delete(h.entries, key)
+		return
+	}
+	if entry := h.entry(key); entry != nil {
+		entry.value = list
+		return
+	}
+	h.entries[key] = &memoryEntry{value: list}
+}
+
+func (h *memoryCacheHelper) pop(key string, left bool) (string, error) {
+	list, err := h.list(key)
+	if err != nil {
+		return "", err
+	}
+	if len(list) == 0 {
+		return "", redis.Nil
+	}
+	value := list[len(list)-1]
+	if left {
+		value, list = list[0], list[1:]
+	} else {
+		list = list[:len(list)-1]
+	}
+	h.setList(key, list)
+	return value, nil
+}
@@ -0,0 +1,385 @@
+package cachehelper
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrPipelineNotExecuted = errors.New("cache: pipeline not executed")
+	ErrMissingArgument     = errors.New("cache: missing command argument")
+	// ErrCrossSlot is returned by the multi-key commands of a cluster transaction whose keys live in several slots,
+	// hash tag them with HashTagKey.
+	ErrCrossSlot = errors.New("cache: keys of a cluster transaction must share a slot")
+)
+
+type (
+	// Future is the result of a queued command, it is resolved by the Exec of its pipeline.
+	Future[T any] struct {
+		mu       sync.RWMutex
+		value    T
+		err      error
+		resolved bool
+	}
+
+	// CachePipeline queues typed commands and sends them at once on Exec, inside MULTI/EXEC for transactions.
+	// Bad arguments fail their future instead of panicking, the other commands still run.
+	// In cluster mode MGet and Del are split per slot in pipelines, and fail with ErrCrossSlot in transactions.
+	CachePipeline interface {
+		// Strings, the values are encoded like CacheHelper.Set, read them with DecodeFuture
+		Get(ctx context.Context, key string) *Future[string]
+		Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *Future[bool]
+		SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *Future[bool]
+		// MGet returns nil for the missing keys
+		MGet(ctx context.Context, keys ...string) *Future[[]interface{}]
+		Del(ctx context.Context, keys ...string) *Future[int64]
+		Expire(ctx context.Context, key string, expiration time.Duration) *Future[bool]
+
+		// Counters
+		Incr(ctx context.Context, key string) *Future[int64]
+		IncrBy(ctx context.Context, key string, value int64) *Future[int64]
+
+		// Hashes, string values are stored as they are like CacheHelper.HSet
+		HSet(ctx context.Context, key, field string, value interface{}) *Future[int64]
+		HGet(ctx context.Context, key, field string) *Future[string]
+		HGetAll(ctx context.Context, key string) *Future[map[string]string]
+		HDel(ctx context.Context, key string, fields ...string) *Future[int64]
+		HIncrBy(ctx context.Context, key, field string, value int64) *Future[int64]
+
+		// Sorted sets, min and max are scores like `-inf`, `(1` or `5`
+		ZAdd(ctx context.Context, key string, members ...redis.Z) *Future[int64]
+		ZRangeWithScores(ctx context.Context, key string, start, stop int64) *Future[[]redis.Z]
+		ZRangeByScore(ctx context.Context, key string, min, max string) *Future[[]string]
+		ZRemRangeByScore(ctx context.Context, key string, min, max string) *Future[int64]
+
+		// Lists
+		LPush(ctx context.Context, key string, values ...interface{}) *Future[int64]
+		RPush(ctx context.Context, key string, values ...interface{}) *Future[int64]
+		LPop(ctx context.Context, key string) *Future[string]
+		RPop(ctx context.Context, key string) *Future[string]
+		LRange(ctx context.Context, key string, start, stop int64) *Future[[]string]
+		LLen(ctx context.Context, key string) *Future[int64]
+
+		// Exec sends the queued commands and resolves their futures. It returns the first error of the commands,
+		// redis.Nil aside since a missing key doesn't fail the pipeline.
+		Exec(ctx context.Context) error
+		Discard()
+	}
+
+	redisTypedPipeline struct {
+		mu        sync.Mutex
+		pipeliner redis.Pipeliner
+		values    *valueEncoder
+		resolvers []func()
+		// err is the first argument error, the command isn't sent but Exec reports it
+		err error
+		// cluster pipelines split the multi-key commands per slot, cluster transactions refuse keys of several slots
+		cluster     bool
+		transaction bool
+	}
+)
+
+// Result returns the value of the command, ErrPipelineNotExecuted until its pipeline is executed.
+func (f *Future[T]) Result() (T, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if !f.resolved {
+		var zero T
+		return zero, ErrPipelineNotExecuted
+	}
+	return f.value, f.err
+}
+
+func (f *Future[T]) Val() T {
+	value, _ := f.Result()
+	return value
+}
+
+func (f *Future[T]) Err() error {
+	_, err := f.Result()
+	return err
+}
+
+func (f *Future[T]) resolve(value T, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.value, f.err, f.resolved = value, err, true
+}
+
+func failedFuture[T any](err error) *Future[T] {
+	future := &Future[T]{}
+	var zero T
+	future.resolve(zero, err)
+	return future
+}
+
+// DecodeFuture decodes the value of a Get future into T, whatever codec wrote it.
+func DecodeFuture[T any](future *Future[string]) (T, error) {
+	var value T
+	data, err := future.Result()
+	if err != nil {
+		return value, err
+	}
+	err = DecodeValue([]byte(data), &value)
+	return value, err
+}
+
+func newRedisTypedPipeline(pipeliner redis.Pipeliner, values *valueEncoder) CachePipeline {
+	return &redisTypedPipeline{pipeliner: pipeliner, values: values}
+}
+
+func newClusterTypedPipeline(pipeliner redis.Pipeliner, values *valueEncoder, transaction bool) CachePipeline {
+	return &redisTypedPipeline{pipeliner: pipeliner, values: values, cluster: true, transaction: transaction}
+}
+
+// queueCmd resolves the future of cmd after Exec.
+func queueCmd[T any](p *redisTypedPipeline, cmd interface{ Result() (T, error) }) *Future[T] {
+	future := &Future[T]{}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.resolvers = append(p.resolvers, func() { future.resolve(cmd.Result()) })
+	return future
+}
+
+func queueFailed[T any](p *redisTypedPipeline, err error) *Future[T] {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err == nil {
+		p.err = err
+	}
+	return failedFuture[T](err)
+}
+
+func (p *redisTypedPipeline) Get(ctx context.Context, key string) *Future[string] {
+	return queueCmd[string](p, p.pipeliner.Get(ctx, key))
+}
+
+func (p *redisTypedPipeline) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *Future[bool] {
+	data, err := p.values.encode(value)
+	if err != nil {
+		return queueFailed[bool](p, err)
+	}
+	cmd := p.pipeliner.Set(ctx, key, string(data), expiration)
+	future := &Future[bool]{}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.resolvers = append(p.resolvers, func() {
+		err := cmd.Err()
+		future.resolve(err == nil, err)
+	})
+	return future
+}
+
+func (p *redisTypedPipeline) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *Future[bool] {
+	data, err := p.values.encode(value)
+	if err != nil {
+		return queueFailed[bool](p, err)
+	}
+	return queueCmd[bool](p, p.pipeliner.SetNX(ctx, key, string(data), expiration))
+}
+
+func (p *redisTypedPipeline) MGet(ctx context.Context, keys ...string) *Future[[]interface{}] {
+	if len(keys) == 0 {
+		return queueFailed[[]interface{}](p, ErrMissingArgument)
+	}
+	if !p.cluster || SameSlot(keys...) {
+		return queueCmd[[]interface{}](p, p.pipeliner.MGet(ctx, keys...))
+	}
+	if p.transaction {
+		return queueFailed[[]interface{}](p, ErrCrossSlot)
+	}
+	slots, indexes := groupBySlot(keys)
+	cmds := make([]*redis.SliceCmd, len(slots))
+	for i, slot := range slots {
+		cmds[i] = p.pipeliner.MGet(ctx, slotKeys(keys, indexes[slot])...)
+	}
+	future := &Future[[]interface{}]{}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.resolvers = append(p.resolvers, func() {
+		values := make([]interface{}, len(keys))
+		for i, slot := range slots {
+			slotValues, err := cmds[i].Result()
+			if err != nil {
+				future.resolve(nil, err)
+				return
+			}
+			for j, index := range indexes[slot] {
+				values[index] = slotValues[j]
+			}
+		}
+		future.resolve(values, nil)
+	})
+	return future
+}
+
+func (p *redisTypedPipeline) Del(ctx context.Context, keys ...string) *Future[int64] {
+	if len(keys) == 0 {
+		return queueFailed[int64](p, ErrMissingArgument)
+	}
+	if !p.cluster || SameSlot(keys...) {
+		return queueCmd[int64](p, p.pipeliner.Del(ctx, keys...))
+	}
+	if p.transaction {
+		return queueFailed[int64](p, ErrCrossSlot)
+	}
+	slots, indexes := groupBySlot(keys)
+	cmds := make([]*redis.IntCmd, len(slots))
+	for i, slot := range slots {
+		cmds[i] = p.pipeliner.Del(ctx, slotKeys(keys, indexes[slot])...)
+	}
+	future := &Future[int64]{}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.resolvers = append(p.resolvers, func() {
+		var deleted int64
+		for _, cmd := range cmds {
+			count, err := cmd.Result()
+			if err != nil {
+				future.resolve(0, err)
+				return
+			}
+			deleted += count
+		}
+		future.resolve(deleted, nil)
+	})
+	return future
+}
+
+func (p *redisTypedPipeline) Expire(ctx context.Context, key string, expiration time.Duration) *Future[bool] {
+	return queueCmd[bool](p, p.pipeliner.Expire(ctx, key, expiration))
+}
+
+func (p *redisTypedPipeline) Incr(ctx context.Context, key string) *Future[int64] {
+	return queueCmd[int64](p, p.pipeliner.Incr(ctx, key))
+}
+
+func (p *redisTypedPipeline) IncrBy(ctx context.Context, key string, value int64) *Future[int64] {
+	return queueCmd[int64](p, p.pipeliner.IncrBy(ctx, key, value))
+}
+
+func (p *redisTypedPipeline) HSet(ctx context.Context, key, field string, value interface{}) *Future[int64] {
+	fieldValue, err := p.values.encodeField(value)
+	if err != nil {
+		return queueFailed[int64](p, err)
+	}
+	return queueCmd[int64](p, p.pipeliner.HSet(ctx, key, field, fieldValue))
+}
+
+func (p *redisTypedPipeline) HGet(ctx context.Context, key, field string) *Future[string] {
+	return queueCmd[string](p, p.pipeliner.HGet(ctx, key, field))
+}
+
+func (p *redisTypedPipeline) HGetAll(ctx context.Context, key string) *Future[map[string]string] {
+	return queueCmd[map[string]string](p, p.pipeliner.HGetAll(ctx, key))
+}
+
+func (p *redisTypedPipeline) HDel(ctx context.Context, key string, fields ...string) *Future[int64] {
+	if len(fields) == 0 {
+		return queueFailed[int64](p, ErrMissingArgument)
+	}
+	return queueCmd[int64](p, p.pipeliner.HDel(ctx, key, fields...))
+}
+
+func (p *redisTypedPipeline) HIncrBy(ctx context.Context, key, field string, value int64) *Future[int64] {
+	return queueCmd[int64](p, p.pipeliner.HIncrBy(ctx, key, field, value))
+}
+
+func (p *redisTypedPipeline) ZAdd(ctx context.Context, key string, members ...redis.Z) *Future[int64] {
+	if len(members) == 0 {
+		return queueFailed[int64](p, ErrMissingArgument)
+	}
+	return queueCmd[int64](p, p.pipeliner.ZAdd(ctx, key, members...))
+}
+
+func (p *redisTypedPipeline) ZRangeWithScores(ctx context.Context, key string, start, stop int64) *Future[[]redis.Z] {
+	return queueCmd[[]redis.Z](p, p.pipeliner.ZRangeWithScores(ctx, key, start, stop))
+}
+
+func (p *redisTypedPipeline) ZRangeByScore(ctx context.Context, key string, min, max string) *Future[[]string] {
+	return queueCmd[[]string](p, p.pipeliner.ZRangeByScore(ctx, key, &redis.ZRangeBy{Min: min, Max: max}))
+}
+
+func (p *redisTypedPipeline) ZRemRangeByScore(ctx context.Context, key string, min, max string) *Future[int64] {
+	return queueCmd[int64](p, p.pipeliner.ZRemRangeByScore(ctx, key, min, max))
+}
+
+func (p *redisTypedPipeline) LPush(ctx context.Context, key string, values ...interface{}) *Future[int64] {
+	fields, err := p.encodeFields(values)
+	if err != nil {
+		return queueFailed[int64](p, err)
+	}
+	return queueCmd[int64](p, p.pipeliner.LPush(ctx, key, fields...))
+}
+
+func (p *redisTypedPipeline) RPush(ctx context.Context, key string, values ...interface{}) *Future[int64] {
+	fields, err := p.encodeFields(values)
+	if err != nil {
+		return queueFailed[int64](p, err)
+	}
+	return queueCmd[int64](p, p.pipeliner.RPush(ctx, key, fields...))
+}
+
+func (p *redisTypedPipeline) LPop(ctx context.Context, key string) *Future[string] {
+	return queueCmd[string](p, p.pipeliner.LPop(ctx, key))
+}
+
+func (p *redisTypedPipeline) RPop(ctx context.Context, key string) *Future[string] {
+	return queueCmd[string](p, p.pipeliner.RPop(ctx, key))
+}
+
+func (p *redisTypedPipeline) LRange(ctx context.Context, key string, start, stop int64) *Future[[]string] {
+	return queueCmd[[]string](p, p.pipeliner.LRange(ctx, key, start, stop))
+}
+
+func (p *redisTypedPipeline) LLen(ctx context.Context, key string) *Future[int64] {
+	return queueCmd[int64](p, p.pipeliner.LLen(ctx, key))
+}
+
+func (p *redisTypedPipeline) Exec(ctx context.Context) error {
+	p.mu.Lock()
+	resolvers, argumentErr := p.resolvers, p.err
+	p.resolvers, p.err = nil, nil
+	p.mu.Unlock()
+
+	var err error
+	if len(resolvers) > 0 {
+		_, err = p.pipeliner.Exec(ctx)
+	}
+	for _, resolve := range resolvers {
+		resolve()
+	}
+	if errors.Is(err, redis.Nil) {
+		err = nil
+	}
+	if err == nil {
+		err = argumentErr
+	}
+	return err
+}
+
+func (p *redisTypedPipeline) Discard() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pipeliner.Discard()
+	p.resolvers, p.err = nil, nil
+}
+
+func (p *redisTypedPipeline) encodeFields(values []interface{}) ([]interface{}, error) {
+	if len(values) == 0 {
+		return nil, ErrMissingArgument
+	}
+	fields := make([]interface{}, len(values))
+	for i, value := range values {
+		field, err := p.values.encodeField(value)
+		if err != nil {
+			return nil, err
+		}
+		fields[i] = field
+	}
+	return fields, nil
+}
@@ -2,16 +2,25 @@ package cachehelper
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"reflect"
+	"sort"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+const (
+	// clusterCursorShift splits the cursor of GetKeysByPattern into the master index and the cursor of the master
+	clusterCursorShift = 48
+	clusterCursorMask  = 1<<clusterCursorShift - 1
+)
+
 type clusterRedisHelper struct {
 	clusterClient *redis.ClusterClient
+	values        *valueEncoder
 }
 
 func (h *clusterRedisHelper) GetTransaction(ctx context.Context, transactionID string) CacheTransactionExecution {
@@ -34,6 +43,14 @@ func (h *clusterRedisHelper) GetPipeline(ctx context.Context, transactionID stri
 	}
 }
 
+func (h *clusterRedisHelper) NewPipeline(ctx context.Context) CachePipeline {
+	return newClusterTypedPipeline(h.clusterClient.Pipeline(), h.values, false)
+}
+
+func (h *clusterRedisHelper) NewTransaction(ctx context.Context) CachePipeline {
+	return newClusterTypedPipeline(h.clusterClient.TxPipeline(), h.values, true)
+}
+
 func (h *clusterRedisHelper) Exists(ctx context.Context, key string) (err error) {
 	indicator, err := h.clusterClient.Exists(ctx, key).Result()
 	if err != nil {
@@ -50,7 +67,7 @@ func (h *clusterRedisHelper) Get(ctx context.Context, key string, value interfac
 	if err != nil {
 		return err
 	}
-	err = json.Unmarshal([]byte(data), &value)
+	err = DecodeValue([]byte(data), &value)
 	if err != nil {
 		return err
 	}
@@ -58,7 +75,7 @@ func (h *clusterRedisHelper) Get(ctx context.Context, key string, value interfac
 }
 
 func (h *clusterRedisHelper) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) (err error) {
-	data, err := json.Marshal(value)
+	data, err := h.values.encode(value)
 	if err != nil {
 		return err
 	}
@@ -70,11 +87,11 @@ func (h *clusterRedisHelper) Set(ctx context.Context, key string, value interfac
 }
 
 func (h *clusterRedisHelper) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (isSuccess bool, err error) {
-	data, err := json.Marshal(value)
+	data, err := h.values.encode(value)
 	if err != nil {
 		return false, err
 	}
-	_, err = h.clusterClient.SetNX(ctx, key, string(data), expiration).Result()
+	isSuccess, err = h.clusterClient.SetNX(ctx, key, string(data), expiration).Result()
 	if err != nil {
 		return false, err
 	}
@@ -102,62 +119,85 @@ func (h *clusterRedisHelper) GetInterface(ctx context.Context, key string, value
 	if err != nil {
 		return nil, err
 	}
+	return decodeInterface(data, value)
+}
 
-	typeValue := reflect.TypeOf(value)
-	kind := typeValue.Kind()
-
-	var outData interface{}
-	switch kind {
-	case reflect.Ptr, reflect.Struct, reflect.Slice:
-		outData = reflect.New(typeValue).Interface()
-	default:
-		outData = reflect.Zero(typeValue).Interface()
+// DelMulti deletes the keys with one DEL per slot since a DEL can't span slots.
+func (h *clusterRedisHelper) DelMulti(ctx context.Context, keys ...string) error {
+	slots, indexes := groupBySlot(keys)
+	pipeline := h.clusterClient.Pipeline()
+	for _, slot := range slots {
+		pipeline.Del(ctx, slotKeys(keys, indexes[slot])...)
 	}
-	err = json.Unmarshal([]byte(data), &outData)
+	_, err := pipeline.Exec(ctx)
+	return err
+}
+
+// GetKeysByPattern scans the masters one after the other. The returned cursor holds the index of the master in
+// its high bits and the cursor of that master in the low bits, it is 0 once every master is scanned.
+func (h *clusterRedisHelper) GetKeysByPattern(ctx context.Context, pattern string, cursor uint64, limit int64) ([]string, uint64, error) {
+	masters, err := h.masters(ctx)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-
-	switch kind {
-	case reflect.Ptr, reflect.Struct, reflect.Slice:
-		return reflect.ValueOf(outData).Elem().Interface(), nil
+	index, nodeCursor := int(cursor>>clusterCursorShift), cursor&clusterCursorMask
+	if index >= len(masters) {
+		return nil, 0, nil
 	}
-	var outValue interface{} = outData
-	if reflect.TypeOf(outData).ConvertibleTo(typeValue) {
-		outValueConverted := reflect.ValueOf(outData).Convert(typeValue)
-		outValue = outValueConverted.Interface()
+	keys, next, err := masters[index].Scan(ctx, nodeCursor, pattern, limit).Result()
+	if err != nil {
+		return nil, 0, err
 	}
-	return outValue, nil
-}
-
-func (h *clusterRedisHelper) DelMulti(ctx context.Context, keys ...string) error {
-	var err error
-	return err
+	if next == 0 {
+		if index++; index == len(masters) {
+			return keys, 0, nil
+		}
+	}
+	return keys, uint64(index)<<clusterCursorShift | next, nil
 }
 
-func (h *clusterRedisHelper) GetKeysByPattern(ctx context.Context, pattern string, cursor uint64, limit int64) ([]string, uint64, error) {
-	var err error
-	var keys []string
-	return keys, 0, err
+// masters returns the master nodes sorted by address so a scan cursor points to the same node between calls.
+func (h *clusterRedisHelper) masters(ctx context.Context) ([]*redis.Client, error) {
+	var (
+		mu      sync.Mutex
+		masters []*redis.Client
+	)
+	err := h.clusterClient.ForEachMaster(ctx, func(ctx context.Context, master *redis.Client) error {
+		mu.Lock()
+		defer mu.Unlock()
+		masters = append(masters, master)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(masters, func(i, j int) bool {
+		return masters[i].Options().Addr < masters[j].Options().Addr
+	})
+	return masters, nil
 }
 
 func (h *clusterRedisHelper) SubscribeMessage(ctx context.Context, keySpace string, subscribeFunc SubscribeFunc) {
 	subscribes := h.clusterClient.Subscribe(ctx, keySpace)
+	defer subscribes.Close()
 	messageChan := subscribes.Channel()
 	for {
-		message, ok := <-messageChan
-		if ok {
+		select {
+		case <-ctx.Done():
+			return
+		case message, ok := <-messageChan:
+			if !ok {
+				return
+			}
 			go func() {
-				func() {
-					_ = subscribeFunc(CacheMessage{Message: *message})
-				}()
+				handleCacheMessage(subscribeFunc, CacheMessage{Message: *message})
 			}()
 		}
 	}
 }
 
 func (h *clusterRedisHelper) PublishMessage(ctx context.Context, keySpace string, message interface{}) error {
-	result := h.clusterClient.Publish(ctx, keySpace, message)
+	result := h.clusterClient.Publish(ctx, keySpace, withTraceId(ctx, message))
 	var out int64
 	var err error
 	if out, err = result.Result(); err != nil {
@@ -169,12 +209,52 @@ func (h *clusterRedisHelper) PublishMessage(ctx context.Context, keySpace string
 	return nil
 }
 
-func (h *clusterRedisHelper) GetMulti(ctx context.Context, data interface{}, keys ...string) ([]interface{}, error) {
-	return nil, nil
+// GetMulti sends one MGET per slot and returns the values in the order of keys, nil for the missing ones.
+func (h *clusterRedisHelper) GetMulti(ctx context.Context, data interface{}, keys ...string) (result []interface{}, err error) {
+	slots, indexes := groupBySlot(keys)
+	pipeline := h.clusterClient.Pipeline()
+	cmds := make(map[int]*redis.SliceCmd, len(slots))
+	for _, slot := range slots {
+		cmds[slot] = pipeline.MGet(ctx, slotKeys(keys, indexes[slot])...)
+	}
+	if _, err = pipeline.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	result = make([]interface{}, len(keys))
+	for _, slot := range slots {
+		values := cmds[slot].Val()
+		for i, index := range indexes[slot] {
+			result[index] = values[i]
+		}
+	}
+	return result, nil
 }
 
+// RenameKey renames within a slot with RENAME, across slots the value is moved with DUMP and RESTORE which isn't
+// atomic.
 func (h *clusterRedisHelper) RenameKey(ctx context.Context, oldkey, newkey string) error {
-	return nil
+	if SameSlot(oldkey, newkey) {
+		return h.clusterClient.Rename(ctx, oldkey, newkey).Err()
+	}
+	value, err := h.clusterClient.Dump(ctx, oldkey).Result()
+	if errors.Is(err, redis.Nil) {
+		return ErrNoSuchKey
+	}
+	if err != nil {
+		return err
+	}
+	ttl, err := h.clusterClient.PTTL(ctx, oldkey).Result()
+	if err != nil {
+		return err
+	}
+	if ttl < 0 {
+		ttl = 0
+	}
+	if err = h.clusterClient.RestoreReplace(ctx, newkey, ttl, value).Err(); err != nil {
+		return err
+	}
+	return h.clusterClient.Del(ctx, oldkey).Err()
 }
 
 func (h *clusterRedisHelper) GetStrLenght(ctx context.Context, key string) (int64, error) {
@@ -194,29 +274,134 @@ func (h *clusterRedisHelper) TimeExpire(ctx context.Context, key string) (time.D
 }
 
 func (h *clusterRedisHelper) HSet(ctx context.Context, key, mapKey string, mapValue interface{}, expiration time.Duration) (isSet bool, err error) {
-	return isSet, err
+	var (
+		fieldValue string
+		boolResult *redis.BoolCmd
+		result     *redis.IntCmd
+	)
+	if fieldValue, err = h.values.encodeField(mapValue); err != nil {
+		return isSet, err
+	}
+
+	// HSET reports the number of new fields, updating an existing field is a successful set too
+	result = h.clusterClient.HSet(ctx, key, mapKey, fieldValue)
+	if err = result.Err(); err != nil {
+		return isSet, err
+	}
+
+	if expiration != time.Duration(0) {
+		boolResult = h.clusterClient.Expire(ctx, key, expiration)
+		if isSet, err = boolResult.Result(); !isSet || err != nil {
+			return isSet, err
+		}
+	}
+	return true, nil
 }
 
 func (h *clusterRedisHelper) HSetNX(ctx context.Context, key string, mapKey string, mapValue interface{}, expiration time.Duration) (isSet bool, err error) {
+	var (
+		fieldValue string
+		boolResult *redis.BoolCmd
+	)
+	if fieldValue, err = h.values.encodeField(mapValue); err != nil {
+		return isSet, err
+	}
+
+	boolResult = h.clusterClient.HSetNX(ctx, key, mapKey, fieldValue)
+	if isSet, err = boolResult.Result(); !isSet || err != nil {
+		return isSet, err
+	}
+	if expiration != time.Duration(0) {
+		boolResult = h.clusterClient.Expire(ctx, key, expiration)
+	}
+	if isSet, err = boolResult.Result(); !isSet || err != nil {
+		return isSet, err
+	}
 	return isSet, err
 }
 
 func (h *clusterRedisHelper) HGet(ctx context.Context, key, mapKey string) (value string, err error) {
-	return value, err
+	result := h.clusterClient.HGet(ctx, key, mapKey)
+	if err = result.Err(); err != nil {
+		return value, err
+	}
+	if value, err = result.Result(); err != nil {
+		return value, err
+	}
+	return value, nil
 }
 
 func (h *clusterRedisHelper) HGetAll(ctx context.Context, key string, mapKeys []string) (values map[string]string, err error) {
-	return values, err
+	result := h.clusterClient.HGetAll(ctx, key)
+	if err = result.Err(); err != nil {
+		return values, err
+	}
+	if values, err = result.Result(); values == nil || err != nil {
+		return values, err
+	}
+	return values, nil
 }
 
 func (h *clusterRedisHelper) HIncreaseBy(ctx context.Context, key, mapKey string, increase int64) (isIncreased bool, value string, err error) {
-	return isIncreased, value, err
+	result := h.clusterClient.HIncrBy(ctx, key, mapKey, increase)
+	if err = result.Err(); err != nil {
+		return isIncreased, value, err
+	}
+	var (
+		valueInt int64
+	)
+	if valueInt, err = result.Result(); err != nil {
+		return isIncreased, value, err
+	}
+
+	return true, strconv.FormatInt(valueInt, 10), nil
 }
 
 func (h *clusterRedisHelper) HMSet(ctx context.Context, key string, mapData map[string]interface{}, expiration time.Duration) (isSet bool, err error) {
-	return isSet, err
+	var (
+		inputData  map[string]interface{} = make(map[string]interface{}, len(mapData))
+		fieldValue string
+	)
+
+	for key, value := range mapData {
+		if fieldValue, err = h.values.encodeField(value); err != nil {
+			return isSet, err
+		}
+		inputData[key] = fieldValue
+	}
+	result := h.clusterClient.HMSet(ctx, key, inputData)
+	if err = result.Err(); err != nil {
+		return isSet, err
+	}
+	if ok, err := result.Result(); !ok || err != nil {
+		return isSet, err
+	}
+	if expiration != time.Duration(0) {
+		boolResult := h.clusterClient.Expire(ctx, key, expiration)
+		if isSet, err = boolResult.Result(); !isSet || err != nil {
+			return isSet, err
+		}
+	}
+
+	return true, nil
 }
 
 func (h *clusterRedisHelper) HMGet(ctx context.Context, key string, fields []string) (result map[string]interface{}, err error) {
-	return result, err
+	var (
+		results []interface{}
+	)
+	sliceResult := h.clusterClient.HMGet(ctx, key, fields...)
+	if err = sliceResult.Err(); err != nil {
+		return result, err
+	}
+
+	if results, err = sliceResult.Result(); err != nil {
+		return result, err
+	}
+
+	result = make(map[string]interface{}, len(results))
+	for index, item := range fields {
+		result[item] = results[index]
+	}
+	return result, nil
 }
@@ -2,7 +2,6 @@ package cachehelper
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
@@ -14,6 +13,7 @@ import (
 
 type redisHelper struct {
 	client *redis.Client
+	values *valueEncoder
 }
 
 func (h *redisHelper) GetTransaction(ctx context.Context, transactionID string) CacheTransactionExecution {
@@ -36,6 +36,14 @@ func (h *redisHelper) GetPipeline(ctx context.Context, transactionID string) Cac
 	}
 }
 
+func (h *redisHelper) NewPipeline(ctx context.Context) CachePipeline {
+	return newRedisTypedPipeline(h.client.Pipeline(), h.values)
+}
+
+func (h *redisHelper) NewTransaction(ctx context.Context) CachePipeline {
+	return newRedisTypedPipeline(h.client.TxPipeline(), h.values)
+}
+
 func (h *redisHelper) Exists(ctx context.Context, key string) (err error) {
 	indicator, err := h.client.Exists(ctx, key).Result()
 	if err != nil {
@@ -48,7 +56,7 @@ func (h *redisHelper) Exists(ctx context.Context, key string) (err error) {
 }
 
 func (h *redisHelper) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (isSucces bool, err error) {
-	data, err := json.Marshal(value)
+	data, err := h.values.encode(value)
 	if err != nil {
 		return false, err
 	}
@@ -65,7 +73,7 @@ func (h *redisHelper) Get(ctx context.Context, key string, value interface{}) (e
 	if err != nil {
 		return err
 	}
-	err = json.Unmarshal([]byte(data), &value)
+	err = DecodeValue([]byte(data), &value)
 	if err != nil {
 		return err
 	}
@@ -73,7 +81,7 @@ func (h *redisHelper) Get(ctx context.Context, key string, value interface{}) (e
 }
 
 func (h *redisHelper) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) (err error) {
-	data, err := json.Marshal(value)
+	data, err := h.values.encode(value)
 	if err != nil {
 		return err
 	}
@@ -102,12 +110,15 @@ func (h *redisHelper) Expire(ctx context.Context, key string, expiration time.Du
 }
 
 func (h *redisHelper) GetInterface(ctx context.Context, key string, value interface{}) (interface{}, error) {
-	var err error
 	data, err := h.client.Get(ctx, key).Result()
 	if err != nil {
 		return nil, err
 	}
+	return decodeInterface(data, value)
+}
 
+// decodeInterface decodes data into a new value of the type of value.
+func decodeInterface(data string, value interface{}) (interface{}, error) {
 	typeValue := reflect.TypeOf(value)
 	kind := typeValue.Kind()
 
@@ -118,7 +129,7 @@ func (h *redisHelper) GetInterface(ctx context.Context, key string, value interf
 	default:
 		outData = reflect.Zero(typeValue).Interface()
 	}
-	err = json.Unmarshal([]byte(data), &outData)
+	err := DecodeValue([]byte(data), &outData)
 	if err != nil {
 		return nil, err
 	}
@@ -157,18 +168,24 @@ func (h *redisHelper) GetKeysByPattern(ctx context.Context, pattern string, curs
 
 func (h *redisHelper) SubscribeMessage(ctx context.Context, keySpace string, subscribeFunc SubscribeFunc) {
 	subscribes := h.client.Subscribe(ctx, keySpace)
+	defer subscribes.Close()
 	messageChan := subscribes.Channel()
 	for {
-		message, ok := <-messageChan
-		if ok {
+		select {
+		case <-ctx.Done():
+			return
+		case message, ok := <-messageChan:
+			if !ok {
+				return
+			}
 			go func() {
-				_ = subscribeFunc(CacheMessage{Message: *message})
+				handleCacheMessage(subscribeFunc, CacheMessage{Message: *message})
 			}()
 		}
 	}
 }
 func (h *redisHelper) PublishMessage(ctx context.Context, keySpace string, message interface{}) error {
-	result := h.client.Publish(ctx, keySpace, message)
+	result := h.client.Publish(ctx, keySpace, withTraceId(ctx, message))
 	var out int64
 	var err error
 	if out, err = result.Result(); err != nil {
@@ -232,48 +249,39 @@ func (h *redisHelper) TimeExpire(ctx context.Context, key string) (time.Duration
 
 func (h *redisHelper) HSet(ctx context.Context, key, mapKey string, mapValue interface{}, expiration time.Duration) (isSet bool, err error) {
 	var (
-		marshalValue []byte
-		boolResult   *redis.BoolCmd
-		result       *redis.IntCmd
+		fieldValue string
+		boolResult *redis.BoolCmd
+		result     *redis.IntCmd
 	)
-	if _, isString := mapValue.(string); !isString {
-		marshalValue, err = json.Marshal(mapValue)
-		if err != nil {
-			return isSet, err
-		}
+	if fieldValue, err = h.values.encodeField(mapValue); err != nil {
+		return isSet, err
 	}
 
-	result = h.client.HSet(ctx, key, mapKey, string(marshalValue))
-	if result.Err() != nil {
+	// HSET reports the number of new fields, updating an existing field is a successful set too
+	result = h.client.HSet(ctx, key, mapKey, fieldValue)
+	if err = result.Err(); err != nil {
 		return isSet, err
 	}
-	if isSet0, err := result.Result(); isSet0 == 0 || err != nil {
-		return isSet0 == 0, err
-	}
 
 	if expiration != time.Duration(0) {
 		boolResult = h.client.Expire(ctx, key, expiration)
-	}
-
-	if isSet, err = boolResult.Result(); !isSet || err != nil {
-		return isSet, err
+		if isSet, err = boolResult.Result(); !isSet || err != nil {
+			return isSet, err
+		}
 	}
 	return true, nil
 }
 
 func (h *redisHelper) HSetNX(ctx context.Context, key string, mapKey string, mapValue interface{}, expiration time.Duration) (isSet bool, err error) {
 	var (
-		marshalValue []byte
-		boolResult   *redis.BoolCmd
+		fieldValue string
+		boolResult *redis.BoolCmd
 	)
-	if _, isString := mapValue.(string); !isString {
-		marshalValue, err = json.Marshal(mapValue)
-		if err != nil {
-			return isSet, err
-		}
+	if fieldValue, err = h.values.encodeField(mapValue); err != nil {
+		return isSet, err
 	}
 
-	boolResult = h.client.HSetNX(ctx, key, mapKey, string(marshalValue))
+	boolResult = h.client.HSetNX(ctx, key, mapKey, fieldValue)
 	if isSet, err = boolResult.Result(); !isSet || err != nil {
 		return isSet, err
 	}
@@ -288,7 +296,7 @@ func (h *redisHelper) HSetNX(ctx context.Context, key string, mapKey string, map
 
 func (h *redisHelper) HGet(ctx context.Context, key, mapKey string) (value string, err error) {
 	result := h.client.HGet(ctx, key, mapKey)
-	if result.Err() != nil {
+	if err = result.Err(); err != nil {
 		return value, err
 	}
 	if value, err = result.Result(); err != nil {
@@ -299,7 +307,7 @@ func (h *redisHelper) HGet(ctx context.Context, key, mapKey string) (value strin
 
 func (h *redisHelper) HGetAll(ctx context.Context, key string, mapKeys []string) (values map[string]string, err error) {
 	result := h.client.HGetAll(ctx, key)
-	if result.Err() != nil {
+	if err = result.Err(); err != nil {
 		return values, err
 	}
 	if values, err = result.Result(); values == nil || err != nil {
@@ -310,7 +318,7 @@ func (h *redisHelper) HGetAll(ctx context.Context, key string, mapKeys []string)
 
 func (h *redisHelper) HIncreaseBy(ctx context.Context, key, mapKey string, increase int64) (isIncreased bool, value string, err error) {
 	result := h.client.HIncrBy(ctx, key, mapKey, increase)
-	if result.Err() != nil {
+	if err = result.Err(); err != nil {
 		return isIncreased, value, err
 	}
 	var (
@@ -325,26 +333,18 @@ func (h *redisHelper) HIncreaseBy(ctx context.Context, key, mapKey string, incre
 
 func (h *redisHelper) HMSet(ctx context.Context, key string, mapData map[string]interface{}, expiration time.Duration) (isSet bool, err error) {
 	var (
-		inputData    map[string]interface{} = make(map[string]interface{}, len(mapData))
-		marshalValue []byte
-		stringValue  string
-		isString     bool
+		inputData  map[string]interface{} = make(map[string]interface{}, len(mapData))
+		fieldValue string
 	)
 
 	for key, value := range mapData {
-		if stringValue, isString = value.(string); !isString {
-			marshalValue, err = json.Marshal(value)
-			if err != nil {
-				return isSet, err
-			}
-		}
-		if stringValue == "" {
-			stringValue = string(marshalValue)
+		if fieldValue, err = h.values.encodeField(value); err != nil {
+			return isSet, err
 		}
-		inputData[key] = string(stringValue)
+		inputData[key] = fieldValue
 	}
 	result := h.client.HMSet(ctx, key, inputData)
-	if result.Err() != nil {
+	if err = result.Err(); err != nil {
 		return isSet, err
 	}
 	if ok, err := result.Result(); !ok || err != nil {
@@ -365,7 +365,7 @@ func (h *redisHelper) HMGet(ctx context.Context, key string, fields []string) (r
 		results []interface{}
 	)
 	sliceResult := h.client.HMGet(ctx, key, fields...)
-	if sliceResult.Err() != nil {
+	if err = sliceResult.Err(); err != nil {
 		return result, err
 	}
 
@@ -0,0 +1,80 @@
+package cachehelper
+
+import (
+	"strings"
+)
+
+// ClusterSlots is the number of hash slots of a Redis Cluster.
+const ClusterSlots = 16384
+
+// HashSlot returns the cluster slot of key, only the hash tag is hashed when key has one.
+func HashSlot(key string) int {
+	return int(crc16(hashTag(key)) % ClusterSlots)
+}
+
+// HashTagKey prefixes key with the hash tag {tag}, keys sharing a tag live in the same slot so they can be used
+// together in MGET, transactions or RENAME.
+func HashTagKey(tag, key string) string {
+	return "{" + tag + "}:" + key
+}
+
+// SameSlot reports whether all keys hash to the same slot.
+func SameSlot(keys ...string) bool {
+	for _, key := range keys {
+		if HashSlot(key) != HashSlot(keys[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+// hashTag returns the part of key between the first `{` and the next `}` when it isn't empty, key otherwise.
+func hashTag(key string) string {
+	start := strings.IndexByte(key, '{')
+	if start < 0 {
+		return key
+	}
+	end := strings.IndexByte(key[start+1:], '}')
+	if end <= 0 {
+		return key
+	}
+	return key[start+1 : start+1+end]
+}
+
+// groupBySlot groups the indexes of keys by their slot, keeping the order of the slots.
+func groupBySlot(keys []string) (slots []int, indexes map[int][]int) {
+	indexes = map[int][]int{}
+	for i, key := range keys {
+		slot := HashSlot(key)
+		if _, exists := indexes[slot]; !exists {
+			slots = append(slots, slot)
+		}
+		indexes[slot] = append(indexes[slot], i)
+	}
+	return slots, indexes
+}
+
+// slotKeys returns the keys at indexes, e.g. the keys of a slot given by groupBySlot.
+func slotKeys(keys []string, indexes []int) []string {
+	result := make([]string, 0, len(indexes))
+	for _, index := range indexes {
+		result = append(result, keys[index])
+	}
+	return result
+}
+
+// crc16 is the CRC16-CCITT (XMODEM) checksum used by Redis Cluster.
+func crc16(data string) uint16 {
+	crc := uint16(0)
+	for i := 0; i < len(data); i++ {
+		crc ^= uint16(data[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,140 @@
+package cachehelper
+
+import (
+	"context"
+	"errors"
+	"go-clean-arch/helper-libs/redisclienthelper"
+	"sort"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestRedisClusterHelper returns a cluster client over two miniredis masters splitting the slots in halves.
+func newTestRedisClusterHelper(t *testing.T) (*redisclienthelper.RedisClientHelper, []*miniredis.Miniredis) {
+	t.Helper()
+	servers := []*miniredis.Miniredis{miniredis.RunT(t), miniredis.RunT(t)}
+	client := redis.NewClusterClient(&redis.ClusterOptions{
+		ClusterSlots: func(ctx context.Context) ([]redis.ClusterSlot, error) {
+			return []redis.ClusterSlot{
+				{Start: 0, End: ClusterSlots/2 - 1, Nodes: []redis.ClusterNode{{Addr: servers[0].Addr()}}},
+				{Start: ClusterSlots / 2, End: ClusterSlots - 1, Nodes: []redis.ClusterNode{{Addr: servers[1].Addr()}}},
+			}, nil
+		},
+	})
+	t.Cleanup(func() { client.Close() })
+	return &redisclienthelper.RedisClientHelper{ClusterClient: client}, servers
+}
+
+func TestHashSlot(t *testing.T) {
+	tests := []struct {
+		key  string
+		slot int
+	}{
+		{key: "foo", slot: 12182},
+		{key: "123456789", slot: 0x31C3},
+		{key: "{user1000}.following", slot: HashSlot("user1000")},
+		{key: "{}.empty-tag", slot: int(crc16("{}.empty-tag") % ClusterSlots)},
+		{key: "{unclosed", slot: int(crc16("{unclosed") % ClusterSlots)},
+		{key: HashTagKey("order:1", "items"), slot: HashSlot("order:1")},
+	}
+	for _, tt := range tests {
+		if slot := HashSlot(tt.key); slot != tt.slot {
+			t.Errorf("expected slot %d for %s, actual: %d", tt.slot, tt.key, slot)
+		}
+	}
+	if !SameSlot(HashTagKey("order:1", "items"), HashTagKey("order:1", "total")) || SameSlot("foo", "bar") {
+		t.Error("expected tagged keys to share a slot and foo and bar not to")
+	}
+}
+
+func TestClusterMultiKeyCommands(t *testing.T) {
+	redisClient, servers := newTestRedisClusterHelper(t)
+	cache := NewCacheHelper(&CacheConfigOptions{RedisClientHelper: redisClient})
+	ctx := context.Background()
+
+	// foo and bar live on different masters
+	keys := []string{"foo", "bar", "missing", HashTagKey("foo", "1")}
+	for _, key := range []string{"foo", "bar", HashTagKey("foo", "1")} {
+		if err := cache.Set(ctx, key, key, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !servers[0].Exists("bar") || !servers[1].Exists("foo") {
+		t.Fatal("expected the keys to be spread over both masters")
+	}
+
+	values, err := cache.GetMulti(ctx, nil, keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 4 || values[0] != `"foo"` || values[1] != `"bar"` || values[2] != nil || values[3] != `"{foo}:1"` {
+		t.Errorf("expected the values in the order of the keys, actual: %v", values)
+	}
+
+	var scanned []string
+	for cursor := uint64(0); ; {
+		page, next, err := cache.GetKeysByPattern(ctx, "*", cursor, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanned = append(scanned, page...)
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+	sort.Strings(scanned)
+	if len(scanned) != 3 || scanned[0] != "bar" || scanned[2] != "{foo}:1" {
+		t.Errorf("expected the keys of both masters, actual: %v", scanned)
+	}
+
+	if err := cache.DelMulti(ctx, keys...); err != nil {
+		t.Fatal(err)
+	}
+	if len(servers[0].Keys()) != 0 || len(servers[1].Keys()) != 0 {
+		t.Errorf("expected every key to be deleted, actual: %v %v", servers[0].Keys(), servers[1].Keys())
+	}
+}
+
+func TestClusterPipelineMultiKeyCommands(t *testing.T) {
+	redisClient, servers := newTestRedisClusterHelper(t)
+	cache := NewCacheHelper(&CacheConfigOptions{RedisClientHelper: redisClient}).(CacheHelperEnhancement)
+	ctx := context.Background()
+
+	// foo and bar live on different masters
+	keys := []string{"foo", "bar", "missing", HashTagKey("foo", "1")}
+	pipeline := cache.NewPipeline(ctx)
+	for _, key := range []string{"foo", "bar", HashTagKey("foo", "1")} {
+		pipeline.Set(ctx, key, key, 0)
+	}
+	mget := pipeline.MGet(ctx, keys...)
+	if err := pipeline.Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+	values := mget.Val()
+	if len(values) != 4 || values[0] != `"foo"` || values[1] != `"bar"` || values[2] != nil || values[3] != `"{foo}:1"` {
+		t.Errorf("expected the values in the order of the keys, actual: %v", values)
+	}
+
+	transaction := cache.NewTransaction(ctx)
+	crossSlotGet := transaction.MGet(ctx, "foo", "bar")
+	crossSlotDel := transaction.Del(ctx, "foo", "bar")
+	sameSlotDel := transaction.Del(ctx, "foo", HashTagKey("foo", "1"))
+	if err := transaction.Exec(ctx); !errors.Is(err, ErrCrossSlot) {
+		t.Errorf("expected %v, actual: %v", ErrCrossSlot, err)
+	}
+	if !errors.Is(crossSlotGet.Err(), ErrCrossSlot) || !errors.Is(crossSlotDel.Err(), ErrCrossSlot) || sameSlotDel.Val() != 2 {
+		t.Errorf("expected the cross slot commands to fail and the others to run, actual: %v, %v, %d",
+			crossSlotGet.Err(), crossSlotDel.Err(), sameSlotDel.Val())
+	}
+
+	pipeline = cache.NewPipeline(ctx)
+	del := pipeline.Del(ctx, keys...)
+	if err := pipeline.Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if del.Val() != 1 || len(servers[0].Keys()) != 0 || len(servers[1].Keys()) != 0 {
+		t.Errorf("expected the last key to be deleted, actual: %d, %v %v", del.Val(), servers[0].Keys(), servers[1].Keys())
+	}
+}
@@ -0,0 +1,397 @@
+package cachehelper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-clean-arch/helper-libs/commonhelper"
+	"go-clean-arch/helper-libs/loghelper"
+	"go-clean-arch/helper-libs/redisclienthelper"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const (
+	streamField_Payload  = "payload"
+	streamField_Stream   = "stream"
+	streamField_Id       = "id"
+	streamField_Error    = "error"
+	streamField_Delivery = "deliveries"
+
+	defaultStreamMaxDeliveries = 5
+	defaultStreamMinIdle       = 30 * time.Second
+	defaultStreamBlock         = 2 * time.Second
+	defaultStreamCount         = 10
+	defaultDeadLetterSuffix    = ":dlq"
+	streamRetryInterval        = time.Second
+)
+
+type (
+	StreamOptions struct {
+		RedisClientHelper *redisclienthelper.RedisClientHelper
+		// Group is the consumer group, replicas of a service share it so each message is handled once.
+		Group string
+		// Consumer names this replica in the group, it falls back to the hostname. It must be stable across restarts
+		// and unique per replica, so a restarted replica takes its pending messages back.
+		Consumer string
+		// MaxDeliveries is the number of attempts before a message is moved to the dead-letter stream,
+		// it falls back to 5.
+		MaxDeliveries int64
+		// MinIdle is how long a message stays pending before another consumer reclaims it, it falls back to 30s.
+		// It must be longer than the handlers take.
+		MinIdle time.Duration
+		// Block bounds a read waiting for new messages and so the time Consume takes to return, it falls back to 2s.
+		Block time.Duration
+		// Count is the number of messages read at once, it falls back to 10.
+		Count int64
+		// MaxLen trims the streams approximately on publish, zero keeps every message.
+		MaxLen int64
+		// DeadLetterSuffix is appended to the stream name to name its dead-letter stream, it falls back to `:dlq`.
+		DeadLetterSuffix string
+	}
+
+	// StreamMessage is a message of a stream delivered to a consumer group.
+	StreamMessage struct {
+		Id      string
+		Stream  string
+		Payload string
+		// TraceId of the publisher when the payload is a JSON object carrying it
+		TraceId string
+		// Deliveries counts the attempts including this one
+		Deliveries int64
+	}
+
+	// StreamHandler acknowledges the message by returning nil. An error leaves it pending, it is redelivered once
+	// MinIdle is over until MaxDeliveries is reached, then it is moved to the dead-letter stream.
+	StreamHandler func(ctx context.Context, message StreamMessage) error
+
+	// StreamHelper is a reliable alternative to SubscribeMessage based on Redis Streams consumer groups: messages
+	// published while no consumer runs are kept and messages of crashed consumers are reclaimed.
+	StreamHelper interface {
+		// Publish appends message to stream and returns its id. Strings and bytes are sent as they are, other
+		// values as JSON.
+		Publish(ctx context.Context, stream string, message interface{}) (string, error)
+		// Consume creates the group when missing then handles the messages of stream until ctx is done.
+		Consume(ctx context.Context, stream string, handler StreamHandler) error
+		// Ack acknowledges messages handled outside of Consume.
+		Ack(ctx context.Context, stream string, ids ...string) error
+		// DeadLetterStream returns the name of the dead-letter stream of stream.
+		DeadLetterStream(stream string) string
+	}
+
+	streamHelper struct {
+		client           redis.UniversalClient
+		group            string
+		consumer         string
+		maxDeliveries    int64
+		minIdle          time.Duration
+		block            time.Duration
+		count            int64
+		maxLen           int64
+		deadLetterSuffix string
+	}
+)
+
+func NewStreamHelper(opts *StreamOptions) StreamHelper {
+	if opts.RedisClientHelper == nil || (opts.RedisClientHelper.ClusterClient == nil && opts.RedisClientHelper.Client == nil) {
+		loghelper.Logger.Panic("redis client must specific")
+	}
+	if opts.Group == "" {
+		loghelper.Logger.Panic("stream group must specific")
+	}
+	h := &streamHelper{
+		group:            opts.Group,
+		consumer:         opts.Consumer,
+		maxDeliveries:    opts.MaxDeliveries,
+		minIdle:          opts.MinIdle,
+		block:            opts.Block,
+		count:            opts.Count,
+		maxLen:           opts.MaxLen,
+		deadLetterSuffix: opts.DeadLetterSuffix,
+	}
+	if opts.RedisClientHelper.ClusterClient != nil {
+		h.client = opts.RedisClientHelper.ClusterClient
+	} else {
+		h.client = opts.RedisClientHelper.Client
+	}
+	if h.consumer == "" {
+		h.consumer, _ = os.Hostname()
+	}
+	if h.maxDeliveries <= 0 {
+		h.maxDeliveries = defaultStreamMaxDeliveries
+	}
+	if h.minIdle <= 0 {
+		h.minIdle = defaultStreamMinIdle
+	}
+	if h.block <= 0 {
+		h.block = defaultStreamBlock
+	}
+	if h.count <= 0 {
+		h.count = defaultStreamCount
+	}
+	if h.deadLetterSuffix == "" {
+		h.deadLetterSuffix = defaultDeadLetterSuffix
+	}
+	return h
+}
+
+func (h *streamHelper) Publish(ctx context.Context, stream string, message interface{}) (string, error) {
+	payload, err := streamPayload(withTraceId(ctx, message))
+	if err != nil {
+		return "", err
+	}
+	return h.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: stream,
+		MaxLen: h.maxLen,
+		Approx: h.maxLen > 0,
+		Values: []interface{}{streamField_Payload, payload},
+	}).Result()
+}
+
+func (h *streamHelper) Consume(ctx context.Context, stream string, handler StreamHandler) error {
+	err := h.client.XGroupCreateMkStream(ctx, stream, h.group, "0").Err()
+	if err != nil && !isBusyGroup(err) {
+		return err
+	}
+
+	if err := h.resume(ctx, stream, handler); err != nil && ctx.Err() == nil {
+		loghelper.Logger.Warnw("failed to resume pending stream messages", zap.String("stream", stream), zap.Error(err))
+	}
+
+	lastClaim := time.Time{}
+	for ctx.Err() == nil {
+		// Reclaiming at MinIdle pace is enough since younger pending messages can't be claimed anyway
+		if time.Since(lastClaim) >= h.minIdle {
+			lastClaim = time.Now()
+			if err := h.reclaim(ctx, stream, handler); err != nil && ctx.Err() == nil {
+				loghelper.Logger.Warnw("failed to reclaim stream messages", zap.String("stream", stream), zap.Error(err))
+			}
+		}
+
+		streams, err := h.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    h.group,
+			Consumer: h.consumer,
+			Streams:  []string{stream, ">"},
+			Count:    h.count,
+			Block:    h.block,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			loghelper.Logger.Warnw("failed to read stream, retry", zap.String("stream", stream), zap.Error(err))
+			select {
+			case <-ctx.Done():
+			case <-time.After(streamRetryInterval):
+			}
+			continue
+		}
+		for _, result := range streams {
+			for _, message := range result.Messages {
+				h.handle(ctx, stream, message, 1, handler)
+			}
+		}
+	}
+	return nil
+}
+
+func (h *streamHelper) Ack(ctx context.Context, stream string, ids ...string) error {
+	return h.client.XAck(ctx, stream, h.group, ids...).Err()
+}
+
+func (h *streamHelper) DeadLetterStream(stream string) string {
+	return stream + h.deadLetterSuffix
+}
+
+// resume handles the messages left pending by a previous run of this consumer, e.g. before a restart, without
+// waiting for MinIdle.
+func (h *streamHelper) resume(ctx context.Context, stream string, handler StreamHandler) error {
+	start := "0"
+	for ctx.Err() == nil {
+		streams, err := h.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    h.group,
+			Consumer: h.consumer,
+			Streams:  []string{stream, start},
+			Count:    h.count,
+			Block:    -1,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(streams) == 0 || len(streams[0].Messages) == 0 {
+			return nil
+		}
+		messages := streams[0].Messages
+		deliveries, err := h.deliveries(ctx, stream, messages)
+		if err != nil {
+			return err
+		}
+		for _, message := range messages {
+			h.handle(ctx, stream, message, deliveries[message.ID], handler)
+		}
+		start = messages[len(messages)-1].ID
+	}
+	return nil
+}
+
+// reclaim takes over the messages left pending longer than MinIdle, by a crashed consumer or a failed handler.
+func (h *streamHelper) reclaim(ctx context.Context, stream string, handler StreamHandler) error {
+	start := "0-0"
+	for {
+		messages, next, err := h.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   stream,
+			Group:    h.group,
+			Consumer: h.consumer,
+			MinIdle:  h.minIdle,
+			Start:    start,
+			Count:    h.count,
+		}).Result()
+		if err != nil {
+			return err
+		}
+		if len(messages) > 0 {
+			deliveries, err := h.deliveries(ctx, stream, messages)
+			if err != nil {
+				return err
+			}
+			for _, message := range messages {
+				h.handle(ctx, stream, message, deliveries[message.ID], handler)
+			}
+		}
+		if next == "0-0" || next == "" || ctx.Err() != nil {
+			return nil
+		}
+		start = next
+	}
+}
+
+// deliveries returns the delivery counts of the messages just claimed by this consumer.
+func (h *streamHelper) deliveries(ctx context.Context, stream string, messages []redis.XMessage) (map[string]int64, error) {
+	pending, err := h.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream:   stream,
+		Group:    h.group,
+		Start:    messages[0].ID,
+		End:      messages[len(messages)-1].ID,
+		Count:    int64(len(messages)),
+		Consumer: h.consumer,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	deliveries := make(map[string]int64, len(pending))
+	for _, entry := range pending {
+		deliveries[entry.ID] = entry.RetryCount
+	}
+	return deliveries, nil
+}
+
+func (h *streamHelper) handle(ctx context.Context, stream string, message redis.XMessage, deliveries int64, handler StreamHandler) {
+	// Entries deleted while pending are claimed without values, there is nothing left to handle
+	if message.Values == nil {
+		_ = h.Ack(ctx, stream, message.ID)
+		return
+	}
+	streamMessage := newStreamMessage(stream, message, deliveries)
+	logger := loghelper.Logger.WithContext(streamMessage.Context())
+	if deliveries > h.maxDeliveries {
+		h.deadLetter(ctx, streamMessage, errors.New("max deliveries exceeded"))
+		return
+	}
+
+	handlerCtx := ctx
+	if streamMessage.TraceId != "" {
+		handlerCtx = commonhelper.SetTraceIdToContext(ctx, streamMessage.TraceId)
+	}
+	err := handler(handlerCtx, streamMessage)
+	if err == nil {
+		if err := h.Ack(ctx, stream, message.ID); err != nil {
+			logger.Warnw("failed to ack stream message", zap.String("stream", stream), zap.String("id", message.ID), zap.Error(err))
+		}
+		return
+	}
+	logger.Warnw("failed to handle stream message", zap.String("stream", stream), zap.String("id", message.ID),
+		zap.Int64("deliveries", deliveries), zap.Error(err))
+	if deliveries >= h.maxDeliveries {
+		h.deadLetter(ctx, streamMessage, err)
+	}
+}
+
+// deadLetter moves message to the dead-letter stream, it stays pending when the move fails so it is retried.
+func (h *streamHelper) deadLetter(ctx context.Context, message StreamMessage, cause error) {
+	logger := loghelper.Logger.WithContext(message.Context())
+	err := h.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: h.DeadLetterStream(message.Stream),
+		MaxLen: h.maxLen,
+		Approx: h.maxLen > 0,
+		Values: []interface{}{
+			streamField_Payload, message.Payload,
+			streamField_Stream, message.Stream,
+			streamField_Id, message.Id,
+			streamField_Error, cause.Error(),
+			streamField_Delivery, strconv.FormatInt(message.Deliveries, 10),
+		},
+	}).Err()
+	if err == nil {
+		err = h.Ack(ctx, message.Stream, message.Id)
+	}
+	if err != nil {
+		logger.Errorw("failed to dead-letter stream message", zap.String("stream", message.Stream), zap.String("id", message.Id), zap.Error(err))
+		return
+	}
+	logger.Warnw("stream message dead-lettered", zap.String("stream", message.Stream), zap.String("id", message.Id), zap.Error(cause))
+}
+
+// Context returns a context carrying the traceId of the publisher so handlers can keep logging with it.
+func (m StreamMessage) Context() context.Context {
+	if m.TraceId == "" {
+		return context.Background()
+	}
+	return commonhelper.SetTraceIdToContext(context.Background(), m.TraceId)
+}
+
+// Decode unmarshals the JSON payload into value.
+func (m StreamMessage) Decode(value interface{}) error {
+	return json.Unmarshal([]byte(m.Payload), value)
+}
+
+func newStreamMessage(stream string, message redis.XMessage, deliveries int64) StreamMessage {
+	payload, _ := message.Values[streamField_Payload].(string)
+	traced := newCacheMessage(CacheMessage{Message: redis.Message{Payload: payload}})
+	return StreamMessage{
+		Id:         message.ID,
+		Stream:     stream,
+		Payload:    payload,
+		TraceId:    traced.TraceId,
+		Deliveries: deliveries,
+	}
+}
+
+func streamPayload(message interface{}) (string, error) {
+	switch v := message.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	}
+	data, err := json.Marshal(message)
+	if err != nil {
+		return "", fmt.Errorf("encode stream message: %w", err)
+	}
+	return string(data), nil
+}
+
+func isBusyGroup(err error) bool {
+	return strings.HasPrefix(err.Error(), "BUSYGROUP")
+}
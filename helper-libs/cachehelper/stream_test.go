@@ -0,0 +1,165 @@
+package cachehelper
+
+import (
+	"context"
+	"errors"
+	"go-clean-arch/helper-libs/commonhelper"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestStreamConsumeAcks(t *testing.T) {
+	redisClient, _ := newTestRedisClientHelper(t)
+	streams := NewStreamHelper(&StreamOptions{RedisClientHelper: redisClient, Group: "orders", Block: 10 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Messages published before the group exists are delivered too
+	traceCtx := commonhelper.SetTraceIdToContext(ctx, "trace-1")
+	if _, err := streams.Publish(traceCtx, "events", map[string]string{"event": "created"}); err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan StreamMessage, 1)
+	go streams.Consume(ctx, "events", func(ctx context.Context, message StreamMessage) error {
+		received <- message
+		return nil
+	})
+
+	select {
+	case message := <-received:
+		payload := map[string]string{}
+		if err := message.Decode(&payload); err != nil || payload["event"] != "created" {
+			t.Errorf("expected the created event, actual: %s, %v", message.Payload, err)
+		}
+		if message.TraceId != "trace-1" || message.Deliveries != 1 {
+			t.Errorf("expected trace-1 delivered once, actual: %+v", message)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a message")
+	}
+	waitFor(t, func() bool {
+		pending, err := redisClient.Client.XPending(ctx, "events", "orders").Result()
+		return err == nil && pending.Count == 0
+	})
+}
+
+func TestStreamDeadLettersAfterMaxDeliveries(t *testing.T) {
+	redisClient, _ := newTestRedisClientHelper(t)
+	streams := NewStreamHelper(&StreamOptions{
+		RedisClientHelper: redisClient,
+		Group:             "orders",
+		MaxDeliveries:     3,
+		MinIdle:           20 * time.Millisecond,
+		Block:             10 * time.Millisecond,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		mu         sync.Mutex
+		deliveries []int64
+	)
+	go streams.Consume(ctx, "events", func(ctx context.Context, message StreamMessage) error {
+		mu.Lock()
+		defer mu.Unlock()
+		deliveries = append(deliveries, message.Deliveries)
+		return errors.New("handler failed")
+	})
+	id, err := streams.Publish(ctx, "events", "poison")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var deadLetters []redis.XMessage
+	waitFor(t, func() bool {
+		deadLetters, _ = redisClient.Client.XRange(ctx, streams.DeadLetterStream("events"), "-", "+").Result()
+		return len(deadLetters) == 1
+	})
+	values := deadLetters[0].Values
+	if values["payload"] != "poison" || values["id"] != id || values["error"] != "handler failed" || values["deliveries"] != "3" {
+		t.Errorf("expected the poison message with its error, actual: %v", values)
+	}
+	mu.Lock()
+	if len(deliveries) != 3 || deliveries[0] != 1 || deliveries[2] != 3 {
+		t.Errorf("expected 3 deliveries, actual: %v", deliveries)
+	}
+	mu.Unlock()
+	if pending, _ := redisClient.Client.XPending(ctx, "events", "orders").Result(); pending.Count != 0 {
+		t.Errorf("expected the dead-lettered message to be acked, actual: %d pending", pending.Count)
+	}
+}
+
+func TestStreamReclaimsMessagesOfCrashedConsumers(t *testing.T) {
+	redisClient, _ := newTestRedisClientHelper(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	options := StreamOptions{RedisClientHelper: redisClient, Group: "orders", MinIdle: 20 * time.Millisecond, Block: 10 * time.Millisecond}
+
+	// A replica reads the message then dies before acking it
+	if err := redisClient.Client.XGroupCreateMkStream(ctx, "events", "orders", "0").Err(); err != nil {
+		t.Fatal(err)
+	}
+	streams := NewStreamHelper(&options)
+	if _, err := streams.Publish(ctx, "events", "order-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := redisClient.Client.XReadGroup(ctx, &redis.XReadGroupArgs{Group: "orders", Consumer: "crashed", Streams: []string{"events", ">"}}).Result(); err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan StreamMessage, 1)
+	go streams.Consume(ctx, "events", func(ctx context.Context, message StreamMessage) error {
+		received <- message
+		return nil
+	})
+	select {
+	case message := <-received:
+		if message.Payload != "order-1" || message.Deliveries != 2 {
+			t.Errorf("expected order-1 delivered twice, actual: %+v", message)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the pending message to be reclaimed")
+	}
+}
+
+func TestStreamResumesPendingMessagesOnRestart(t *testing.T) {
+	redisClient, _ := newTestRedisClientHelper(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	options := StreamOptions{RedisClientHelper: redisClient, Group: "orders", Block: 10 * time.Millisecond}
+	hostname, _ := os.Hostname()
+	if consumer := NewStreamHelper(&options).(*streamHelper).consumer; consumer != hostname {
+		t.Errorf("expected the hostname as consumer, actual: %s", consumer)
+	}
+
+	// The replica reads the message then restarts before acking it
+	options.Consumer = "replica-1"
+	if err := redisClient.Client.XGroupCreateMkStream(ctx, "events", "orders", "0").Err(); err != nil {
+		t.Fatal(err)
+	}
+	streams := NewStreamHelper(&options)
+	if _, err := streams.Publish(ctx, "events", "order-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := redisClient.Client.XReadGroup(ctx, &redis.XReadGroupArgs{Group: "orders", Consumer: "replica-1", Streams: []string{"events", ">"}}).Result(); err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan StreamMessage, 1)
+	go streams.Consume(ctx, "events", func(ctx context.Context, message StreamMessage) error {
+		received <- message
+		return nil
+	})
+	select {
+	case message := <-received:
+		if message.Payload != "order-1" || message.Deliveries != 2 {
+			t.Errorf("expected order-1 delivered twice, actual: %+v", message)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the pending message to be resumed before MinIdle")
+	}
+}
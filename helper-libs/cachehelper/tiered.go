@@ -0,0 +1,250 @@
+package cachehelper
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"go-clean-arch/helper-libs/loghelper"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const (
+	CacheTier_Local CacheTier = "local"
+	CacheTier_Redis CacheTier = "redis"
+
+	cacheResult_Hit  = "hit"
+	cacheResult_Miss = "miss"
+
+	defaultTieredName         = "default"
+	defaultTieredSize         = 10000
+	defaultTieredLocalTTL     = time.Minute
+	defaultTieredChannel      = "cache:invalidation"
+	tieredResubscribeInterval = time.Second
+)
+
+var cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "cache_requests_total",
+	Help: "Lookups of the tiered cache helpers by tier and result.",
+}, []string{"cache", "tier", "result"})
+
+func init() {
+	prometheus.MustRegister(cacheRequests)
+}
+
+type (
+	CacheTier string
+
+	TieredCacheOptions struct {
+		// CacheHelper is the shared tier, usually the redis helper of NewCacheHelper.
+		CacheHelper CacheHelper
+		// Name labels the metrics of this cache, it falls back to `default`.
+		Name string
+		// Size bounds the number of local entries, it falls back to 10000.
+		Size int
+		// LocalTTL bounds how long a local entry outlives a missed invalidation, it falls back to 1m.
+		LocalTTL time.Duration
+		// Channel the invalidations are broadcast on, it falls back to `cache:invalidation`.
+		// Replicas sharing keys must share the channel.
+		Channel string
+	}
+
+	// TieredCacheHelper is a CacheHelper keeping the values read by Get in a bounded in-process LRU in front of the
+	// shared tier. Writes go to the shared tier and are broadcast so every replica evicts its local copy.
+	// Only Get and Exists are served locally, the other reads always hit the shared tier.
+	TieredCacheHelper interface {
+		CacheHelper
+		// Close stops listening to the invalidations of other replicas.
+		Close() error
+	}
+
+	cacheInvalidation struct {
+		Origin string   `json:"origin"`
+		Keys   []string `json:"keys"`
+	}
+
+	tieredCacheHelper struct {
+		CacheHelper
+		name     string
+		channel  string
+		instance string
+		local    *expirable.LRU[string, []byte]
+		// generation changes on every local eviction, a value read from the shared tier is only kept locally
+		// when no eviction happened during the read, so an invalidation racing the read isn't lost
+		generation atomic.Uint64
+		cancel     context.CancelFunc
+		done       chan struct{}
+		closeOnce  sync.Once
+	}
+)
+
+// NewTieredCacheHelper starts listening to the invalidations of other replicas until Close is called.
+func NewTieredCacheHelper(opts *TieredCacheOptions) TieredCacheHelper {
+	if opts.CacheHelper == nil {
+		loghelper.Logger.Panic("cache helper must specific")
+	}
+	h := &tieredCacheHelper{
+		CacheHelper: opts.CacheHelper,
+		name:        opts.Name,
+		channel:     opts.Channel,
+		instance:    newInstanceId(),
+		done:        make(chan struct{}),
+	}
+	if h.name == "" {
+		h.name = defaultTieredName
+	}
+	if h.channel == "" {
+		h.channel = defaultTieredChannel
+	}
+	size, localTTL := opts.Size, opts.LocalTTL
+	if size <= 0 {
+		size = defaultTieredSize
+	}
+	if localTTL <= 0 {
+		localTTL = defaultTieredLocalTTL
+	}
+	h.local = expirable.NewLRU[string, []byte](size, nil, localTTL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h.cancel = cancel
+	go h.subscribe(ctx)
+	return h
+}
+
+func (h *tieredCacheHelper) Exists(ctx context.Context, key string) error {
+	if h.local.Contains(key) {
+		return nil
+	}
+	return h.CacheHelper.Exists(ctx, key)
+}
+
+func (h *tieredCacheHelper) Get(ctx context.Context, key string, value interface{}) error {
+	if data, ok := h.local.Get(key); ok {
+		h.observe(CacheTier_Local, cacheResult_Hit)
+		return DecodeValue(data, &value)
+	}
+	h.observe(CacheTier_Local, cacheResult_Miss)
+
+	generation := h.generation.Load()
+	// The stored bytes are kept locally, so every read decodes its own copy whatever the codec
+	var data rawValue
+	if err := h.CacheHelper.Get(ctx, key, &data); err != nil {
+		if errors.Is(err, redis.Nil) {
+			h.observe(CacheTier_Redis, cacheResult_Miss)
+		}
+		return err
+	}
+	h.observe(CacheTier_Redis, cacheResult_Hit)
+	if h.generation.Load() == generation {
+		h.local.Add(key, data)
+	}
+	return DecodeValue(data, &value)
+}
+
+func (h *tieredCacheHelper) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	defer h.invalidate(ctx, key)
+	return h.CacheHelper.Set(ctx, key, value, expiration)
+}
+
+func (h *tieredCacheHelper) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	isSet, err := h.CacheHelper.SetNX(ctx, key, value, expiration)
+	if isSet {
+		h.invalidate(ctx, key)
+	}
+	return isSet, err
+}
+
+func (h *tieredCacheHelper) Del(ctx context.Context, key string) error {
+	defer h.invalidate(ctx, key)
+	return h.CacheHelper.Del(ctx, key)
+}
+
+func (h *tieredCacheHelper) Expire(ctx context.Context, key string, expiration time.Duration) error {
+	defer h.invalidate(ctx, key)
+	return h.CacheHelper.Expire(ctx, key, expiration)
+}
+
+func (h *tieredCacheHelper) DelMulti(ctx context.Context, keys ...string) error {
+	defer h.invalidate(ctx, keys...)
+	return h.CacheHelper.DelMulti(ctx, keys...)
+}
+
+func (h *tieredCacheHelper) RenameKey(ctx context.Context, oldKey, newKey string) error {
+	defer h.invalidate(ctx, oldKey, newKey)
+	return h.CacheHelper.RenameKey(ctx, oldKey, newKey)
+}
+
+func (h *tieredCacheHelper) Close() error {
+	h.closeOnce.Do(func() {
+		h.cancel()
+		<-h.done
+		h.local.Purge()
+	})
+	return nil
+}
+
+// invalidate evicts keys locally and broadcasts them, a failed broadcast leaves other replicas stale up to LocalTTL.
+func (h *tieredCacheHelper) invalidate(ctx context.Context, keys ...string) {
+	h.evict(keys...)
+	message, err := json.Marshal(&cacheInvalidation{Origin: h.instance, Keys: keys})
+	if err == nil {
+		err = h.CacheHelper.PublishMessage(ctx, h.channel, string(message))
+	}
+	if err != nil {
+		loghelper.Logger.WithContext(ctx).Warnw("broadcast cache invalidation failed", zap.Strings("keys", keys), zap.Error(err))
+	}
+}
+
+func (h *tieredCacheHelper) evict(keys ...string) {
+	h.generation.Add(1)
+	for _, key := range keys {
+		h.local.Remove(key)
+	}
+}
+
+// subscribe resubscribes until ctx is done, the local tier is purged on every subscription since invalidations may
+// have been missed in between.
+func (h *tieredCacheHelper) subscribe(ctx context.Context) {
+	defer close(h.done)
+	for ctx.Err() == nil {
+		h.generation.Add(1)
+		h.local.Purge()
+		h.CacheHelper.SubscribeMessage(ctx, h.channel, h.onInvalidation)
+		select {
+		case <-ctx.Done():
+		case <-time.After(tieredResubscribeInterval):
+			loghelper.Logger.Warnw("cache invalidation subscription closed, resubscribe", zap.String("channel", h.channel))
+		}
+	}
+}
+
+func (h *tieredCacheHelper) onInvalidation(message CacheMessage) error {
+	invalidation := &cacheInvalidation{}
+	if err := json.Unmarshal([]byte(message.Payload), invalidation); err != nil {
+		loghelper.Logger.WithContext(message.Context()).Warnw("invalid cache invalidation", zap.String("payload", message.Payload), zap.Error(err))
+		return err
+	}
+	if invalidation.Origin == h.instance {
+		return nil
+	}
+	h.evict(invalidation.Keys...)
+	return nil
+}
+
+func (h *tieredCacheHelper) observe(tier CacheTier, result string) {
+	cacheRequests.WithLabelValues(h.name, string(tier), result).Inc()
+}
+
+func newInstanceId() string {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,68 @@
+package cachehelper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestTieredCacheHelper(t *testing.T) {
+	redisClient, server := newTestRedisClientHelper(t)
+	shared := NewCacheHelper(&CacheConfigOptions{RedisClientHelper: redisClient})
+	// Two helpers sharing redis stand for two replicas
+	replicaA := NewTieredCacheHelper(&TieredCacheOptions{CacheHelper: shared, Name: "tiered-test"})
+	defer replicaA.Close()
+	replicaB := NewTieredCacheHelper(&TieredCacheOptions{CacheHelper: shared, Name: "tiered-test"})
+	defer replicaB.Close()
+	waitFor(t, func() bool { return server.PubSubNumSub(defaultTieredChannel)[defaultTieredChannel] == 2 })
+
+	localHits := cacheRequests.WithLabelValues("tiered-test", string(CacheTier_Local), cacheResult_Hit)
+	redisHits := cacheRequests.WithLabelValues("tiered-test", string(CacheTier_Redis), cacheResult_Hit)
+	localHitsBefore, redisHitsBefore := testutil.ToFloat64(localHits), testutil.ToFloat64(redisHits)
+
+	ctx := context.Background()
+	if err := replicaA.Set(ctx, "merchant:1", loaderTestUser{Id: "1", Name: "Alice"}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	// Reads racing an invalidation aren't kept locally, wait for the broadcast of the write to reach B
+	waitFor(t, func() bool { return replicaB.(*tieredCacheHelper).generation.Load() > 1 })
+	for i := 0; i < 2; i++ {
+		user := loaderTestUser{}
+		if err := replicaB.Get(ctx, "merchant:1", &user); err != nil || user.Name != "Alice" {
+			t.Fatalf("expected Alice, actual: %+v, %v", user, err)
+		}
+	}
+	if hits := testutil.ToFloat64(localHits) - localHitsBefore; hits != 1 {
+		t.Errorf("expected the second read to hit the local tier, actual hits: %v", hits)
+	}
+	if hits := testutil.ToFloat64(redisHits) - redisHitsBefore; hits != 1 {
+		t.Errorf("expected the first read to hit redis, actual hits: %v", hits)
+	}
+
+	// A write on one replica evicts the local copy of the others
+	if err := replicaA.Set(ctx, "merchant:1", loaderTestUser{Id: "1", Name: "Bob"}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool {
+		user := loaderTestUser{}
+		return replicaB.Get(ctx, "merchant:1", &user) == nil && user.Name == "Bob"
+	})
+
+	if err := replicaA.Del(ctx, "merchant:1"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return replicaB.Exists(ctx, "merchant:1") != nil })
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,82 @@
+package cachehelper
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/json"
+	"go-clean-arch/helper-libs/commonhelper"
+	"time"
+)
+
+// withTraceId JSON encodes the messages the redis clients can't format, whether ctx has a traceId or not so
+// subscribers always receive the same payload, and adds the traceId of ctx to JSON objects. Strings, bytes, scalars
+// and BinaryMarshalers are published as they are.
+func withTraceId(ctx context.Context, message interface{}) interface{} {
+	var data []byte
+	switch v := message.(type) {
+	case nil, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64,
+		time.Time, time.Duration, encoding.BinaryMarshaler:
+		return message
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return message
+		}
+		data, message = encoded, string(encoded)
+	}
+
+	traceId := commonhelper.GetTraceIdFromContext(ctx)
+	if traceId == "" {
+		return message
+	}
+	if traced, ok := addTraceId(data, traceId); ok {
+		return traced
+	}
+	return message
+}
+
+// addTraceId appends the traceId field to the JSON object data, the other fields are kept as they are, numbers
+// and order included. It returns false when data isn't a JSON object.
+func addTraceId(data []byte, traceId string) (string, bool) {
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(data, &payload); err != nil || payload == nil {
+		return "", false
+	}
+	if _, exists := payload[string(commonhelper.PayloadTrackingField_TraceId)]; exists {
+		return string(data), true
+	}
+	field, err := json.Marshal(map[commonhelper.PayloadTrackingField]string{commonhelper.PayloadTrackingField_TraceId: traceId})
+	if err != nil {
+		return "", false
+	}
+	object := bytes.TrimSpace(data)
+	if len(payload) == 0 {
+		return string(field), true
+	}
+	// Both end with `}`, the field is spliced in place of the closing brace of the object
+	return string(object[:len(object)-1]) + "," + string(field[1:]), true
+}
+
+// newCacheMessage extracts the traceId published by withTraceId from the payload, if any.
+func newCacheMessage(message CacheMessage) CacheMessage {
+	var payload map[string]interface{}
+	if err := json.Unmarshal([]byte(message.Payload), &payload); err == nil {
+		if traceId, ok := payload[string(commonhelper.PayloadTrackingField_TraceId)].(string); ok {
+			message.TraceId = traceId
+		}
+	}
+	return message
+}
+
+// Context returns a context carrying the traceId of the publisher so handlers can keep logging with it.
+func (m CacheMessage) Context() context.Context {
+	if m.TraceId == "" {
+		return context.Background()
+	}
+	return commonhelper.SetTraceIdToContext(context.Background(), m.TraceId)
+}
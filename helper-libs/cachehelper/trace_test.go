@@ -0,0 +1,39 @@
+package cachehelper
+
+import (
+	"context"
+	"go-clean-arch/helper-libs/commonhelper"
+	"testing"
+)
+
+func TestWithTraceId(t *testing.T) {
+	type order struct {
+		Zone   string `json:"zone"`
+		Id     int64  `json:"id"`
+		Amount int64  `json:"amount"`
+	}
+	traceCtx := commonhelper.SetTraceIdToContext(context.Background(), "trace-1")
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		message  interface{}
+		expected interface{}
+	}{
+		{"struct without traceId", context.Background(), order{Zone: "b", Id: 9007199254740993, Amount: 1},
+			`{"zone":"b","id":9007199254740993,"amount":1}`},
+		{"struct keeps order and precision", traceCtx, order{Zone: "b", Id: 9007199254740993, Amount: 1},
+			`{"zone":"b","id":9007199254740993,"amount":1,"traceId":"trace-1"}`},
+		{"json string", traceCtx, ` {"b":1.50,"a":2} `, `{"b":1.50,"a":2,"traceId":"trace-1"}`},
+		{"empty object", traceCtx, []byte(`{}`), `{"traceId":"trace-1"}`},
+		{"traceId already set", traceCtx, `{"traceId":"trace-0"}`, `{"traceId":"trace-0"}`},
+		{"not an object", traceCtx, `[1,2]`, `[1,2]`},
+		{"scalar", traceCtx, 42, 42},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := withTraceId(test.ctx, test.message); actual != test.expected {
+				t.Errorf("expected %v, actual: %v", test.expected, actual)
+			}
+		})
+	}
+}
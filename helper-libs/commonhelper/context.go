@@ -0,0 +1,17 @@
+package commonhelper
+
+import "context"
+
+// GetTraceIdFromContext returns the trace id of the request carried by ctx, or an empty string.
+func GetTraceIdFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	traceId, _ := ctx.Value(ContextKeyType_TraceId).(string)
+	return traceId
+}
+
+// SetTraceIdToContext returns a copy of ctx carrying traceId under ContextKeyType_TraceId.
+func SetTraceIdToContext(ctx context.Context, traceId string) context.Context {
+	return context.WithValue(ctx, ContextKeyType_TraceId, traceId)
+}
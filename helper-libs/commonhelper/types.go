@@ -10,6 +10,8 @@ const (
 	ContextKeyType_Subject    ContentKeyType = "Sub"
 	ContextKeyType_AppSubject ContentKeyType = "AppSub"
 	HeaderKeyType_Token       ContentKeyType = "Token"
+	ContextKeyType_GormTx     ContentKeyType = "GormTx"
+	ContextKeyType_SqlxTx     ContentKeyType = "SqlxTx"
 )
 
 type MetadataKeyType string
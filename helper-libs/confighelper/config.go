@@ -3,6 +3,7 @@ package confighelper
 import (
 	"bytes"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -24,31 +25,78 @@ func Load(cfgMap any, defaultConfig []byte) error {
 
 type (
 	ServerConfig struct {
-		RateLimit           int64  `mapstructure:"rate_limit"`
-		UseTls              bool   `mapstructure:"use_tls"`
-		TlsCertFile         string `mapstructure:"tls_cert_file"`
-		TlsKeyFile          string `mapstructure:"tls_key_file"`
-		TlsRootCACertFile   string `mapstructure:"tls_rootca_cert_file"`
-		TlsCertBase64       string `mapstructure:"tls_cert_base64"`
-		TlsKeyBase64        string `mapstructure:"tls_key_base64"`
-		TlsRootCACertBase64 string `mapstructure:"tls_rootca_cert_base64"`
-		InsecureSkipVerify  bool   `mapstructure:"insecure_skip_verify"`
-		UseCORs             bool   `mapstructure:"use_cors"`
+		// RateLimit is the number of requests allowed per RateLimitPeriod to each client, zero disables limiting.
+		RateLimit          int64         `mapstructure:"rate_limit"`
+		RateLimitAlgorithm string        `mapstructure:"rate_limit_algorithm"`
+		RateLimitPeriod    time.Duration `mapstructure:"rate_limit_period"`
+		RateLimitBurst     int64         `mapstructure:"rate_limit_burst"`
+		// RateLimitKeyBy identifies a client by `ip`, `subject` and/or `route`.
+		RateLimitKeyBy []string `mapstructure:"rate_limit_key_by"`
+		// TrustedProxies are the CIDRs of the proxies allowed to set X-Forwarded-For, without any the client IP
+		// is the address of the connection.
+		TrustedProxies      []string `mapstructure:"trusted_proxies"`
+		UseTls              bool     `mapstructure:"use_tls"`
+		TlsCertFile         string   `mapstructure:"tls_cert_file"`
+		TlsKeyFile          string   `mapstructure:"tls_key_file"`
+		TlsRootCACertFile   string   `mapstructure:"tls_rootca_cert_file"`
+		TlsCertBase64       string   `mapstructure:"tls_cert_base64"`
+		TlsKeyBase64        string   `mapstructure:"tls_key_base64" secret:"true"`
+		TlsRootCACertBase64 string   `mapstructure:"tls_rootca_cert_base64"`
+		InsecureSkipVerify  bool     `mapstructure:"insecure_skip_verify"`
+		// TlsClientAuth enables mTLS: none, request, require, verify_if_given or require_and_verify.
+		TlsClientAuth     string        `mapstructure:"tls_client_auth"`
+		UseCORs           bool          `mapstructure:"use_cors"`
+		Cors              CorsConfig    `mapstructure:"cors"`
+		ReadTimeout       time.Duration `mapstructure:"read_timeout"`
+		ReadHeaderTimeout time.Duration `mapstructure:"read_header_timeout"`
+		WriteTimeout      time.Duration `mapstructure:"write_timeout"`
+		IdleTimeout       time.Duration `mapstructure:"idle_timeout"`
+	}
+
+	CorsPolicy struct {
+		// Paths limits the policy to the matching paths, a trailing `*` matches a prefix.
+		Paths            []string `mapstructure:"paths"`
+		AllowOrigins     []string `mapstructure:"allow_origins"`
+		AllowMethods     []string `mapstructure:"allow_methods"`
+		AllowHeaders     []string `mapstructure:"allow_headers"`
+		ExposeHeaders    []string `mapstructure:"expose_headers"`
+		AllowCredentials bool     `mapstructure:"allow_credentials"`
+		// MaxAge in seconds the preflight response may be cached.
+		MaxAge int `mapstructure:"max_age"`
+	}
+
+	// CorsConfig applies the first policy matching the request path, the default policy otherwise.
+	CorsConfig struct {
+		CorsPolicy `mapstructure:",squash"`
+		Policies   []CorsPolicy `mapstructure:"policies"`
 	}
 
 	DefaultMerchantConfig struct {
 		Timezone string `mapstructure:"timezone"`
 	}
 
+	// BasicAuth is a credential entry, `password_hash` (bcrypt or `sha256:<hex>`) takes precedence over the plain `password`
+	// and `password_hash_env` names an environment variable overriding `password_hash`.
 	BasicAuth struct {
-		Username string `mapstructure:"username"`
-		Password string `mapstructure:"password"`
+		Username        string `mapstructure:"username"`
+		Password        string `mapstructure:"password" secret:"true"`
+		PasswordHash    string `mapstructure:"password_hash" secret:"true"`
+		PasswordHashEnv string `mapstructure:"password_hash_env"`
+	}
+
+	// BasicAuthGuardConfig protects `paths` with the inline credential and every entry of `credentials`.
+	BasicAuthGuardConfig struct {
+		BasicAuth   `mapstructure:",squash"`
+		Enabled     bool        `mapstructure:"enabled"`
+		Realm       string      `mapstructure:"realm"`
+		Paths       []string    `mapstructure:"paths"`
+		Credentials []BasicAuth `mapstructure:"credentials"`
 	}
 
 	ClientAuth struct {
 		ClientId     string `mapstructure:"client_id"`
-		ClientSecret string `mapstructure:"client_secret"`
-		Token        string `mapstructure:"token"`
+		ClientSecret string `mapstructure:"client_secret" secret:"true"`
+		Token        string `mapstructure:"token" secret:"true"`
 	}
 
 	SqlDatabaseConfig struct {
@@ -56,7 +104,7 @@ type (
 		Host                string   `mapstructure:"host"`
 		Port                int      `mapstructure:"port"`
 		Username            string   `mapstructure:"username"`
-		Password            string   `mapstructure:"password"`
+		Password            string   `mapstructure:"password" secret:"true"`
 		Database            string   `mapstructure:"database"`
 		Schema              string   `mapstructure:"schema"`
 		UseTls              bool     `mapstructure:"use_tls"`
@@ -65,7 +113,7 @@ type (
 		TlsKeyFile          string   `mapstructure:"tls_key_file"`
 		TlsCertFile         string   `mapstructure:"tls_cert_file"`
 		TlsRootCACertBase64 string   `mapstructure:"tls_rootca_cert_base64"`
-		TlsKeyBase64        string   `mapstructure:"tls_key_base64"`
+		TlsKeyBase64        string   `mapstructure:"tls_key_base64" secret:"true"`
 		TlsCertBase64       string   `mapstructure:"tls_cert_base64"`
 		InsecureSkipVerify  bool     `mapstructure:"insecure_skip_verify"`
 		MaxOpenConns        int      `mapstructure:"max_open_conns"`
@@ -79,13 +127,13 @@ type (
 		Host                string   `mapstructure:"host"`
 		Port                int      `mapstructure:"port"`
 		Username            string   `mapstructure:"username"`
-		Password            string   `mapstructure:"password"`
+		Password            string   `mapstructure:"password" secret:"true"`
 		UseTls              bool     `mapstructure:"use_tls"`
 		TlsRootCACertFile   string   `mapstructure:"tls_rootca_cert_file"`
 		TlsKeyFile          string   `mapstructure:"tls_key_file"`
 		TlsCertFile         string   `mapstructure:"tls_cert_file"`
 		TlsRootCACertBase64 string   `mapstructure:"tls_rootca_cert_base64"`
-		TlsKeyBase64        string   `mapstructure:"tls_key_base64"`
+		TlsKeyBase64        string   `mapstructure:"tls_key_base64" secret:"true"`
 		TlsCertBase64       string   `mapstructure:"tls_cert_base64"`
 		InsecureSkipVerify  bool     `mapstructure:"insecure_skip_verify"`
 	}
@@ -95,13 +143,13 @@ type (
 		Host                string   `mapstructure:"host"`
 		Port                int      `mapstructure:"port"`
 		Username            string   `mapstructure:"username"`
-		Password            string   `mapstructure:"password"`
+		Password            string   `mapstructure:"password" secret:"true"`
 		UseTls              bool     `mapstructure:"use_tls"`
 		TlsRootCACertFile   string   `mapstructure:"tls_rootca_cert_file"`
 		TlsKeyFile          string   `mapstructure:"tls_key_file"`
 		TlsCertFile         string   `mapstructure:"tls_cert_file"`
 		TlsRootCACertBase64 string   `mapstructure:"tls_rootca_cert_base64"`
-		TlsKeyBase64        string   `mapstructure:"tls_key_base64"`
+		TlsKeyBase64        string   `mapstructure:"tls_key_base64" secret:"true"`
 		TlsCertBase64       string   `mapstructure:"tls_cert_base64"`
 		InsecureSkipVerify  bool     `mapstructure:"insecure_skip_verify"`
 	}
@@ -111,14 +159,14 @@ type (
 		Host                string `mapstructure:"host"`
 		Port                string `mapstructure:"port"`
 		Username            string `mapstructure:"username"`
-		Password            string `mapstructure:"password"`
+		Password            string `mapstructure:"password" secret:"true"`
 		UseHttp2            bool   `mapstructure:"use_http2"`
 		UseTls              bool   `mapstructure:"use_tls"`
 		TlsCertFile         string `mapstructure:"tls_cert_file"`
 		TlsKeyFile          string `mapstructure:"tls_key_file"`
 		TlsRootCACertFile   string `mapstructure:"tls_rootca_cert_file"`
 		TlsCertBase64       string `mapstructure:"tls_cert_base64"`
-		TlsKeyBase64        string `mapstructure:"tls_key_base64"`
+		TlsKeyBase64        string `mapstructure:"tls_key_base64" secret:"true"`
 		TlsRootCACertBase64 string `mapstructure:"tls_rootca_cert_base64"`
 		InsecureSkipVerify  bool   `mapstructure:"insecure_skip_verify"`
 	}
@@ -129,17 +177,60 @@ type (
 		KeyFile            string `mapstructure:"key_file"`
 		RootCACertFile     string `mapstructure:"rootca_cert_file"`
 		CertBase64         string `mapstructure:"cert_base64"`
-		KeyBase64          string `mapstructure:"key_base64"`
+		KeyBase64          string `mapstructure:"key_base64" secret:"true"`
 		RootCACertBase64   string `mapstructure:"rootca_cert_base64"`
 		InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
 	}
 
+	// JwtConfig configures bearer token validation, keys come from `jwks_url` or else from a static public key or hmac secret.
+	JwtConfig struct {
+		Enabled             bool          `mapstructure:"enabled"`
+		Issuer              string        `mapstructure:"issuer"`
+		Audiences           []string      `mapstructure:"audiences"`
+		Algorithm           string        `mapstructure:"algorithm"`
+		JwksUrl             string        `mapstructure:"jwks_url"`
+		JwksRefreshInterval time.Duration `mapstructure:"jwks_refresh_interval"`
+		PublicKeyFile       string        `mapstructure:"public_key_file"`
+		PublicKeyBase64     string        `mapstructure:"public_key_base64"`
+		HmacSecret          string        `mapstructure:"hmac_secret" secret:"true"`
+		Leeway              time.Duration `mapstructure:"leeway"`
+		AppSubjectClaim     string        `mapstructure:"app_subject_claim"`
+	}
+
+	// ShutdownConfig bounds each stage of the graceful shutdown.
+	ShutdownConfig struct {
+		ReadinessDelay    time.Duration `mapstructure:"readiness_delay"`
+		HttpTimeout       time.Duration `mapstructure:"http_timeout"`
+		BackgroundTimeout time.Duration `mapstructure:"background_timeout"`
+		ResourcesTimeout  time.Duration `mapstructure:"resources_timeout"`
+	}
+
+	IdempotencyConfig struct {
+		Enabled bool `mapstructure:"enabled"`
+		// Store is `redis`, or `postgres` for long retention.
+		Store       string        `mapstructure:"store"`
+		Ttl         time.Duration `mapstructure:"ttl"`
+		LockTimeout time.Duration `mapstructure:"lock_timeout"`
+		LockWait    time.Duration `mapstructure:"lock_wait"`
+		Methods     []string      `mapstructure:"methods"`
+	}
+
 	WorkflowConfig struct {
-		Topic            string `mapstructure:"topic"`
-		RequestTimeout   int64  `mapstructure:"request_timeout"`
+		Topic          string `mapstructure:"topic"`
+		RequestTimeout int64  `mapstructure:"request_timeout"`
+		// ScheduleInterval is in seconds, ScheduleCron is a cron expression taking precedence over it.
 		ScheduleInterval int64  `mapstructure:"schedule_interval"`
-		ScheduleCron     int64  `mapstructure:"schedule_cron"`
+		ScheduleCron     string `mapstructure:"schedule_cron"`
 		MaxAttempt       int    `mapstructure:"max_attempt"`
 		MaxConcurrent    int    `mapstructure:"max_concurrent"`
 	}
+
+	SchedulerConfig struct {
+		Enabled bool `mapstructure:"enabled"`
+		// LeaderTtl is how long the leadership outlives a leader that stopped renewing.
+		LeaderTtl     time.Duration `mapstructure:"leader_ttl"`
+		MaxConcurrent int           `mapstructure:"max_concurrent"`
+		// HistoryRetention is how long the runs are kept, they are kept forever when 0.
+		HistoryRetention time.Duration `mapstructure:"history_retention"`
+	}
 )
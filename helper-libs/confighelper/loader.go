@@ -0,0 +1,258 @@
+package confighelper
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go-clean-arch/helper-libs/envhelper"
+	"go-clean-arch/helper-libs/loghelper"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	_ "github.com/spf13/viper/remote"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultRemoteWatchInterval = 30 * time.Second
+)
+
+type (
+	// Validator is implemented by configs checking their own required fields and value ranges.
+	Validator interface {
+		Validate() error
+	}
+
+	// RemoteConfig describes a viper remote key/value provider (etcd, etcd3, consul or firestore) holding a yaml document.
+	RemoteConfig struct {
+		Provider      string
+		Endpoint      string
+		Path          string
+		SecretKeyring string
+		// WatchInterval is the polling period of Watch, it falls back to 30s.
+		WatchInterval time.Duration
+	}
+
+	LoaderOptions struct {
+		// DefaultConfig is the embedded yaml holding every key, env variables only override keys declared here.
+		DefaultConfig []byte
+		// ConfigFile is merged over the defaults when set, see ConfigFileFromArgs.
+		ConfigFile string
+		Remote     *RemoteConfig
+		// SecretResolver resolves the secret references of every config string, it falls back to NewSecretResolverFromEnv.
+		SecretResolver *SecretResolver
+	}
+
+	// Loader layers the defaults, the config file, env variables and the remote provider, each one overriding the previous.
+	Loader[T any] struct {
+		opts      *LoaderOptions
+		mu        sync.RWMutex
+		current   *T
+		callbacks []func(old, new *T)
+		watchOnce sync.Once
+	}
+)
+
+func NewLoader[T any](opts *LoaderOptions) *Loader[T] {
+	if opts.SecretResolver == nil {
+		opts.SecretResolver = NewSecretResolverFromEnv()
+	}
+	return &Loader[T]{
+		opts: opts,
+	}
+}
+
+// ConfigFileFromArgs returns the value of the `-c`/`-config` flag of args, or the CONFIG_FILE env variable.
+func ConfigFileFromArgs(args []string) string {
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		if !strings.HasPrefix(args[i], "-") || (name != "c" && name != "config") {
+			continue
+		}
+		if hasValue {
+			return value
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return os.Getenv(envhelper.CONFIG_FILE)
+}
+
+// RemoteConfigFromEnv returns the remote provider described by the CONFIG_REMOTE_* env variables, nil when not configured.
+func RemoteConfigFromEnv() *RemoteConfig {
+	provider := os.Getenv(envhelper.CONFIG_REMOTE_PROVIDER)
+	if provider == "" {
+		return nil
+	}
+	watchInterval, _ := time.ParseDuration(os.Getenv(envhelper.CONFIG_REMOTE_WATCH_INTERVAL))
+	return &RemoteConfig{
+		Provider:      provider,
+		Endpoint:      os.Getenv(envhelper.CONFIG_REMOTE_ENDPOINT),
+		Path:          os.Getenv(envhelper.CONFIG_REMOTE_PATH),
+		SecretKeyring: os.Getenv(envhelper.CONFIG_REMOTE_SECRET_KEYRING),
+		WatchInterval: watchInterval,
+	}
+}
+
+// Load reads and validates the config, it becomes the value returned by Current.
+func (l *Loader[T]) Load() (*T, error) {
+	cfg, err := l.read()
+	if err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	l.current = cfg
+	l.mu.Unlock()
+	return cfg, nil
+}
+
+// Current returns the last valid config, nil before Load.
+func (l *Loader[T]) Current() *T {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.current
+}
+
+// OnChange registers fn to be called with the previous and the new config after every successful reload.
+// Configs are immutable values, fn decides which changes are safe to apply without restart.
+func (l *Loader[T]) OnChange(fn func(old, new *T)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.callbacks = append(l.callbacks, fn)
+}
+
+// Watch reloads the config when the file changes or, with a remote provider, on every watch interval.
+// A reload failing to parse or to validate is logged and ignored, the current config stays in use.
+func (l *Loader[T]) Watch() {
+	l.watchOnce.Do(func() {
+		if l.opts.ConfigFile != "" {
+			watcher := viper.New()
+			watcher.SetConfigFile(l.opts.ConfigFile)
+			watcher.OnConfigChange(func(event fsnotify.Event) {
+				if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) {
+					l.reload()
+				}
+			})
+			watcher.WatchConfig()
+		}
+		if l.opts.Remote != nil {
+			interval := l.opts.Remote.WatchInterval
+			if interval <= 0 {
+				interval = defaultRemoteWatchInterval
+			}
+			go func() {
+				ticker := time.NewTicker(interval)
+				defer ticker.Stop()
+				for range ticker.C {
+					l.reload()
+				}
+			}()
+		}
+	})
+}
+
+func (l *Loader[T]) reload() {
+	cfg, err := l.read()
+	if err != nil {
+		loghelper.Logger.Errorw("reload config failed, keep the current config", zap.Error(err))
+		return
+	}
+
+	l.mu.Lock()
+	old := l.current
+	l.current = cfg
+	callbacks := make([]func(old, new *T), len(l.callbacks))
+	copy(callbacks, l.callbacks)
+	l.mu.Unlock()
+
+	loghelper.Logger.Info("config reloaded")
+	for _, callback := range callbacks {
+		callback(old, cfg)
+	}
+}
+
+// read builds a fresh viper every time, viper's own reload only re-reads the file and would drop the other layers.
+func (l *Loader[T]) read() (*T, error) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(bytes.NewBuffer(l.opts.DefaultConfig)); err != nil {
+		return nil, fmt.Errorf("read default config: %w", err)
+	}
+
+	if l.opts.ConfigFile != "" {
+		v.SetConfigFile(l.opts.ConfigFile)
+		if ext := strings.TrimPrefix(filepath.Ext(l.opts.ConfigFile), "."); ext == "" {
+			v.SetConfigType("yaml")
+		}
+		if err := v.MergeInConfig(); err != nil {
+			return nil, fmt.Errorf("read config file %s: %w", l.opts.ConfigFile, err)
+		}
+	}
+
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "__"))
+	v.AutomaticEnv()
+
+	if l.opts.Remote != nil {
+		if err := mergeRemoteConfig(v, l.opts.Remote); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg := new(T)
+	if err := v.Unmarshal(cfg); err != nil {
+		return nil, fmt.Errorf("decode config: %w", err)
+	}
+	if err := l.opts.SecretResolver.ResolveAll(cfg); err != nil {
+		return nil, fmt.Errorf("resolve config secrets: %w", err)
+	}
+	if validator, ok := any(cfg).(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid config: %w", err)
+		}
+	}
+	return cfg, nil
+}
+
+// mergeRemoteConfig sets every remote key explicitly, so the remote provider overrides env variables too.
+func mergeRemoteConfig(v *viper.Viper, remote *RemoteConfig) error {
+	rv := viper.New()
+	rv.SetConfigType("yaml")
+	var err error
+	if remote.SecretKeyring != "" {
+		err = rv.AddSecureRemoteProvider(remote.Provider, remote.Endpoint, remote.Path, remote.SecretKeyring)
+	} else {
+		err = rv.AddRemoteProvider(remote.Provider, remote.Endpoint, remote.Path)
+	}
+	if err != nil {
+		return fmt.Errorf("remote config provider: %w", err)
+	}
+	if err = rv.ReadRemoteConfig(); err != nil {
+		return fmt.Errorf("read remote config %s%s: %w", remote.Endpoint, remote.Path, err)
+	}
+	for _, key := range rv.AllKeys() {
+		v.Set(key, rv.Get(key))
+	}
+	return nil
+}
+
+// ValidationErrors collects every invalid field so startup reports them all at once.
+type ValidationErrors struct {
+	errs []error
+}
+
+// Addf records an invalid field when `invalid` holds.
+func (e *ValidationErrors) Addf(invalid bool, format string, args ...any) {
+	if invalid {
+		e.errs = append(e.errs, fmt.Errorf(format, args...))
+	}
+}
+
+func (e *ValidationErrors) Err() error {
+	return errors.Join(e.errs...)
+}
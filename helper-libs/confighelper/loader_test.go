@@ -0,0 +1,115 @@
+package confighelper
+
+import (
+	"go-clean-arch/helper-libs/loghelper"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var loaderDefaultConfig = []byte(`
+app: pm-helper-libs
+log_level: info
+server:
+  rate_limit: 10
+`)
+
+type loaderConfig struct {
+	App      string       `mapstructure:"app"`
+	LogLevel string       `mapstructure:"log_level"`
+	Server   ServerConfig `mapstructure:"server"`
+}
+
+func (c *loaderConfig) Validate() error {
+	errs := &ValidationErrors{}
+	errs.Addf(c.Server.RateLimit < 0, "server.rate_limit must not be negative, got %d", c.Server.RateLimit)
+	return errs.Err()
+}
+
+func TestLoaderLayers(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(configFile, []byte("log_level: debug\nserver:\n  rate_limit: 20\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SERVER__RATE_LIMIT", "30")
+
+	cfg, err := NewLoader[loaderConfig](&LoaderOptions{DefaultConfig: loaderDefaultConfig, ConfigFile: configFile}).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.App != "pm-helper-libs" || cfg.LogLevel != "debug" || cfg.Server.RateLimit != 30 {
+		t.Errorf("expected defaults overridden by file then env, actual: %+v", cfg)
+	}
+}
+
+func TestLoaderValidation(t *testing.T) {
+	t.Setenv("SERVER__RATE_LIMIT", "-1")
+
+	_, err := NewLoader[loaderConfig](&LoaderOptions{DefaultConfig: loaderDefaultConfig}).Load()
+	if err == nil {
+		t.Fatal("expected a validation error")
+	}
+}
+
+func TestLoaderWatch(t *testing.T) {
+	_ = loghelper.InitZap("testing", "dev", map[string]string{})
+	configFile := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(configFile, []byte("log_level: info\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	loader := NewLoader[loaderConfig](&LoaderOptions{DefaultConfig: loaderDefaultConfig, ConfigFile: configFile})
+	if _, err := loader.Load(); err != nil {
+		t.Fatal(err)
+	}
+	changes := make(chan *loaderConfig, 10)
+	loader.OnChange(func(old, new *loaderConfig) {
+		changes <- new
+	})
+	loader.Watch()
+
+	// An invalid config is ignored and the current one stays in use
+	if err := os.WriteFile(configFile, []byte("server:\n  rate_limit: -5\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := os.WriteFile(configFile, []byte("log_level: warn\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case cfg := <-changes:
+			if cfg.Server.RateLimit < 0 {
+				t.Fatalf("invalid config must not be applied: %+v", cfg)
+			}
+			if cfg.LogLevel == "warn" {
+				if loader.Current().LogLevel != "warn" {
+					t.Errorf("expected current config to be reloaded, actual: %+v", loader.Current())
+				}
+				return
+			}
+		case <-timeout:
+			t.Fatal("config was not reloaded")
+		}
+	}
+}
+
+func TestConfigFileFromArgs(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "/env/config.yml")
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{args: []string{"-c", "/config/config.yml"}, expected: "/config/config.yml"},
+		{args: []string{"--config=/etc/app.yml"}, expected: "/etc/app.yml"},
+		{args: []string{"-v"}, expected: "/env/config.yml"},
+	}
+	for _, tt := range tests {
+		if actual := ConfigFileFromArgs(tt.args); actual != tt.expected {
+			t.Errorf("args %v: expected %q, actual: %q", tt.args, tt.expected, actual)
+		}
+	}
+}
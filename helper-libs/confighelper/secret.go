@@ -0,0 +1,355 @@
+package confighelper
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-clean-arch/helper-libs/envhelper"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	SecretPrefix_File   = "file://"
+	SecretPrefix_Env    = "env:"
+	SecretPrefix_Base64 = "base64:"
+	// SecretPrefix_Encrypted values are `enc:v1:<wrapped data key>:<ciphertext>`, both base64 encoded AES-GCM outputs
+	// prefixed by their nonce. The data key is wrapped by the key of the local key file.
+	SecretPrefix_Encrypted = "enc:"
+
+	secretEnvelopeVersion = "v1"
+	secretTag             = "secret"
+	redactedValue         = "******"
+)
+
+var (
+	ErrSecretKeyFileMissing = errors.New("secret key file is required to decrypt enc: values")
+)
+
+type (
+	SecretResolverOptions struct {
+		// KeyFile holds the 32 bytes key, raw, hex or base64 encoded, unwrapping the data keys of encrypted values.
+		KeyFile string
+	}
+
+	// SecretResolver replaces secret references of config strings by the secret they point to.
+	SecretResolver struct {
+		keyFile string
+		keyOnce sync.Once
+		key     []byte
+		keyErr  error
+	}
+)
+
+func NewSecretResolver(opts *SecretResolverOptions) *SecretResolver {
+	return &SecretResolver{
+		keyFile: opts.KeyFile,
+	}
+}
+
+// NewSecretResolverFromEnv reads the key file path from the CONFIG_SECRET_KEY_FILE env variable.
+func NewSecretResolverFromEnv() *SecretResolver {
+	return NewSecretResolver(&SecretResolverOptions{
+		KeyFile: os.Getenv(envhelper.CONFIG_SECRET_KEY_FILE),
+	})
+}
+
+// Resolve returns the secret referenced by value, values without a known prefix are returned as is.
+func (r *SecretResolver) Resolve(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, SecretPrefix_File):
+		path := strings.TrimPrefix(value, SecretPrefix_File)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("read secret file %s: %w", path, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	case strings.HasPrefix(value, SecretPrefix_Env):
+		name := strings.TrimPrefix(value, SecretPrefix_Env)
+		secret, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("secret env variable %s is not set", name)
+		}
+		return secret, nil
+	case strings.HasPrefix(value, SecretPrefix_Base64):
+		data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, SecretPrefix_Base64))
+		if err != nil {
+			return "", fmt.Errorf("decode base64 secret: %w", err)
+		}
+		return string(data), nil
+	case strings.HasPrefix(value, SecretPrefix_Encrypted):
+		key, err := r.loadKey()
+		if err != nil {
+			return "", err
+		}
+		return DecryptSecret(value, key)
+	}
+	return value, nil
+}
+
+// ResolveAll resolves every string reachable from cfg, which must be a pointer, including slices, maps and nested structs.
+func (r *SecretResolver) ResolveAll(cfg any) error {
+	value := reflect.ValueOf(cfg)
+	if value.Kind() != reflect.Pointer || value.IsNil() {
+		return errors.New("resolve secrets: config must be a non nil pointer")
+	}
+	return r.resolveValue(value.Elem(), "")
+}
+
+func (r *SecretResolver) resolveValue(value reflect.Value, path string) error {
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if value.IsNil() {
+			return nil
+		}
+		if value.Kind() == reflect.Interface {
+			// Values held by interfaces aren't addressable, resolve a copy and store it back
+			elem := reflect.New(value.Elem().Type()).Elem()
+			elem.Set(value.Elem())
+			if err := r.resolveValue(elem, path); err != nil {
+				return err
+			}
+			if value.CanSet() {
+				value.Set(elem)
+			}
+			return nil
+		}
+		return r.resolveValue(value.Elem(), path)
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			if !value.Type().Field(i).IsExported() {
+				continue
+			}
+			if err := r.resolveValue(value.Field(i), joinPath(path, value.Type().Field(i).Name)); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			if err := r.resolveValue(value.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			elem := reflect.New(value.Type().Elem()).Elem()
+			elem.Set(value.MapIndex(key))
+			if err := r.resolveValue(elem, joinPath(path, fmt.Sprint(key.Interface()))); err != nil {
+				return err
+			}
+			value.SetMapIndex(key, elem)
+		}
+	case reflect.String:
+		if !value.CanSet() {
+			return nil
+		}
+		resolved, err := r.Resolve(value.String())
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		value.SetString(resolved)
+	}
+	return nil
+}
+
+func (r *SecretResolver) loadKey() ([]byte, error) {
+	r.keyOnce.Do(func() {
+		if r.keyFile == "" {
+			r.keyErr = ErrSecretKeyFileMissing
+			return
+		}
+		data, err := os.ReadFile(r.keyFile)
+		if err != nil {
+			r.keyErr = fmt.Errorf("read secret key file: %w", err)
+			return
+		}
+		r.key, r.keyErr = parseSecretKey(strings.TrimSpace(string(data)))
+	})
+	return r.key, r.keyErr
+}
+
+func parseSecretKey(encoded string) ([]byte, error) {
+	if len(encoded) == 32 {
+		return []byte(encoded), nil
+	}
+	if key, err := hex.DecodeString(encoded); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(encoded); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	return nil, errors.New("secret key must be 32 bytes, raw, hex or base64 encoded")
+}
+
+// EncryptSecret seals plaintext with a fresh data key wrapped by key, the result is accepted by DecryptSecret and Resolve.
+func EncryptSecret(plaintext string, key []byte) (string, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	wrappedKey, err := sealAESGCM(key, dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := sealAESGCM(dataKey, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	return SecretPrefix_Encrypted + secretEnvelopeVersion + ":" +
+		base64.StdEncoding.EncodeToString(wrappedKey) + ":" +
+		base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+func DecryptSecret(value string, key []byte) (string, error) {
+	parts := strings.Split(strings.TrimPrefix(value, SecretPrefix_Encrypted), ":")
+	if len(parts) != 3 || parts[0] != secretEnvelopeVersion {
+		return "", errors.New("encrypted secret must be enc:v1:<wrapped key>:<ciphertext>")
+	}
+	wrappedKey, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("decode wrapped key: %w", err)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("decode ciphertext: %w", err)
+	}
+	dataKey, err := openAESGCM(key, wrappedKey)
+	if err != nil {
+		return "", fmt.Errorf("unwrap data key: %w", err)
+	}
+	plaintext, err := openAESGCM(dataKey, ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("decrypt secret: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+func sealAESGCM(key, plaintext []byte) ([]byte, error) {
+	aead, err := newAESGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func openAESGCM(key, sealed []byte) ([]byte, error) {
+	aead, err := newAESGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+}
+
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Redact formats cfg like `%+v` but hides the fields tagged `secret:"true"`, at any depth.
+// Configs implement fmt.Stringer with it so logging them never prints a resolved secret.
+func Redact(cfg any) string {
+	var builder strings.Builder
+	writeRedacted(&builder, reflect.ValueOf(cfg), true)
+	return builder.String()
+}
+
+func writeRedacted(builder *strings.Builder, value reflect.Value, root bool) {
+	// The root is skipped since it is the config whose String calls Redact
+	if !root && value.Kind() == reflect.Struct && value.CanInterface() {
+		if stringer, ok := value.Interface().(fmt.Stringer); ok {
+			builder.WriteString(stringer.String())
+			return
+		}
+	}
+
+	switch value.Kind() {
+	case reflect.Invalid:
+		builder.WriteString("<nil>")
+	case reflect.Pointer, reflect.Interface:
+		if value.IsNil() {
+			builder.WriteString("<nil>")
+			return
+		}
+		if value.Kind() == reflect.Pointer {
+			builder.WriteString("&")
+		}
+		writeRedacted(builder, value.Elem(), root)
+	case reflect.Struct:
+		builder.WriteString("{")
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			if i > 0 {
+				builder.WriteString(" ")
+			}
+			builder.WriteString(field.Name)
+			builder.WriteString(":")
+			switch {
+			case field.Tag.Get(secretTag) == "true":
+				if !value.Field(i).IsZero() {
+					builder.WriteString(redactedValue)
+				}
+			case !field.IsExported():
+				builder.WriteString("?")
+			default:
+				writeRedacted(builder, value.Field(i), false)
+			}
+		}
+		builder.WriteString("}")
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && value.IsNil() {
+			builder.WriteString("[]")
+			return
+		}
+		builder.WriteString("[")
+		for i := 0; i < value.Len(); i++ {
+			if i > 0 {
+				builder.WriteString(" ")
+			}
+			writeRedacted(builder, value.Index(i), false)
+		}
+		builder.WriteString("]")
+	case reflect.Map:
+		keys := value.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		builder.WriteString("map[")
+		for i, key := range keys {
+			if i > 0 {
+				builder.WriteString(" ")
+			}
+			builder.WriteString(fmt.Sprint(key.Interface()))
+			builder.WriteString(":")
+			writeRedacted(builder, value.MapIndex(key), false)
+		}
+		builder.WriteString("]")
+	default:
+		if value.CanInterface() {
+			builder.WriteString(fmt.Sprint(value.Interface()))
+		}
+	}
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
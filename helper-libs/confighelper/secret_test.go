@@ -0,0 +1,86 @@
+package confighelper
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type secretConfig struct {
+	App       string
+	Database  SqlDatabaseConfig
+	BasicAuth BasicAuthGuardConfig
+	Headers   map[string]string
+}
+
+func (c secretConfig) String() string {
+	return Redact(c)
+}
+
+func TestSecretResolverResolveAll(t *testing.T) {
+	dir := t.TempDir()
+	passwordFile := filepath.Join(dir, "db_pw")
+	if err := os.WriteFile(passwordFile, []byte("db-secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	key := []byte("0123456789abcdef0123456789abcdef")
+	keyFile := filepath.Join(dir, "secret.key")
+	if err := os.WriteFile(keyFile, []byte(hex.EncodeToString(key)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := EncryptSecret("encrypted-secret", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_BASIC_AUTH_PW", "env-secret")
+
+	cfg := &secretConfig{
+		App:      "plain",
+		Database: SqlDatabaseConfig{Password: SecretPrefix_File + passwordFile},
+		BasicAuth: BasicAuthGuardConfig{
+			BasicAuth:   BasicAuth{Username: "admin", Password: "env:TEST_BASIC_AUTH_PW"},
+			Credentials: []BasicAuth{{Username: "ops", Password: encrypted}},
+		},
+		Headers: map[string]string{"X-Api-Key": "base64:" + base64.StdEncoding.EncodeToString([]byte("header-secret"))},
+	}
+
+	resolver := NewSecretResolver(&SecretResolverOptions{KeyFile: keyFile})
+	if err := resolver.ResolveAll(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"plain":            cfg.App,
+		"db-secret":        cfg.Database.Password,
+		"env-secret":       cfg.BasicAuth.Password,
+		"encrypted-secret": cfg.BasicAuth.Credentials[0].Password,
+		"header-secret":    cfg.Headers["X-Api-Key"],
+	}
+	for want, got := range expected {
+		if got != want {
+			t.Errorf("expected %q, actual: %q", want, got)
+		}
+	}
+
+	logged := cfg.String()
+	for _, secret := range []string{"db-secret", "env-secret", "encrypted-secret"} {
+		if strings.Contains(logged, secret) {
+			t.Errorf("redacted config leaks %q: %s", secret, logged)
+		}
+	}
+	if !strings.Contains(logged, "App:plain") || !strings.Contains(logged, "Username:ops") {
+		t.Errorf("redacted config must keep non secret fields: %s", logged)
+	}
+}
+
+func TestSecretResolverErrors(t *testing.T) {
+	resolver := NewSecretResolver(&SecretResolverOptions{})
+	for _, value := range []string{"env:TEST_MISSING_SECRET_ENV", "file:///not/found", "base64:***", "enc:v1:a:b"} {
+		if _, err := resolver.Resolve(value); err == nil {
+			t.Errorf("expected an error resolving %q", value)
+		}
+	}
+}
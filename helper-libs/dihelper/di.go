@@ -1,6 +1,8 @@
 package dihelper
 
 import (
+	"errors"
+	"fmt"
 	"sync"
 
 	"github.com/sarulabs/di"
@@ -8,6 +10,12 @@ import (
 
 type DIBuilder func() []di.Def
 
+type builtDependency struct {
+	name  string
+	obj   interface{}
+	close func(obj interface{}) error
+}
+
 var (
 	buildOnce           sync.Once
 	builder             *di.Builder
@@ -19,6 +27,11 @@ var (
 	UsecasesBuilder     DIBuilder
 	FacadiesBuilder     DIBuilder
 	APIsBuilder         DIBuilder
+
+	builtMu   sync.Mutex
+	built     []builtDependency
+	cleanOnce sync.Once
+	cleanErr  error
 )
 
 func BuildLibDIContainer() {
@@ -57,8 +70,63 @@ func GetLibDependency(dependencyName string) interface{} {
 	return container.Get(dependencyName)
 }
 
+// CleanDependency closes every built dependency in the reverse order of their creation, so a dependency is closed
+// only after everything built on top of it, then deletes the container. It runs once, later calls return the same result.
 func CleanDependency() error {
-	return container.Clean()
+	cleanOnce.Do(func() {
+		builtMu.Lock()
+		dependencies := built
+		built = nil
+		builtMu.Unlock()
+
+		var errs []error
+		for i := len(dependencies) - 1; i >= 0; i-- {
+			if err := closeDependency(dependencies[i]); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if container != nil {
+			errs = append(errs, container.Delete())
+		}
+		cleanErr = errors.Join(errs...)
+	})
+	return cleanErr
+}
+
+func closeDependency(dependency builtDependency) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("could not close `%s`, Close function panicked: %v", dependency.name, recovered)
+		}
+	}()
+	if err = dependency.close(dependency.obj); err != nil {
+		return fmt.Errorf("could not close `%s`: %w", dependency.name, err)
+	}
+	return nil
+}
+
+// trackDefs records the objects built by defs so CleanDependency can close them in order,
+// the container itself no longer closes them since it does it in random order.
+func trackDefs(defs []di.Def) []di.Def {
+	tracked := make([]di.Def, len(defs))
+	for i, def := range defs {
+		def := def
+		build, closeFunc := def.Build, def.Close
+		if build != nil && closeFunc != nil {
+			def.Build = func(ctn di.Container) (interface{}, error) {
+				obj, err := build(ctn)
+				if err == nil {
+					builtMu.Lock()
+					built = append(built, builtDependency{name: def.Name, obj: obj, close: closeFunc})
+					builtMu.Unlock()
+				}
+				return obj, err
+			}
+			def.Close = nil
+		}
+		tracked[i] = def
+	}
+	return tracked
 }
 
 func buildConfigs() error {
@@ -67,7 +135,7 @@ func buildConfigs() error {
 		ConfigsBuilder = defaultBuilder
 	}
 	defs := ConfigsBuilder()
-	err := builder.Add(defs...)
+	err := builder.Add(trackDefs(defs)...)
 	if err != nil {
 		return err
 	}
@@ -80,7 +148,7 @@ func buildHelpers() error {
 		HelpersBuilder = defaultBuilder
 	}
 	defs := HelpersBuilder()
-	err := builder.Add(defs...)
+	err := builder.Add(trackDefs(defs)...)
 	if err != nil {
 		return err
 	}
@@ -93,7 +161,7 @@ func buildRepositories() error {
 		RepositoriesBuilder = defaultBuilder
 	}
 	defs := RepositoriesBuilder()
-	err := builder.Add(defs...)
+	err := builder.Add(trackDefs(defs)...)
 	if err != nil {
 		return err
 	}
@@ -106,7 +174,7 @@ func buildAdapters() error {
 		AdaptersBuilder = defaultBuilder
 	}
 	defs := AdaptersBuilder()
-	err := builder.Add(defs...)
+	err := builder.Add(trackDefs(defs)...)
 	if err != nil {
 		return err
 	}
@@ -119,7 +187,7 @@ func buildUsecases() error {
 		UsecasesBuilder = defaultBuilder
 	}
 	defs := UsecasesBuilder()
-	err := builder.Add(defs...)
+	err := builder.Add(trackDefs(defs)...)
 	if err != nil {
 		return err
 	}
@@ -132,7 +200,7 @@ func buildFacadies() error {
 		FacadiesBuilder = defaultBuilder
 	}
 	defs := FacadiesBuilder()
-	err := builder.Add(defs...)
+	err := builder.Add(trackDefs(defs)...)
 	if err != nil {
 		return err
 	}
@@ -145,7 +213,7 @@ func buildAPIs() error {
 		APIsBuilder = defaultBuilder
 	}
 	defs := APIsBuilder()
-	err := builder.Add(defs...)
+	err := builder.Add(trackDefs(defs)...)
 	if err != nil {
 		return err
 	}
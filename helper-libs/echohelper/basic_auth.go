@@ -0,0 +1,138 @@
+package echohelper
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"go-clean-arch/helper-libs/confighelper"
+	"go-clean-arch/helper-libs/loghelper"
+	"os"
+	"strings"
+
+	echo "github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	passwordHashPrefixSha256 = "sha256:"
+
+	defaultBasicAuthRealm = "Restricted"
+)
+
+var (
+	// DefaultBasicAuthPaths are the operational endpoints guarded when no path is configured.
+	DefaultBasicAuthPaths = []string{"/metrics", "/swagger/*", "/v1/health/*"}
+)
+
+type (
+	BasicAuthGuardConfig struct {
+		Skipper middleware.Skipper
+		// Paths guarded by basic auth, an entry ending with `*` matches every path under its prefix. Other paths are not guarded.
+		Paths       []string
+		Realm       string
+		Credentials []confighelper.BasicAuth
+	}
+
+	basicAuthCredential struct {
+		username []byte
+		verify   func(password string) bool
+	}
+)
+
+// NewBasicAuthGuardConfig collects the inline credential and the `credentials` entries of `cfg`.
+func NewBasicAuthGuardConfig(cfg *confighelper.BasicAuthGuardConfig) BasicAuthGuardConfig {
+	credentials := make([]confighelper.BasicAuth, 0, len(cfg.Credentials)+1)
+	if cfg.Username != "" {
+		credentials = append(credentials, cfg.BasicAuth)
+	}
+	credentials = append(credentials, cfg.Credentials...)
+
+	return BasicAuthGuardConfig{
+		Paths:       cfg.Paths,
+		Realm:       cfg.Realm,
+		Credentials: credentials,
+	}
+}
+
+// BasicAuthGuard protects the configured paths with basic auth, usernames and passwords are compared in constant time.
+func BasicAuthGuard(config BasicAuthGuardConfig) echo.MiddlewareFunc {
+	credentials := make([]*basicAuthCredential, 0, len(config.Credentials))
+	for _, credential := range config.Credentials {
+		if credential.Username == "" {
+			continue
+		}
+		credentials = append(credentials, newBasicAuthCredential(credential))
+	}
+	if len(credentials) == 0 {
+		loghelper.Logger.Panic("basic auth credentials must specific")
+	}
+	if len(config.Paths) == 0 {
+		config.Paths = DefaultBasicAuthPaths
+	}
+	if config.Realm == "" {
+		config.Realm = defaultBasicAuthRealm
+	}
+	skipper := config.Skipper
+	if skipper == nil {
+		skipper = middleware.DefaultSkipper
+	}
+
+	return middleware.BasicAuthWithConfig(middleware.BasicAuthConfig{
+		Skipper: func(c echo.Context) bool {
+			return skipper(c) || !MatchPath(config.Paths, c.Request().URL.Path)
+		},
+		Realm: config.Realm,
+		Validator: func(username, password string, c echo.Context) (bool, error) {
+			// Every entry is checked so the response time doesn't reveal which username exists
+			valid := false
+			for _, credential := range credentials {
+				usernameMatched := subtle.ConstantTimeCompare([]byte(username), credential.username) == 1
+				if credential.verify(password) && usernameMatched {
+					valid = true
+				}
+			}
+			return valid, nil
+		},
+	})
+}
+
+func newBasicAuthCredential(credential confighelper.BasicAuth) *basicAuthCredential {
+	passwordHash := credential.PasswordHash
+	if credential.PasswordHashEnv != "" {
+		if value, ok := os.LookupEnv(credential.PasswordHashEnv); ok && value != "" {
+			passwordHash = value
+		}
+	}
+
+	result := &basicAuthCredential{
+		username: []byte(credential.Username),
+	}
+	switch {
+	case strings.HasPrefix(passwordHash, passwordHashPrefixSha256):
+		expected, err := hex.DecodeString(strings.TrimPrefix(passwordHash, passwordHashPrefixSha256))
+		if err != nil {
+			loghelper.Logger.Panicf("invalid sha256 password hash of basic auth user %s", credential.Username)
+		}
+		result.verify = func(password string) bool {
+			digest := sha256.Sum256([]byte(password))
+			return subtle.ConstantTimeCompare(digest[:], expected) == 1
+		}
+	case passwordHash != "":
+		hash := []byte(passwordHash)
+		if _, err := bcrypt.Cost(hash); err != nil {
+			loghelper.Logger.Panicf("invalid bcrypt password hash of basic auth user %s", credential.Username)
+		}
+		result.verify = func(password string) bool {
+			return bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil
+		}
+	default:
+		// Digests have a fixed length, so comparing them doesn't leak the length of the password
+		expected := sha256.Sum256([]byte(credential.Password))
+		result.verify = func(password string) bool {
+			digest := sha256.Sum256([]byte(password))
+			return credential.Password != "" && subtle.ConstantTimeCompare(digest[:], expected[:]) == 1
+		}
+	}
+	return result
+}
@@ -0,0 +1,74 @@
+package echohelper
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"go-clean-arch/helper-libs/confighelper"
+	"go-clean-arch/helper-libs/loghelper"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	echo "github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestBasicAuthGuard(t *testing.T) {
+	if err := loghelper.InitZap("test", "dev", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("bcrypt-secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sha256Hash := sha256.Sum256([]byte("sha-secret"))
+	t.Setenv("TEST_BASIC_AUTH_HASH", passwordHashPrefixSha256+hex.EncodeToString(sha256Hash[:]))
+
+	guard := BasicAuthGuard(NewBasicAuthGuardConfig(&confighelper.BasicAuthGuardConfig{
+		BasicAuth: confighelper.BasicAuth{Username: "admin", Password: "plain-secret"},
+		Paths:     []string{"/metrics", "/v1/health/*"},
+		Credentials: []confighelper.BasicAuth{
+			{Username: "ops", PasswordHash: string(bcryptHash)},
+			{Username: "monitor", PasswordHash: "sha256:00", PasswordHashEnv: "TEST_BASIC_AUTH_HASH"},
+		},
+	}))
+	handler := guard(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	tests := []struct {
+		name           string
+		path           string
+		username       string
+		password       string
+		expectedStatus int
+	}{
+		{name: "unguarded path", path: "/v1/cards", expectedStatus: http.StatusOK},
+		{name: "missing credential", path: "/metrics", expectedStatus: http.StatusUnauthorized},
+		{name: "plain password", path: "/metrics", username: "admin", password: "plain-secret", expectedStatus: http.StatusOK},
+		{name: "bcrypt password", path: "/v1/health/readiness", username: "ops", password: "bcrypt-secret", expectedStatus: http.StatusOK},
+		{name: "sha256 password from env", path: "/metrics", username: "monitor", password: "sha-secret", expectedStatus: http.StatusOK},
+		{name: "wrong password", path: "/metrics", username: "admin", password: "wrong", expectedStatus: http.StatusUnauthorized},
+		{name: "password of another user", path: "/metrics", username: "admin", password: "bcrypt-secret", expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.username != "" {
+				req.SetBasicAuth(tt.username, tt.password)
+			}
+			rec := httptest.NewRecorder()
+			err := handler(echo.New().NewContext(req, rec))
+
+			status := rec.Code
+			if httpErr, ok := err.(*echo.HTTPError); ok {
+				status = httpErr.Code
+			}
+			if status != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d (err %v)", tt.expectedStatus, status, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package echohelper
+
+import (
+	"go-clean-arch/helper-libs/confighelper"
+
+	echo "github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+type (
+	corsPolicy struct {
+		paths      []string
+		middleware echo.MiddlewareFunc
+	}
+)
+
+// CORS applies the first policy of cfg whose paths match the request path, the default policy otherwise.
+func CORS(cfg *confighelper.CorsConfig) echo.MiddlewareFunc {
+	policies := make([]corsPolicy, 0, len(cfg.Policies))
+	for _, policy := range cfg.Policies {
+		policies = append(policies, corsPolicy{
+			paths:      policy.Paths,
+			middleware: middleware.CORSWithConfig(newCORSConfig(policy)),
+		})
+	}
+	defaultPolicy := middleware.CORSWithConfig(newCORSConfig(cfg.CorsPolicy))
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		handlers := make([]echo.HandlerFunc, len(policies))
+		for i, policy := range policies {
+			handlers[i] = policy.middleware(next)
+		}
+		defaultHandler := defaultPolicy(next)
+
+		return func(c echo.Context) error {
+			for i, policy := range policies {
+				if MatchPath(policy.paths, c.Request().URL.Path) {
+					return handlers[i](c)
+				}
+			}
+			return defaultHandler(c)
+		}
+	}
+}
+
+func newCORSConfig(policy confighelper.CorsPolicy) middleware.CORSConfig {
+	return middleware.CORSConfig{
+		AllowOrigins:     policy.AllowOrigins,
+		AllowMethods:     policy.AllowMethods,
+		AllowHeaders:     policy.AllowHeaders,
+		ExposeHeaders:    policy.ExposeHeaders,
+		AllowCredentials: policy.AllowCredentials,
+		MaxAge:           policy.MaxAge,
+	}
+}
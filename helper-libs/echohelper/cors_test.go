@@ -0,0 +1,47 @@
+package echohelper
+
+import (
+	"go-clean-arch/helper-libs/confighelper"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	echo "github.com/labstack/echo/v4"
+)
+
+func TestCORS(t *testing.T) {
+	cors := CORS(&confighelper.CorsConfig{
+		CorsPolicy: confighelper.CorsPolicy{AllowOrigins: []string{"https://app.example.com"}},
+		Policies: []confighelper.CorsPolicy{
+			{Paths: []string{"/v1/public/*"}, AllowOrigins: []string{"*"}, MaxAge: 600},
+		},
+	})
+	handler := cors(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	tests := []struct {
+		name           string
+		path           string
+		origin         string
+		expectedOrigin string
+	}{
+		{name: "default policy allowed origin", path: "/v1/cards", origin: "https://app.example.com", expectedOrigin: "https://app.example.com"},
+		{name: "default policy other origin", path: "/v1/cards", origin: "https://evil.example.com", expectedOrigin: ""},
+		{name: "path policy", path: "/v1/public/rates", origin: "https://evil.example.com", expectedOrigin: "*"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set(echo.HeaderOrigin, tt.origin)
+			rec := httptest.NewRecorder()
+			if err := handler(echo.New().NewContext(req, rec)); err != nil {
+				t.Fatal(err)
+			}
+			if actual := rec.Header().Get(echo.HeaderAccessControlAllowOrigin); actual != tt.expectedOrigin {
+				t.Errorf("expected allow origin %q, actual: %q", tt.expectedOrigin, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package echohelper
+
+import (
+	"go-clean-arch/helper-libs/commonhelper"
+	"go-clean-arch/helper-libs/loghelper"
+	"go-clean-arch/helper-libs/ratelimithelper"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	echo "github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"go.uber.org/zap"
+)
+
+const (
+	RateLimitKeyBy_IP      = "ip"
+	RateLimitKeyBy_Subject = "subject"
+	RateLimitKeyBy_Route   = "route"
+
+	HeaderRateLimitLimit     = "X-RateLimit-Limit"
+	HeaderRateLimitRemaining = "X-RateLimit-Remaining"
+	HeaderRateLimitReset     = "X-RateLimit-Reset"
+)
+
+type (
+	DistributedRateLimiterConfig struct {
+		Skipper middleware.Skipper
+		Limiter ratelimithelper.Limiter
+		Limit   ratelimithelper.Limit
+		// KeyBy combines `ip`, `subject` (the JWT subject, the ip when anonymous) and `route`, it falls back to `ip`.
+		KeyBy []string
+	}
+
+	distributedRateLimiter struct {
+		config       DistributedRateLimiterConfig
+		mu           sync.RWMutex
+		limit        ratelimithelper.Limit
+		keyExtractor func(c echo.Context) string
+	}
+)
+
+// NewDistributedRateLimiter shares the limits of every replica through config.Limiter.
+// Requests are allowed when the limiter fails, e.g. redis is unreachable, so an outage of redis doesn't take the API down.
+func NewDistributedRateLimiter(config DistributedRateLimiterConfig) RateLimiter {
+	if config.Limiter == nil {
+		loghelper.Logger.Panic("rate limiter must specific")
+	}
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+	if len(config.KeyBy) == 0 {
+		config.KeyBy = []string{RateLimitKeyBy_IP}
+	}
+	extractors := make([]func(c echo.Context) string, 0, len(config.KeyBy))
+	for _, keyBy := range config.KeyBy {
+		switch keyBy {
+		case RateLimitKeyBy_IP:
+			extractors = append(extractors, func(c echo.Context) string {
+				return "ip:" + c.RealIP()
+			})
+		case RateLimitKeyBy_Subject:
+			extractors = append(extractors, func(c echo.Context) string {
+				if subject, _ := c.Get(string(commonhelper.ContextKeyType_Subject)).(string); subject != "" {
+					return "sub:" + subject
+				}
+				return "ip:" + c.RealIP()
+			})
+		case RateLimitKeyBy_Route:
+			extractors = append(extractors, func(c echo.Context) string {
+				return "route:" + c.Request().Method + " " + c.Path()
+			})
+		default:
+			loghelper.Logger.Panicf("rate limit key by must be ip, subject or route, got %q", keyBy)
+		}
+	}
+
+	return &distributedRateLimiter{
+		config: config,
+		limit:  config.Limit,
+		keyExtractor: func(c echo.Context) string {
+			parts := make([]string, len(extractors))
+			for i, extractor := range extractors {
+				parts[i] = extractor(c)
+			}
+			return strings.Join(parts, "|")
+		},
+	}
+}
+
+func (r *distributedRateLimiter) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if r.config.Skipper(c) {
+				return next(c)
+			}
+			r.mu.RLock()
+			limit := r.limit
+			r.mu.RUnlock()
+			if limit.Rate <= 0 {
+				return next(c)
+			}
+
+			result, err := r.config.Limiter.Allow(c.Request().Context(), r.keyExtractor(c), limit)
+			if err != nil {
+				loghelper.Logger.Warnw("rate limiter unavailable, request allowed", zap.Error(err))
+				return next(c)
+			}
+
+			header := c.Response().Header()
+			header.Set(HeaderRateLimitLimit, strconv.FormatInt(result.Limit, 10))
+			header.Set(HeaderRateLimitRemaining, strconv.FormatInt(result.Remaining, 10))
+			header.Set(HeaderRateLimitReset, strconv.FormatInt(ceilSeconds(result.ResetAfter), 10))
+			if !result.Allowed {
+				header.Set(echo.HeaderRetryAfter, strconv.FormatInt(max(1, ceilSeconds(result.RetryAfter)), 10))
+				return ErrRateLimitExceeded
+			}
+			return next(c)
+		}
+	}
+}
+
+// SetRate changes the requests allowed per period, the period and burst stay as configured.
+func (r *distributedRateLimiter) SetRate(requests int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.limit.Rate = requests
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,124 @@
+package echohelper
+
+import (
+	"go-clean-arch/helper-libs/commonhelper"
+	"go-clean-arch/helper-libs/loghelper"
+	"go-clean-arch/helper-libs/ratelimithelper"
+	"go-clean-arch/helper-libs/redisclienthelper"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	echo "github.com/labstack/echo/v4"
+	redis "github.com/redis/go-redis/v9"
+)
+
+func TestDistributedRateLimiter(t *testing.T) {
+	if err := loghelper.InitZap("test", "dev", nil); err != nil {
+		t.Fatal(err)
+	}
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+	defer client.Close()
+
+	rateLimiter := NewDistributedRateLimiter(DistributedRateLimiterConfig{
+		Limiter: ratelimithelper.NewRedisLimiter(&ratelimithelper.RedisLimiterOptions{
+			Client: &redisclienthelper.RedisClientHelper{Client: client},
+		}),
+		Limit: ratelimithelper.Limit{Rate: 2, Period: time.Minute},
+		KeyBy: []string{RateLimitKeyBy_Subject, RateLimitKeyBy_Route},
+	})
+	e := echo.New()
+	handler := rateLimiter.Middleware()(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+	serve := func(subject string) (*httptest.ResponseRecorder, error) {
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/v1/cards", nil), rec)
+		c.SetPath("/v1/cards")
+		c.Set(string(commonhelper.ContextKeyType_Subject), subject)
+		return rec, handler(c)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := serve("alice"); err != nil {
+			t.Fatalf("request %d: expected to be allowed, actual: %v", i, err)
+		}
+	}
+	rec, err := serve("alice")
+	if err != ErrRateLimitExceeded {
+		t.Fatalf("expected %v, actual: %v", ErrRateLimitExceeded, err)
+	}
+	if rec.Header().Get(HeaderRateLimitLimit) != "2" || rec.Header().Get(HeaderRateLimitRemaining) != "0" ||
+		rec.Header().Get(echo.HeaderRetryAfter) != "30" {
+		t.Errorf("unexpected rate limit headers: %v", rec.Header())
+	}
+	if _, err = serve("bob"); err != nil {
+		t.Errorf("expected another subject to have its own limit, actual: %v", err)
+	}
+
+	// Fail open when redis is unreachable
+	server.Close()
+	if _, err = serve("alice"); err != nil {
+		t.Errorf("expected the request to be allowed without redis, actual: %v", err)
+	}
+}
+
+func TestDistributedRateLimiterIgnoresSpoofedForwardedFor(t *testing.T) {
+	if err := loghelper.InitZap("test", "dev", nil); err != nil {
+		t.Fatal(err)
+	}
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	rateLimiter := NewDistributedRateLimiter(DistributedRateLimiterConfig{
+		Limiter: ratelimithelper.NewRedisLimiter(&ratelimithelper.RedisLimiterOptions{
+			Client: &redisclienthelper.RedisClientHelper{Client: client},
+		}),
+		Limit: ratelimithelper.Limit{Rate: 1, Period: time.Minute},
+	})
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		remoteAddr     string
+		limited        bool
+	}{
+		{"no trusted proxy", nil, "203.0.113.7:4000", true},
+		{"untrusted proxy", []string{"10.0.0.0/8"}, "203.0.113.8:4000", true},
+		{"trusted proxy", []string{"10.0.0.0/8"}, "10.0.0.1:4000", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := echo.New()
+			ipExtractor, err := NewIPExtractor(test.trustedProxies)
+			if err != nil {
+				t.Fatal(err)
+			}
+			e.IPExtractor = ipExtractor
+			e.Use(rateLimiter.Middleware())
+			e.GET("/v1/cards", func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			})
+
+			var code int
+			for _, forwardedFor := range []string{"198.51.100.1", "198.51.100.2"} {
+				req := httptest.NewRequest(http.MethodGet, "/v1/cards", nil)
+				req.RemoteAddr = test.remoteAddr
+				req.Header.Set(echo.HeaderXForwardedFor, forwardedFor)
+				rec := httptest.NewRecorder()
+				e.ServeHTTP(rec, req)
+				code = rec.Code
+			}
+			if limited := code == http.StatusTooManyRequests; limited != test.limited {
+				t.Errorf("expected the second request limited: %v, actual status: %d", test.limited, code)
+			}
+		})
+	}
+
+	if _, err := NewIPExtractor([]string{"10.0.0.1"}); err == nil {
+		t.Error("expected an error for a proxy that isn't a CIDR")
+	}
+}
@@ -0,0 +1,226 @@
+package echohelper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"go-clean-arch/helper-libs/commonhelper"
+	"go-clean-arch/helper-libs/idempotencyhelper"
+	"go-clean-arch/helper-libs/loghelper"
+	"go-clean-arch/helper-libs/redislockhelper"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/bsm/redislock"
+	echo "github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"go.uber.org/zap"
+)
+
+const (
+	HeaderIdempotencyKey     = "Idempotency-Key"
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength       = 255
+	defaultIdempotencyTTL         = 24 * time.Hour
+	defaultIdempotencyLockTimeout = 30 * time.Second
+	defaultIdempotencyLockWait    = 5 * time.Second
+	idempotencyLockRetryInterval  = 50 * time.Millisecond
+	defaultIdempotencyLockPrefix  = "idempotency:lock:"
+)
+
+// idempotencyRequestHeaders are set for each request by the trace and rate limit middlewares, they are neither
+// stored nor replayed.
+var idempotencyRequestHeaders = []string{
+	echo.HeaderXRequestID,
+	HeaderTraceparent,
+	HeaderRateLimitLimit,
+	HeaderRateLimitRemaining,
+	HeaderRateLimitReset,
+}
+
+var (
+	ErrIdempotencyKeyInvalid    = echo.NewHTTPError(http.StatusBadRequest, "idempotency key must not exceed 255 characters")
+	ErrIdempotencyKeyInProgress = echo.NewHTTPError(http.StatusConflict, "a request with the same idempotency key is in progress")
+	ErrIdempotencyKeyReused     = echo.NewHTTPError(http.StatusUnprocessableEntity, "idempotency key was used with a different request")
+)
+
+type (
+	IdempotencyConfig struct {
+		Skipper middleware.Skipper
+		Store   idempotencyhelper.Store
+		// Locker serializes the requests of a key, a concurrent duplicate waits up to LockWait and replays the response
+		// of the first one. Without it, a duplicate of a request in progress is rejected at once.
+		Locker *redislockhelper.RedisLockHelper
+		// LockPrefix namespaces the lock keys, e.g. `app:idempotency:lock:`, it falls back to `idempotency:lock:`.
+		LockPrefix string
+		// Methods requiring idempotency when the Idempotency-Key header is sent, it falls back to POST and PATCH.
+		Methods []string
+		// TTL of the stored responses, it falls back to 24h.
+		TTL time.Duration
+		// LockTimeout bounds the time a request holds the lock and its key stays reserved without a response,
+		// it falls back to 30s.
+		LockTimeout time.Duration
+		LockWait    time.Duration
+	}
+
+	idempotencyResponseWriter struct {
+		http.ResponseWriter
+		body bytes.Buffer
+	}
+)
+
+// Idempotency stores the status code, headers and body of the requests sending an Idempotency-Key, and replays them to
+// the retries of the same request. Keys are scoped by the JWT subject when present. Server errors aren't stored, so they
+// can be retried.
+func Idempotency(config IdempotencyConfig) echo.MiddlewareFunc {
+	if config.Store == nil {
+		loghelper.Logger.Panic("idempotency store must specific")
+	}
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+	if len(config.Methods) == 0 {
+		config.Methods = []string{http.MethodPost, http.MethodPatch}
+	}
+	if config.TTL <= 0 {
+		config.TTL = defaultIdempotencyTTL
+	}
+	if config.LockTimeout <= 0 {
+		config.LockTimeout = defaultIdempotencyLockTimeout
+	}
+	if config.LockWait <= 0 {
+		config.LockWait = defaultIdempotencyLockWait
+	}
+	if config.LockPrefix == "" {
+		config.LockPrefix = defaultIdempotencyLockPrefix
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			key := req.Header.Get(HeaderIdempotencyKey)
+			if key == "" || config.Skipper(c) || !slices.Contains(config.Methods, req.Method) {
+				return next(c)
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				return ErrIdempotencyKeyInvalid
+			}
+			if subject, _ := c.Get(string(commonhelper.ContextKeyType_Subject)).(string); subject != "" {
+				key = subject + ":" + key
+			}
+
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				return err
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			ctx := req.Context()
+
+			if config.Locker != nil {
+				lock, err := config.Locker.ObtainWithWait(ctx, config.LockPrefix+key, config.LockTimeout, config.LockWait, idempotencyLockRetryInterval)
+				if errors.Is(err, redislock.ErrNotObtained) {
+					return ErrIdempotencyKeyInProgress
+				}
+				if err != nil {
+					return echo.ErrServiceUnavailable.WithInternal(err)
+				}
+				defer func() {
+					if err := lock.Release(context.WithoutCancel(ctx)); err != nil && !errors.Is(err, redislock.ErrLockNotHeld) {
+						loghelper.Logger.WithContext(ctx).Warnw("release idempotency lock failed", zap.Error(err))
+					}
+				}()
+			}
+
+			record := &idempotencyhelper.Record{
+				Key:         key,
+				RequestHash: idempotencyhelper.HashRequest(req.Method, req.URL.RequestURI(), body),
+				State:       idempotencyhelper.RecordState_Processing,
+			}
+			// The reservation only outlives a crashed replica by LockTimeout, the response is kept for TTL
+			existing, err := config.Store.Reserve(ctx, record, config.LockTimeout)
+			if err != nil {
+				return echo.ErrServiceUnavailable.WithInternal(err)
+			}
+			if existing != nil {
+				switch {
+				case existing.RequestHash != record.RequestHash:
+					return ErrIdempotencyKeyReused
+				case existing.State != idempotencyhelper.RecordState_Completed:
+					return ErrIdempotencyKeyInProgress
+				}
+				return replayIdempotentResponse(c, existing)
+			}
+
+			// The key is released unless a response is stored, e.g. the handler failed or panicked, so it can be retried
+			completed := false
+			defer func() {
+				if completed {
+					return
+				}
+				if err := config.Store.Delete(context.WithoutCancel(ctx), key); err != nil {
+					loghelper.Logger.WithContext(ctx).Errorw("release idempotency key failed", zap.String("key", key), zap.Error(err))
+				}
+			}()
+
+			writer := &idempotencyResponseWriter{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = writer
+			// Errors are rendered here so their response is stored too
+			if err = next(c); err != nil {
+				c.Error(err)
+			}
+			c.Response().Writer = writer.ResponseWriter
+
+			if c.Response().Status >= http.StatusInternalServerError {
+				return nil
+			}
+			record.State = idempotencyhelper.RecordState_Completed
+			record.StatusCode = c.Response().Status
+			header := c.Response().Header().Clone()
+			for _, name := range idempotencyRequestHeaders {
+				header.Del(name)
+			}
+			record.Header = header
+			record.Body = writer.body.Bytes()
+			if err := config.Store.Save(context.WithoutCancel(ctx), record, config.TTL); err != nil {
+				loghelper.Logger.WithContext(ctx).Errorw("store idempotent response failed", zap.String("key", key), zap.Error(err))
+				return nil
+			}
+			completed = true
+			return nil
+		}
+	}
+}
+
+func replayIdempotentResponse(c echo.Context, record *idempotencyhelper.Record) error {
+	header := c.Response().Header()
+	for name, values := range record.Header {
+		if !isIdempotencyRequestHeader(name) {
+			header[name] = values
+		}
+	}
+	header.Set(HeaderIdempotentReplayed, "true")
+	c.Response().WriteHeader(record.StatusCode)
+	_, err := c.Response().Write(record.Body)
+	return err
+}
+
+func isIdempotencyRequestHeader(name string) bool {
+	for _, requestHeader := range idempotencyRequestHeaders {
+		if http.CanonicalHeaderKey(requestHeader) == http.CanonicalHeaderKey(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *idempotencyResponseWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *idempotencyResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,130 @@
+package echohelper
+
+import (
+	"go-clean-arch/helper-libs/cachehelper"
+	"go-clean-arch/helper-libs/idempotencyhelper"
+	"go-clean-arch/helper-libs/loghelper"
+	"go-clean-arch/helper-libs/redisclienthelper"
+	"go-clean-arch/helper-libs/redislockhelper"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	echo "github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	redis "github.com/redis/go-redis/v9"
+)
+
+func TestIdempotency(t *testing.T) {
+	if err := loghelper.InitZap("test", "dev", nil); err != nil {
+		t.Fatal(err)
+	}
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	redisClient := &redisclienthelper.RedisClientHelper{Client: client}
+
+	var calls atomic.Int32
+	e := echo.New()
+	e.Use(middleware.RecoverWithConfig(middleware.RecoverConfig{
+		LogErrorFunc: func(c echo.Context, err error, stack []byte) error { return err },
+	}))
+	e.Use(Trace())
+	e.Use(Idempotency(IdempotencyConfig{
+		Store: idempotencyhelper.NewRedisStore(&idempotencyhelper.RedisStoreOptions{
+			CacheHelper: cachehelper.NewCacheHelper(&cachehelper.CacheConfigOptions{RedisClientHelper: redisClient}),
+		}),
+		Locker:     redislockhelper.NewRedisLockerHelper(&redislockhelper.RedisLockerOptions{RedisClientHelper: redisClient}),
+		LockPrefix: "app:idempotency:lock:",
+	}))
+	e.POST("/v1/payments", func(c echo.Context) error {
+		n := calls.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		c.Response().Header().Set("X-Payment-Id", "pay-1")
+		return c.JSON(http.StatusCreated, map[string]int32{"call": n})
+	})
+	e.POST("/v1/failures", func(c echo.Context) error {
+		calls.Add(1)
+		return echo.ErrInternalServerError
+	})
+	e.POST("/v1/panics", func(c echo.Context) error {
+		calls.Add(1)
+		panic("failed")
+	})
+	var reservedTTL time.Duration
+	var locked bool
+	e.POST("/v1/refunds", func(c echo.Context) error {
+		reservedTTL = server.TTL("idempotency:key-4")
+		locked = server.Exists("app:idempotency:lock:key-4")
+		return c.NoContent(http.StatusCreated)
+	})
+	serve := func(path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set(HeaderIdempotencyKey, key)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Concurrent duplicates wait for the first request and replay its response
+	var wg sync.WaitGroup
+	recs := make([]*httptest.ResponseRecorder, 3)
+	for i := range recs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			recs[i] = serve("/v1/payments", "key-1", `{"amount":10}`)
+		}(i)
+	}
+	wg.Wait()
+	if calls.Load() != 1 {
+		t.Fatalf("expected the handler to run once, actual: %d", calls.Load())
+	}
+	replayed := 0
+	requestIds := map[string]bool{}
+	for _, rec := range recs {
+		requestIds[rec.Header().Get(echo.HeaderXRequestID)] = true
+		if rec.Code != http.StatusCreated || strings.TrimSpace(rec.Body.String()) != `{"call":1}` || rec.Header().Get("X-Payment-Id") != "pay-1" {
+			t.Errorf("expected the stored response, actual: %d %s %v", rec.Code, rec.Body.String(), rec.Header())
+		}
+		if rec.Header().Get(HeaderIdempotentReplayed) == "true" {
+			replayed++
+		}
+	}
+	if replayed != 2 {
+		t.Errorf("expected 2 replayed responses, actual: %d", replayed)
+	}
+	if len(requestIds) != len(recs) {
+		t.Errorf("expected the request id of each request to be kept on replay, actual: %v", requestIds)
+	}
+
+	if rec := serve("/v1/payments", "key-1", `{"amount":20}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected a reused key to be rejected, actual: %d", rec.Code)
+	}
+
+	// Server errors aren't stored so the request can be retried
+	serve("/v1/failures", "key-2", "")
+	if rec := serve("/v1/failures", "key-2", ""); rec.Code != http.StatusInternalServerError || calls.Load() != 3 {
+		t.Errorf("expected the failed request to run again, actual: %d after %d calls", rec.Code, calls.Load())
+	}
+
+	// Panics release the key too
+	serve("/v1/panics", "key-3", "")
+	if rec := serve("/v1/panics", "key-3", ""); rec.Code != http.StatusInternalServerError || calls.Load() != 5 {
+		t.Errorf("expected the panicked request to run again, actual: %d after %d calls", rec.Code, calls.Load())
+	}
+
+	// The key is reserved for the lock timeout and kept for the ttl once the response is stored
+	serve("/v1/refunds", "key-4", "")
+	if reservedTTL != defaultIdempotencyLockTimeout || !locked {
+		t.Errorf("expected the key reserved for %v under the lock prefix, actual: %v, locked: %v", defaultIdempotencyLockTimeout, reservedTTL, locked)
+	}
+	if ttl := server.TTL("idempotency:key-4"); ttl != defaultIdempotencyTTL {
+		t.Errorf("expected the response kept for %v, actual: %v", defaultIdempotencyTTL, ttl)
+	}
+}
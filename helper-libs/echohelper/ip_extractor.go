@@ -0,0 +1,27 @@
+package echohelper
+
+import (
+	"fmt"
+	"net"
+
+	echo "github.com/labstack/echo/v4"
+)
+
+// NewIPExtractor returns how c.RealIP() finds the client IP, rate limits and logs rely on it.
+// Without trusted proxies it is the address of the connection, X-Forwarded-For being set by anyone. Otherwise
+// X-Forwarded-For is walked from the right while the hops are trusted proxies, the ranges trusted by default by
+// echo (loopback, link-local and private networks) are not.
+func NewIPExtractor(trustedProxies []string) (echo.IPExtractor, error) {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect(), nil
+	}
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, cidr := range trustedProxies {
+		_, ipRange, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", cidr, err)
+		}
+		options = append(options, echo.TrustIPRange(ipRange))
+	}
+	return echo.ExtractIPFromXFFHeader(options...), nil
+}
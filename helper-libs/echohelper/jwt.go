@@ -0,0 +1,249 @@
+package echohelper
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"go-clean-arch/helper-libs/commonhelper"
+	"go-clean-arch/helper-libs/confighelper"
+	"go-clean-arch/helper-libs/loghelper"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	echo "github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"go.uber.org/zap"
+)
+
+const (
+	authSchemeBearer = "Bearer"
+
+	defaultJwksRefreshInterval = 15 * time.Minute
+)
+
+var (
+	ErrJWTMissing = echo.NewHTTPError(http.StatusUnauthorized, "missing or malformed bearer token")
+	ErrJWTInvalid = echo.NewHTTPError(http.StatusUnauthorized, "invalid or expired bearer token")
+)
+
+type (
+	// KeySetFunc returns the keys verifying token signatures, it is called for every request so it must be cheap.
+	KeySetFunc func(ctx context.Context) (jwk.Set, error)
+
+	JWTConfig struct {
+		Skipper middleware.Skipper
+		// ExcludePaths are served without token, an entry ending with `*` matches every path under its prefix,
+		// other entries follow path.Match.
+		ExcludePaths []string
+		KeySet       KeySetFunc
+		Issuer       string
+		// Audiences accepted by the service, a token is valid when it carries at least one of them.
+		Audiences []string
+		// Leeway tolerates clock skew when checking exp, nbf and iat.
+		Leeway time.Duration
+		// AppSubjectClaim names the claim stored under commonhelper.ContextKeyType_AppSubject, `sub` is used when empty or absent.
+		AppSubjectClaim string
+	}
+)
+
+// JWTWithConfig authenticates requests with the bearer token of the Authorization header.
+// The subject of a valid token is stored in the request context under commonhelper.ContextKeyType_Subject and
+// commonhelper.ContextKeyType_AppSubject, so entities record it as created/modified user, the raw token under
+// commonhelper.HeaderKeyType_Token.
+func JWTWithConfig(config JWTConfig) echo.MiddlewareFunc {
+	if config.KeySet == nil {
+		loghelper.Logger.Panic("jwt key set must specific")
+	}
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) || MatchPath(config.ExcludePaths, c.Request().URL.Path) {
+				return next(c)
+			}
+
+			req := c.Request()
+			rawToken, ok := bearerToken(req.Header.Get(echo.HeaderAuthorization))
+			if !ok {
+				return unauthorized(c, ErrJWTMissing)
+			}
+
+			token, err := config.parse(req.Context(), rawToken)
+			if err != nil {
+				loghelper.Logger.WithContext(req.Context()).Infow("reject bearer token", zap.Error(err))
+				return unauthorized(c, ErrJWTInvalid)
+			}
+
+			appSubject := token.Subject()
+			if config.AppSubjectClaim != "" {
+				if claim, ok := token.Get(config.AppSubjectClaim); ok {
+					if value, ok := claim.(string); ok && value != "" {
+						appSubject = value
+					}
+				}
+			}
+
+			ctx := context.WithValue(req.Context(), commonhelper.ContextKeyType_Subject, token.Subject())
+			ctx = context.WithValue(ctx, commonhelper.ContextKeyType_AppSubject, appSubject)
+			ctx = context.WithValue(ctx, commonhelper.HeaderKeyType_Token, rawToken)
+			c.SetRequest(req.WithContext(ctx))
+			c.Set(string(commonhelper.ContextKeyType_Subject), token.Subject())
+			c.Set(string(commonhelper.ContextKeyType_AppSubject), appSubject)
+
+			return next(c)
+		}
+	}
+}
+
+func (config *JWTConfig) parse(ctx context.Context, rawToken string) (jwt.Token, error) {
+	keySet, err := config.KeySet(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load key set: %w", err)
+	}
+
+	options := []jwt.ParseOption{
+		jwt.WithKeySet(keySet, jws.WithUseDefault(true), jws.WithInferAlgorithmFromKey(true)),
+		jwt.WithValidate(true),
+		jwt.WithRequiredClaim(jwt.ExpirationKey),
+		jwt.WithAcceptableSkew(config.Leeway),
+	}
+	if config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(config.Issuer))
+	}
+
+	token, err := jwt.ParseString(rawToken, options...)
+	if err != nil {
+		return nil, err
+	}
+	if len(config.Audiences) > 0 && !containsAny(token.Audience(), config.Audiences) {
+		return nil, fmt.Errorf("audience %v is not accepted", token.Audience())
+	}
+	return token, nil
+}
+
+// NewKeySet builds the key set described by `cfg`: a JWKS endpoint refreshed in background when `jwks_url` is set,
+// otherwise the static public key (file or base64 PEM) or hmac secret.
+func NewKeySet(ctx context.Context, cfg *confighelper.JwtConfig) (KeySetFunc, error) {
+	if cfg.JwksUrl != "" {
+		return NewJWKSKeySet(ctx, cfg.JwksUrl, cfg.JwksRefreshInterval)
+	}
+
+	var (
+		key []byte
+		err error
+	)
+	switch {
+	case cfg.PublicKeyFile != "":
+		key, err = os.ReadFile(cfg.PublicKeyFile)
+	case cfg.PublicKeyBase64 != "":
+		key, err = base64.StdEncoding.DecodeString(cfg.PublicKeyBase64)
+	case cfg.HmacSecret != "":
+		key = []byte(cfg.HmacSecret)
+	default:
+		return nil, errors.New("jwt requires jwks_url, public_key_file, public_key_base64 or hmac_secret")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read jwt key: %w", err)
+	}
+	return NewStaticKeySet(key, cfg.Algorithm)
+}
+
+// NewJWKSKeySet fetches the JWKS of `url` and refreshes it in background until ctx is done.
+func NewJWKSKeySet(ctx context.Context, url string, refreshInterval time.Duration) (KeySetFunc, error) {
+	if refreshInterval <= 0 {
+		refreshInterval = defaultJwksRefreshInterval
+	}
+
+	cache := jwk.NewCache(ctx)
+	if err := cache.Register(url, jwk.WithMinRefreshInterval(refreshInterval)); err != nil {
+		return nil, fmt.Errorf("register jwks %s: %w", url, err)
+	}
+	if _, err := cache.Refresh(ctx, url); err != nil {
+		return nil, fmt.Errorf("fetch jwks %s: %w", url, err)
+	}
+
+	return func(ctx context.Context) (jwk.Set, error) {
+		return cache.Get(ctx, url)
+	}, nil
+}
+
+// NewStaticKeySet wraps a single key, a PEM encoded public key for asymmetric algorithms or the secret of HS algorithms.
+func NewStaticKeySet(key []byte, algorithm string) (KeySetFunc, error) {
+	var (
+		jwkKey jwk.Key
+		err    error
+	)
+	if strings.HasPrefix(strings.ToUpper(algorithm), "HS") {
+		jwkKey, err = jwk.FromRaw(key)
+	} else {
+		jwkKey, err = jwk.ParseKey(key, jwk.WithPEM(true))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse jwt key: %w", err)
+	}
+	if algorithm != "" {
+		if err = jwkKey.Set(jwk.AlgorithmKey, jwa.SignatureAlgorithm(algorithm)); err != nil {
+			return nil, err
+		}
+	}
+
+	keySet := jwk.NewSet()
+	if err = keySet.AddKey(jwkKey); err != nil {
+		return nil, err
+	}
+	return func(context.Context) (jwk.Set, error) {
+		return keySet, nil
+	}, nil
+}
+
+// MatchPath reports whether `requestPath` matches one of `patterns`, an entry ending with `*` matches its whole prefix.
+func MatchPath(patterns []string, requestPath string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(requestPath, prefix) {
+			return true
+		}
+		if matched, _ := path.Match(pattern, requestPath); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func bearerToken(authorization string) (string, bool) {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(authorization), " ")
+	if !ok || !strings.EqualFold(scheme, authSchemeBearer) {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+func unauthorized(c echo.Context, err *echo.HTTPError) error {
+	c.Response().Header().Set(echo.HeaderWWWAuthenticate, authSchemeBearer)
+	return err
+}
+
+func containsAny(values []string, accepted []string) bool {
+	for _, value := range values {
+		for _, candidate := range accepted {
+			if value == candidate {
+				return true
+			}
+		}
+	}
+	return false
+}
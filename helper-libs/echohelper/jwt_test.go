@@ -0,0 +1,155 @@
+package echohelper
+
+import (
+	"go-clean-arch/helper-libs/commonhelper"
+	"go-clean-arch/helper-libs/loghelper"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	echo "github.com/labstack/echo/v4"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+func TestJWTWithConfig(t *testing.T) {
+	if err := loghelper.InitZap("test", "dev", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	keySet, err := NewStaticKeySet(secret, "HS256")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(claims map[string]interface{}, key []byte) string {
+		token := jwt.New()
+		for name, value := range claims {
+			if err := token.Set(name, value); err != nil {
+				t.Fatal(err)
+			}
+		}
+		signed, err := jwt.Sign(token, jwt.WithKey(jwa.HS256, key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "Bearer " + string(signed)
+	}
+	validClaims := func() map[string]interface{} {
+		return map[string]interface{}{
+			jwt.SubjectKey:    "user-1",
+			jwt.IssuerKey:     "https://issuer",
+			jwt.AudienceKey:   []string{"other", "card-api"},
+			jwt.ExpirationKey: time.Now().Add(time.Minute),
+			"appsub":          "app-user-1",
+		}
+	}
+
+	tests := []struct {
+		name               string
+		path               string
+		authorization      string
+		expectedStatus     int
+		expectedAppSubject string
+	}{
+		{
+			name:               "valid token",
+			path:               "/v1/cards",
+			authorization:      sign(validClaims(), secret),
+			expectedStatus:     http.StatusOK,
+			expectedAppSubject: "app-user-1",
+		},
+		{
+			name:           "missing token",
+			path:           "/v1/cards",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "excluded path",
+			path:           "/v1/health/liveness",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "expired token",
+			path: "/v1/cards",
+			authorization: sign(func() map[string]interface{} {
+				claims := validClaims()
+				claims[jwt.ExpirationKey] = time.Now().Add(-time.Hour)
+				return claims
+			}(), secret),
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "token without expiry",
+			path: "/v1/cards",
+			authorization: sign(func() map[string]interface{} {
+				claims := validClaims()
+				delete(claims, jwt.ExpirationKey)
+				return claims
+			}(), secret),
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "wrong issuer",
+			path: "/v1/cards",
+			authorization: sign(func() map[string]interface{} {
+				claims := validClaims()
+				claims[jwt.IssuerKey] = "https://other"
+				return claims
+			}(), secret),
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "wrong audience",
+			path: "/v1/cards",
+			authorization: sign(func() map[string]interface{} {
+				claims := validClaims()
+				claims[jwt.AudienceKey] = []string{"other"}
+				return claims
+			}(), secret),
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "wrong signature",
+			path:           "/v1/cards",
+			authorization:  sign(validClaims(), []byte("another-secret-another-secret-00")),
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var appSubject interface{}
+			handler := JWTWithConfig(JWTConfig{
+				ExcludePaths:    []string{"/metrics", "/v1/health/*"},
+				KeySet:          keySet,
+				Issuer:          "https://issuer",
+				Audiences:       []string{"card-api"},
+				AppSubjectClaim: "appsub",
+			})(func(c echo.Context) error {
+				appSubject = c.Request().Context().Value(commonhelper.ContextKeyType_AppSubject)
+				return c.NoContent(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.authorization != "" {
+				req.Header.Set(echo.HeaderAuthorization, tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			err := handler(echo.New().NewContext(req, rec))
+
+			status := rec.Code
+			if httpErr, ok := err.(*echo.HTTPError); ok {
+				status = httpErr.Code
+			}
+			if status != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d (err %v)", tt.expectedStatus, status, err)
+			}
+			if tt.expectedAppSubject != "" && appSubject != tt.expectedAppSubject {
+				t.Errorf("expected app subject %q, got %v", tt.expectedAppSubject, appSubject)
+			}
+		})
+	}
+}
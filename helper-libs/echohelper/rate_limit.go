@@ -0,0 +1,20 @@
+package echohelper
+
+import (
+	"net/http"
+
+	echo "github.com/labstack/echo/v4"
+)
+
+var (
+	ErrRateLimitExceeded = echo.NewHTTPError(http.StatusTooManyRequests, "rate limit exceeded")
+)
+
+type (
+	// RateLimiter limits the requests of every client, the limit can be changed while serving.
+	RateLimiter interface {
+		Middleware() echo.MiddlewareFunc
+		// SetRate changes the limit of every client, zero or less disables limiting.
+		SetRate(requests int64)
+	}
+)
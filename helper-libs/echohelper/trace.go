@@ -0,0 +1,119 @@
+package echohelper
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"go-clean-arch/helper-libs/commonhelper"
+	"go-clean-arch/helper-libs/uuidhelper"
+	"regexp"
+	"strings"
+
+	echo "github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+const (
+	HeaderTraceparent = "traceparent"
+
+	maxRequestIdLength = 128
+)
+
+var (
+	// version-traceid-parentid-flags, see https://www.w3.org/TR/trace-context/#traceparent-header
+	traceparentRegexp = regexp.MustCompile(`^([0-9a-f]{2})-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})$`)
+	requestIdRegexp   = regexp.MustCompile(`^[A-Za-z0-9._:/+=\-]+$`)
+)
+
+type (
+	TraceConfig struct {
+		Skipper middleware.Skipper
+		// Generator creates the trace id when the request carries neither X-Request-Id nor traceparent.
+		Generator func() string
+	}
+)
+
+var (
+	DefaultTraceConfig = TraceConfig{
+		Skipper:   middleware.DefaultSkipper,
+		Generator: uuidhelper.CreateTraceId,
+	}
+)
+
+// Trace stores the trace id of every request in its context under commonhelper.ContextKeyType_TraceId.
+func Trace() echo.MiddlewareFunc {
+	return TraceWithConfig(DefaultTraceConfig)
+}
+
+// TraceWithConfig accepts the incoming X-Request-Id, or the trace id of a W3C traceparent, and generates one otherwise.
+// The id is echoed back in the X-Request-Id response header, together with a traceparent continuing the trace.
+func TraceWithConfig(config TraceConfig) echo.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = DefaultTraceConfig.Skipper
+	}
+	if config.Generator == nil {
+		config.Generator = DefaultTraceConfig.Generator
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			req := c.Request()
+			traceId := req.Header.Get(echo.HeaderXRequestID)
+			traceparentTraceId, traceFlags := parseTraceparent(req.Header.Get(HeaderTraceparent))
+			if !isValidRequestId(traceId) {
+				traceId = traceparentTraceId
+			}
+			if traceId == "" {
+				traceId = config.Generator()
+			}
+
+			c.SetRequest(req.WithContext(commonhelper.SetTraceIdToContext(req.Context(), traceId)))
+			c.Set(string(commonhelper.ContextKeyType_TraceId), traceId)
+
+			res := c.Response()
+			res.Header().Set(echo.HeaderXRequestID, traceId)
+			if traceparentTraceId == "" {
+				traceparentTraceId = toTraceparentTraceId(traceId)
+			}
+			if traceparentTraceId != "" {
+				res.Header().Set(HeaderTraceparent, "00-"+traceparentTraceId+"-"+newSpanId()+"-"+traceFlags)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// parseTraceparent returns the trace id and flags of a valid traceparent header.
+func parseTraceparent(traceparent string) (string, string) {
+	matches := traceparentRegexp.FindStringSubmatch(strings.TrimSpace(traceparent))
+	if matches == nil || matches[1] == "ff" || strings.Trim(matches[2], "0") == "" || strings.Trim(matches[3], "0") == "" {
+		return "", "01"
+	}
+	return matches[2], matches[4]
+}
+
+func isValidRequestId(requestId string) bool {
+	return requestId != "" && len(requestId) <= maxRequestIdLength && requestIdRegexp.MatchString(requestId)
+}
+
+// toTraceparentTraceId converts uuid based trace ids to the 32 hex digits of traceparent, other ids can't be converted.
+func toTraceparentTraceId(traceId string) string {
+	candidate := strings.ToLower(strings.ReplaceAll(traceId, "-", ""))
+	if len(candidate) != 32 || strings.Trim(candidate, "0") == "" {
+		return ""
+	}
+	if _, err := hex.DecodeString(candidate); err != nil {
+		return ""
+	}
+	return candidate
+}
+
+func newSpanId() string {
+	spanId := make([]byte, 8)
+	_, _ = rand.Read(spanId)
+	return hex.EncodeToString(spanId)
+}
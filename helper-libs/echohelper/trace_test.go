@@ -0,0 +1,82 @@
+package echohelper
+
+import (
+	"go-clean-arch/helper-libs/commonhelper"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	echo "github.com/labstack/echo/v4"
+)
+
+func TestTrace(t *testing.T) {
+	tests := []struct {
+		name            string
+		headers         map[string]string
+		expectedTraceId string
+	}{
+		{
+			name:            "keeps incoming request id",
+			headers:         map[string]string{echo.HeaderXRequestID: "req-123"},
+			expectedTraceId: "req-123",
+		},
+		{
+			name:            "uses traceparent trace id",
+			headers:         map[string]string{HeaderTraceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+			expectedTraceId: "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			name: "request id wins over traceparent",
+			headers: map[string]string{
+				echo.HeaderXRequestID: "req-123",
+				HeaderTraceparent:     "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			},
+			expectedTraceId: "req-123",
+		},
+		{
+			name:            "ignores invalid traceparent",
+			headers:         map[string]string{HeaderTraceparent: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+			expectedTraceId: "generated-id",
+		},
+		{
+			name:            "ignores invalid request id",
+			headers:         map[string]string{echo.HeaderXRequestID: "bad id\nwith newline"},
+			expectedTraceId: "generated-id",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			var contextTraceId string
+			handler := TraceWithConfig(TraceConfig{Generator: func() string { return "generated-id" }})(func(c echo.Context) error {
+				contextTraceId = commonhelper.GetTraceIdFromContext(c.Request().Context())
+				return c.NoContent(http.StatusOK)
+			})
+			if err := handler(c); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if contextTraceId != tt.expectedTraceId {
+				t.Errorf("expected context trace id: %v, actual: %v", tt.expectedTraceId, contextTraceId)
+			}
+			if actual := rec.Header().Get(echo.HeaderXRequestID); actual != tt.expectedTraceId {
+				t.Errorf("expected response request id: %v, actual: %v", tt.expectedTraceId, actual)
+			}
+			if traceparent := rec.Header().Get(HeaderTraceparent); traceparent != "" && !traceparentRegexp.MatchString(traceparent) {
+				t.Errorf("invalid response traceparent: %v", traceparent)
+			}
+			if strings.HasPrefix(tt.expectedTraceId, "4bf92f") && !strings.Contains(rec.Header().Get(HeaderTraceparent), tt.expectedTraceId) {
+				t.Errorf("expected traceparent to continue trace %v, actual: %v", tt.expectedTraceId, rec.Header().Get(HeaderTraceparent))
+			}
+		})
+	}
+}
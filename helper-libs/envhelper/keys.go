@@ -3,4 +3,12 @@ package envhelper
 const (
 	ENVIRONMENT string = "ENV"
 	LOG_LEVEL   string = "LOG_LEVEL"
+
+	CONFIG_FILE                  string = "CONFIG_FILE"
+	CONFIG_REMOTE_PROVIDER       string = "CONFIG_REMOTE_PROVIDER"
+	CONFIG_REMOTE_ENDPOINT       string = "CONFIG_REMOTE_ENDPOINT"
+	CONFIG_REMOTE_PATH           string = "CONFIG_REMOTE_PATH"
+	CONFIG_REMOTE_SECRET_KEYRING string = "CONFIG_REMOTE_SECRET_KEYRING"
+	CONFIG_REMOTE_WATCH_INTERVAL string = "CONFIG_REMOTE_WATCH_INTERVAL"
+	CONFIG_SECRET_KEY_FILE       string = "CONFIG_SECRET_KEY_FILE"
 )
@@ -0,0 +1,195 @@
+package healthhelper
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultCheckTimeout = 2 * time.Second
+)
+
+type Status string
+
+const (
+	Status_Up       Status = "UP"
+	Status_Degraded Status = "DEGRADED"
+	Status_Down     Status = "DOWN"
+)
+
+type (
+	// CheckFunc reports the health of a single component, a nil error means healthy.
+	CheckFunc func(ctx context.Context) error
+
+	Checker struct {
+		Name string
+		// Check is executed with a context bounded by Timeout.
+		Check CheckFunc
+		// Timeout falls back to DefaultCheckTimeout when zero.
+		Timeout time.Duration
+		// Critical components turn the whole report DOWN when they fail,
+		// non-critical ones only degrade it.
+		Critical bool
+	}
+
+	ComponentReport struct {
+		Name      string `json:"name"`
+		Status    Status `json:"status"`
+		Critical  bool   `json:"critical"`
+		LatencyMs int64  `json:"latency_ms"`
+		Error     string `json:"error,omitempty"`
+	}
+
+	Report struct {
+		Status     Status            `json:"status"`
+		Components []ComponentReport `json:"components"`
+	}
+
+	Registry interface {
+		Register(checkers ...Checker)
+		Check(ctx context.Context) *Report
+		// MarkShuttingDown makes every following Check report DOWN, so load balancers stop routing before the server drains.
+		MarkShuttingDown()
+	}
+
+	registry struct {
+		mu           sync.RWMutex
+		checkers     []Checker
+		shuttingDown atomic.Bool
+	}
+)
+
+const (
+	shutdownComponentName = "lifecycle"
+)
+
+var (
+	ErrCheckTimeout = errors.New("health check timed out")
+	ErrShuttingDown = errors.New("service is shutting down")
+)
+
+func NewRegistry() Registry {
+	return &registry{}
+}
+
+// Register adds checkers to the registry, a checker with an already registered name replaces the previous one.
+func (r *registry) Register(checkers ...Checker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, checker := range checkers {
+		if checker.Check == nil {
+			continue
+		}
+		if checker.Timeout <= 0 {
+			checker.Timeout = DefaultCheckTimeout
+		}
+
+		replaced := false
+		for i := range r.checkers {
+			if r.checkers[i].Name == checker.Name {
+				r.checkers[i] = checker
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			r.checkers = append(r.checkers, checker)
+		}
+	}
+}
+
+func (r *registry) MarkShuttingDown() {
+	r.shuttingDown.Store(true)
+}
+
+// Check runs every registered checker concurrently and aggregates the results in registration order.
+func (r *registry) Check(ctx context.Context) *Report {
+	if r.shuttingDown.Load() {
+		return &Report{
+			Status: Status_Down,
+			Components: []ComponentReport{{
+				Name:     shutdownComponentName,
+				Status:   Status_Down,
+				Critical: true,
+				Error:    ErrShuttingDown.Error(),
+			}},
+		}
+	}
+
+	r.mu.RLock()
+	checkers := make([]Checker, len(r.checkers))
+	copy(checkers, r.checkers)
+	r.mu.RUnlock()
+
+	report := &Report{
+		Status:     Status_Up,
+		Components: make([]ComponentReport, len(checkers)),
+	}
+
+	var wg sync.WaitGroup
+	for i, checker := range checkers {
+		wg.Add(1)
+		go func(i int, checker Checker) {
+			defer wg.Done()
+			report.Components[i] = runChecker(ctx, checker)
+		}(i, checker)
+	}
+	wg.Wait()
+
+	for _, component := range report.Components {
+		if component.Status == Status_Up {
+			continue
+		}
+		if component.Critical {
+			report.Status = Status_Down
+			break
+		}
+		report.Status = Status_Degraded
+	}
+
+	return report
+}
+
+// IsReady reports whether every critical component is healthy.
+func (r *Report) IsReady() bool {
+	return r.Status != Status_Down
+}
+
+func runChecker(ctx context.Context, checker Checker) ComponentReport {
+	checkCtx, cancel := context.WithTimeout(ctx, checker.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	errChan := make(chan error, 1)
+	go func() {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				errChan <- errors.New("health check panicked")
+			}
+		}()
+		errChan <- checker.Check(checkCtx)
+	}()
+
+	var err error
+	select {
+	case err = <-errChan:
+	case <-checkCtx.Done():
+		err = ErrCheckTimeout
+	}
+
+	component := ComponentReport{
+		Name:      checker.Name,
+		Status:    Status_Up,
+		Critical:  checker.Critical,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		component.Status = Status_Down
+		component.Error = err.Error()
+	}
+	return component
+}
@@ -0,0 +1,86 @@
+package healthhelper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRegistryCheck(t *testing.T) {
+	healthy := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return errors.New("connection refused") }
+	hanging := func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	}
+
+	tests := []struct {
+		name     string
+		checkers []Checker
+		expected Status
+		ready    bool
+	}{
+		{
+			name:     "all healthy",
+			checkers: []Checker{{Name: "postgres", Check: healthy, Critical: true}, {Name: "redis", Check: healthy}},
+			expected: Status_Up,
+			ready:    true,
+		},
+		{
+			name:     "non critical failure",
+			checkers: []Checker{{Name: "postgres", Check: healthy, Critical: true}, {Name: "leader", Check: failing}},
+			expected: Status_Degraded,
+			ready:    true,
+		},
+		{
+			name:     "critical failure",
+			checkers: []Checker{{Name: "postgres", Check: failing, Critical: true}, {Name: "leader", Check: failing}},
+			expected: Status_Down,
+			ready:    false,
+		},
+		{
+			name:     "critical timeout",
+			checkers: []Checker{{Name: "redis", Check: hanging, Timeout: 10 * time.Millisecond, Critical: true}},
+			expected: Status_Down,
+			ready:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewRegistry()
+			registry.Register(tt.checkers...)
+
+			report := registry.Check(context.Background())
+			if report.Status != tt.expected {
+				t.Errorf("expected: %v, actual: %v", tt.expected, report.Status)
+			}
+			if report.IsReady() != tt.ready {
+				t.Errorf("expected ready: %v, actual: %v", tt.ready, report.IsReady())
+			}
+			if len(report.Components) != len(tt.checkers) {
+				t.Fatalf("expected %d components, actual: %d", len(tt.checkers), len(report.Components))
+			}
+			for i, component := range report.Components {
+				if component.Name != tt.checkers[i].Name {
+					t.Errorf("expected component %q at %d, actual: %q", tt.checkers[i].Name, i, component.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestRegistryMarkShuttingDown(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(Checker{Name: "postgres", Check: func(ctx context.Context) error { return nil }, Critical: true})
+	if report := registry.Check(context.Background()); !report.IsReady() {
+		t.Fatalf("expected ready before shutdown, actual: %v", report.Status)
+	}
+
+	registry.MarkShuttingDown()
+	if report := registry.Check(context.Background()); report.IsReady() {
+		t.Errorf("expected not ready while shutting down, actual: %v", report.Status)
+	}
+}
@@ -0,0 +1,46 @@
+package idempotencyhelper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+type RecordState string
+
+const (
+	RecordState_Processing RecordState = "PROCESSING"
+	RecordState_Completed  RecordState = "COMPLETED"
+)
+
+type (
+	// Record is the response stored for an idempotency key, replayed to the retries of the same request.
+	Record struct {
+		Key         string              `json:"key"`
+		RequestHash string              `json:"requestHash"`
+		State       RecordState         `json:"state"`
+		StatusCode  int                 `json:"statusCode,omitempty"`
+		Header      map[string][]string `json:"header,omitempty"`
+		Body        []byte              `json:"body,omitempty"`
+	}
+
+	Store interface {
+		// Reserve stores record when its key is new and returns nil, otherwise it returns the stored record.
+		Reserve(ctx context.Context, record *Record, ttl time.Duration) (*Record, error)
+		// Save overwrites the record of its key, e.g. once the response is known.
+		Save(ctx context.Context, record *Record, ttl time.Duration) error
+		Delete(ctx context.Context, key string) error
+	}
+)
+
+// HashRequest identifies a request by its method, uri and body, a key reused for another request is rejected.
+func HashRequest(method, uri string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method))
+	hash.Write([]byte{'\n'})
+	hash.Write([]byte(uri))
+	hash.Write([]byte{'\n'})
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
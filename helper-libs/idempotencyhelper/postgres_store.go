@@ -0,0 +1,135 @@
+package idempotencyhelper
+
+import (
+	"context"
+	"errors"
+	"go-clean-arch/helper-libs/loghelper"
+	"go-clean-arch/helper-libs/sqlormhelper"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultPostgresTable = "idempotency_records"
+)
+
+type (
+	PostgresStoreOptions struct {
+		Sql sqlormhelper.SqlGormDatabase
+		// Table falls back to `idempotency_records`.
+		Table string
+	}
+
+	// PostgresStore keeps records for long retention, expired rows are replaced on reserve and removed by PurgeExpired.
+	PostgresStore interface {
+		Store
+		// Migrate creates the table and its expiry index.
+		Migrate(ctx context.Context) error
+		PurgeExpired(ctx context.Context) (int64, error)
+	}
+
+	idempotencyRecordEntity struct {
+		Key         string              `gorm:"column:key;primaryKey;type:text"`
+		RequestHash string              `gorm:"column:request_hash;type:text;not null"`
+		State       string              `gorm:"column:state;type:text;not null"`
+		StatusCode  int                 `gorm:"column:status_code"`
+		Header      map[string][]string `gorm:"column:header;type:jsonb;serializer:json"`
+		Body        []byte              `gorm:"column:body;type:bytea"`
+		ExpiresAt   time.Time           `gorm:"column:expires_at;type:timestamptz;not null;index"`
+		sqlormhelper.BaseEntity
+	}
+
+	postgresStore struct {
+		sql   sqlormhelper.SqlGormDatabase
+		table string
+	}
+)
+
+func NewPostgresStore(opts *PostgresStoreOptions) PostgresStore {
+	if opts.Sql == nil {
+		loghelper.Logger.Panic("sql gorm database must specific")
+	}
+	table := opts.Table
+	if table == "" {
+		table = defaultPostgresTable
+	}
+	return &postgresStore{
+		sql:   opts.Sql,
+		table: table,
+	}
+}
+
+func (s *postgresStore) Migrate(ctx context.Context) error {
+	return s.db(ctx).AutoMigrate(&idempotencyRecordEntity{})
+}
+
+func (s *postgresStore) Reserve(ctx context.Context, record *Record, ttl time.Duration) (*Record, error) {
+	now := time.Now()
+	err := s.db(ctx).Where("key = ? AND expires_at <= ?", record.Key, now).Delete(&idempotencyRecordEntity{}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := s.db(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(newIdempotencyRecordEntity(record, now.Add(ttl)))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return nil, nil
+	}
+
+	existing := &idempotencyRecordEntity{}
+	if err = s.db(ctx).Where("key = ?", record.Key).Take(existing).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("idempotency key expired while reserving " + record.Key)
+		}
+		return nil, err
+	}
+	return existing.toRecord(), nil
+}
+
+func (s *postgresStore) Save(ctx context.Context, record *Record, ttl time.Duration) error {
+	entity := newIdempotencyRecordEntity(record, time.Now().Add(ttl))
+	return s.db(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"request_hash", "state", "status_code", "header", "body", "expires_at", "last_modified_time"}),
+	}).Create(entity).Error
+}
+
+func (s *postgresStore) Delete(ctx context.Context, key string) error {
+	return s.db(ctx).Where("key = ?", key).Delete(&idempotencyRecordEntity{}).Error
+}
+
+func (s *postgresStore) PurgeExpired(ctx context.Context) (int64, error) {
+	result := s.db(ctx).Where("expires_at <= ?", time.Now()).Delete(&idempotencyRecordEntity{})
+	return result.RowsAffected, result.Error
+}
+
+func (s *postgresStore) db(ctx context.Context) *gorm.DB {
+	return s.sql.Open().WithContext(ctx).Table(s.table)
+}
+
+func newIdempotencyRecordEntity(record *Record, expiresAt time.Time) *idempotencyRecordEntity {
+	return &idempotencyRecordEntity{
+		Key:         record.Key,
+		RequestHash: record.RequestHash,
+		State:       string(record.State),
+		StatusCode:  record.StatusCode,
+		Header:      record.Header,
+		Body:        record.Body,
+		ExpiresAt:   expiresAt,
+	}
+}
+
+func (e *idempotencyRecordEntity) toRecord() *Record {
+	return &Record{
+		Key:         e.Key,
+		RequestHash: e.RequestHash,
+		State:       RecordState(e.State),
+		StatusCode:  e.StatusCode,
+		Header:      e.Header,
+		Body:        e.Body,
+	}
+}
@@ -0,0 +1,71 @@
+package idempotencyhelper
+
+import (
+	"context"
+	"errors"
+	"go-clean-arch/helper-libs/cachehelper"
+	"go-clean-arch/helper-libs/loghelper"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultRedisKeyPrefix = "idempotency:"
+)
+
+type (
+	RedisStoreOptions struct {
+		CacheHelper cachehelper.CacheHelper
+		// KeyPrefix falls back to `idempotency:`.
+		KeyPrefix string
+	}
+
+	redisStore struct {
+		cache     cachehelper.CacheHelper
+		keyPrefix string
+	}
+)
+
+// NewRedisStore reserves keys with SetNX, records expire with their ttl.
+func NewRedisStore(opts *RedisStoreOptions) Store {
+	if opts.CacheHelper == nil {
+		loghelper.Logger.Panic("cache helper must specific")
+	}
+	keyPrefix := opts.KeyPrefix
+	if keyPrefix == "" {
+		keyPrefix = defaultRedisKeyPrefix
+	}
+	return &redisStore{
+		cache:     opts.CacheHelper,
+		keyPrefix: keyPrefix,
+	}
+}
+
+func (s *redisStore) Reserve(ctx context.Context, record *Record, ttl time.Duration) (*Record, error) {
+	// The stored record may expire between SetNX and Get, the key is then reserved again
+	for attempt := 0; attempt < 2; attempt++ {
+		reserved, err := s.cache.SetNX(ctx, s.keyPrefix+record.Key, record, ttl)
+		if err != nil || reserved {
+			return nil, err
+		}
+		existing := &Record{}
+		err = s.cache.Get(ctx, s.keyPrefix+record.Key, existing)
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return existing, nil
+	}
+	return nil, errors.New("idempotency key expired while reserving " + record.Key)
+}
+
+func (s *redisStore) Save(ctx context.Context, record *Record, ttl time.Duration) error {
+	return s.cache.Set(ctx, s.keyPrefix+record.Key, record, ttl)
+}
+
+func (s *redisStore) Delete(ctx context.Context, key string) error {
+	return s.cache.Del(ctx, s.keyPrefix+key)
+}
@@ -0,0 +1,148 @@
+package lifecyclehelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-clean-arch/helper-libs/loghelper"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultStageTimeout = 10 * time.Second
+)
+
+var (
+	ErrStageTimeout = errors.New("shutdown stage timed out")
+)
+
+type (
+	HookFunc func(ctx context.Context) error
+
+	// Stage is a step of the shutdown, e.g. draining http requests or closing the database pools.
+	Stage struct {
+		Name string
+		// Timeout bounds Hook, it falls back to DefaultStageTimeout when zero.
+		Timeout time.Duration
+		Hook    HookFunc
+	}
+
+	Manager interface {
+		// OnShutdown appends stages, they run one after another in registration order.
+		OnShutdown(stages ...Stage)
+		// Wait blocks until one of the signals is received then runs Shutdown.
+		// A second signal falls back to the default behaviour and kills the process.
+		Wait() error
+		// Shutdown runs every stage once, a failing or timed out stage doesn't prevent the next ones from running.
+		Shutdown(ctx context.Context) error
+	}
+
+	ManagerOptions struct {
+		// Signals fall back to SIGINT and SIGTERM when empty.
+		Signals []os.Signal
+	}
+
+	manager struct {
+		mu           sync.Mutex
+		signals      []os.Signal
+		stages       []Stage
+		shutdownOnce sync.Once
+		shutdownErr  error
+	}
+)
+
+func NewManager(opts *ManagerOptions) Manager {
+	signals := opts.Signals
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	}
+	return &manager{
+		signals: signals,
+	}
+}
+
+func (m *manager) OnShutdown(stages ...Stage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stages = append(m.stages, stages...)
+}
+
+func (m *manager) Wait() error {
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, m.signals...)
+	received := <-signalChan
+	signal.Stop(signalChan)
+
+	loghelper.Logger.Infow("received shutdown signal", zap.String("signal", received.String()))
+	return m.Shutdown(context.Background())
+}
+
+func (m *manager) Shutdown(ctx context.Context) error {
+	m.shutdownOnce.Do(func() {
+		m.mu.Lock()
+		stages := make([]Stage, len(m.stages))
+		copy(stages, m.stages)
+		m.mu.Unlock()
+
+		var errs []error
+		for _, stage := range stages {
+			start := time.Now()
+			err := runStage(ctx, stage)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", stage.Name, err))
+				loghelper.Logger.Errorw("shutdown stage failed", zap.String("stage", stage.Name), zap.Duration("elapsed", time.Since(start)), zap.Error(err))
+				continue
+			}
+			loghelper.Logger.Infow("shutdown stage completed", zap.String("stage", stage.Name), zap.Duration("elapsed", time.Since(start)))
+		}
+		m.shutdownErr = errors.Join(errs...)
+	})
+	return m.shutdownErr
+}
+
+func runStage(ctx context.Context, stage Stage) error {
+	if stage.Hook == nil {
+		return nil
+	}
+	timeout := stage.Timeout
+	if timeout <= 0 {
+		timeout = DefaultStageTimeout
+	}
+	stageCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	errChan := make(chan error, 1)
+	go func() {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				errChan <- fmt.Errorf("shutdown stage panicked: %v", recovered)
+			}
+		}()
+		errChan <- stage.Hook(stageCtx)
+	}()
+
+	select {
+	case err := <-errChan:
+		return err
+	case <-stageCtx.Done():
+		return ErrStageTimeout
+	}
+}
+
+// Sleep waits for d unless ctx is done first, it lets load balancers observe a failing readiness before draining.
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
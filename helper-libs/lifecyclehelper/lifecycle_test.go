@@ -0,0 +1,65 @@
+package lifecyclehelper
+
+import (
+	"context"
+	"errors"
+	"go-clean-arch/helper-libs/loghelper"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestManagerShutdown(t *testing.T) {
+	if err := loghelper.InitZap("test", "dev", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	appendOrder := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, name)
+	}
+	record := func(name string, err error) HookFunc {
+		return func(ctx context.Context) error {
+			appendOrder(name)
+			return err
+		}
+	}
+	errClose := errors.New("close failed")
+
+	manager := NewManager(&ManagerOptions{})
+	manager.OnShutdown(
+		Stage{Name: "readiness", Hook: record("readiness", nil)},
+		Stage{Name: "http", Hook: record("http", errClose)},
+		Stage{Name: "background", Timeout: 10 * time.Millisecond, Hook: func(ctx context.Context) error {
+			appendOrder("background")
+			<-ctx.Done()
+			time.Sleep(5 * time.Millisecond)
+			return nil
+		}},
+		Stage{Name: "resources", Hook: record("resources", nil)},
+	)
+
+	err := manager.Shutdown(context.Background())
+	if !errors.Is(err, errClose) || !errors.Is(err, ErrStageTimeout) {
+		t.Errorf("expected close and timeout errors, actual: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []string{"readiness", "http", "background", "resources"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("expected order %v, actual: %v", expected, order)
+	}
+
+	if again := manager.Shutdown(context.Background()); again != err {
+		t.Errorf("expected second shutdown to return the first result, actual: %v", again)
+	}
+	if len(order) != len(expected) {
+		t.Errorf("expected stages to run once, actual: %v", order)
+	}
+}
@@ -1 +1,37 @@
 package loghelper
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSetLevelSurvivesDBLoggerInit(t *testing.T) {
+	var logs, sqlLogs bytes.Buffer
+	if err := InitZapWithWriters("test", "dev", []io.Writer{&logs}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetLevel("warn"); err != nil {
+		t.Fatal(err)
+	}
+	if err := InitZapWithSql("test", "dev", &sqlLogs, nil); err != nil {
+		t.Fatal(err)
+	}
+	Logger.Info("hidden")
+	Logger.Warn("shown")
+	if strings.Contains(logs.String(), "hidden") || !strings.Contains(logs.String(), "shown") {
+		t.Errorf("expected the level of Logger to be kept, actual: %s", logs.String())
+	}
+
+	if err := SetLevel("error"); err != nil {
+		t.Fatal(err)
+	}
+	DBLogger.Warn("hidden")
+	if sqlLogs.Len() != 0 {
+		t.Errorf("expected SetLevel to change the level of DBLogger, actual: %s", sqlLogs.String())
+	}
+	if err := SetLevel("info"); err != nil {
+		t.Fatal(err)
+	}
+}
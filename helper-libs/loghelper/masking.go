@@ -0,0 +1,304 @@
+package loghelper
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	maskingGroupFirst = "FIRST"
+	maskingGroupMask  = "MASK"
+	maskingGroupLast  = "LAST"
+	maskingChar       = "*"
+)
+
+type (
+	// maskingRule masks the value of a sensitive field.
+	// `pattern` may declare FIRST/MASK/LAST named groups, only the MASK group is hidden,
+	// a pattern without MASK group hides the whole match and a value not matching the pattern is hidden completely.
+	// Patterns declaring FIRST or LAST describe the shape of a value (e.g. a card number) and are also searched in messages.
+	maskingRule struct {
+		field        string
+		pattern      *regexp.Regexp
+		keyValue     *regexp.Regexp
+		maskGroupIdx int
+		matchText    bool
+	}
+
+	maskingCore struct {
+		zapcore.Core
+		rules []*maskingRule
+	}
+)
+
+// newMaskingRules compiles the `sensitive_fields` config, keys are field names and values are the masking regex.
+func newMaskingRules(maskingFields map[string]string) ([]*maskingRule, error) {
+	rules := make([]*maskingRule, 0, len(maskingFields))
+	for field, expr := range maskingFields {
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid masking pattern of field %q: %w", field, err)
+		}
+
+		nameParts := strings.FieldsFunc(field, func(r rune) bool {
+			return r == '_' || r == '-' || r == '.'
+		})
+		for i := range nameParts {
+			nameParts[i] = regexp.QuoteMeta(nameParts[i])
+		}
+		// Matches `password=xxx`, `Password:xxx` or `"password": "xxx"` inside free text
+		keyValue := regexp.MustCompile(`(?i)("?\b` + strings.Join(nameParts, `[_\-.]?`) + `\b"?\s*[:=]\s*"?)([^"\s,;&}\])]+)`)
+
+		rules = append(rules, &maskingRule{
+			field:        normalizeFieldName(field),
+			pattern:      pattern,
+			keyValue:     keyValue,
+			maskGroupIdx: pattern.SubexpIndex(maskingGroupMask),
+			matchText:    pattern.SubexpIndex(maskingGroupFirst) > 0 || pattern.SubexpIndex(maskingGroupLast) > 0,
+		})
+	}
+	return rules, nil
+}
+
+// newMaskingCore wraps `core` so that every entry and field is masked before being encoded.
+func newMaskingCore(core zapcore.Core, maskingFields map[string]string) (zapcore.Core, error) {
+	rules, err := newMaskingRules(maskingFields)
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return core, nil
+	}
+	return &maskingCore{
+		Core:  core,
+		rules: rules,
+	}, nil
+}
+
+func (c *maskingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &maskingCore{
+		Core:  c.Core.With(c.maskFields(fields)),
+		rules: c.rules,
+	}
+}
+
+func (c *maskingCore) Check(entry zapcore.Entry, checkedEntry *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checkedEntry.AddCore(entry, c)
+	}
+	return checkedEntry
+}
+
+func (c *maskingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	entry.Message = c.maskText(entry.Message)
+	return c.Core.Write(entry, c.maskFields(fields))
+}
+
+func (c *maskingCore) maskFields(fields []zapcore.Field) []zapcore.Field {
+	masked := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		masked[i] = c.maskField(field)
+	}
+	return masked
+}
+
+func (c *maskingCore) maskField(field zapcore.Field) zapcore.Field {
+	if rule := c.findRule(field.Key); rule != nil {
+		switch field.Type {
+		case zapcore.StringType:
+			return zap.String(field.Key, rule.maskValue(field.String))
+		case zapcore.ErrorType, zapcore.SkipType:
+			return field
+		default:
+			return zap.String(field.Key, rule.maskValue(fieldValueString(field)))
+		}
+	}
+
+	switch field.Type {
+	case zapcore.StringType:
+		if masked, ok := c.maskJSONString(field.String); ok {
+			return zap.String(field.Key, masked)
+		}
+		return zap.String(field.Key, c.maskKeyValues(field.String))
+	case zapcore.ReflectType, zapcore.ObjectMarshalerType, zapcore.ArrayMarshalerType, zapcore.StringerType:
+		generic, ok := fieldToGeneric(field)
+		if !ok {
+			return field
+		}
+		if masked, changed := c.maskGeneric(generic); changed {
+			return zap.Any(field.Key, masked)
+		}
+	}
+	return field
+}
+
+// maskText masks `key=value` pairs of sensitive fields and any value matching a value shaped masking pattern.
+func (c *maskingCore) maskText(text string) string {
+	text = c.maskKeyValues(text)
+	for _, rule := range c.rules {
+		if rule.matchText {
+			text = rule.maskMatches(text)
+		}
+	}
+	return text
+}
+
+func (c *maskingCore) maskKeyValues(text string) string {
+	for _, rule := range c.rules {
+		text = rule.keyValue.ReplaceAllStringFunc(text, func(match string) string {
+			groups := rule.keyValue.FindStringSubmatch(match)
+			return groups[1] + rule.maskValue(groups[2])
+		})
+	}
+	return text
+}
+
+// maskJSONString masks a string field holding a JSON object or array, the second value reports whether it was JSON.
+func (c *maskingCore) maskJSONString(value string) (string, bool) {
+	trimmed := strings.TrimSpace(value)
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return value, false
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal([]byte(trimmed), &generic); err != nil {
+		return value, false
+	}
+	masked, changed := c.maskGeneric(generic)
+	if !changed {
+		return value, true
+	}
+	data, err := json.Marshal(masked)
+	if err != nil {
+		return value, true
+	}
+	return string(data), true
+}
+
+// maskGeneric walks a decoded JSON value and masks every sensitive key at any depth.
+func (c *maskingCore) maskGeneric(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		changed := false
+		for key, item := range v {
+			if rule := c.findRule(key); rule != nil {
+				v[key] = rule.maskGenericValue(item)
+				changed = true
+				continue
+			}
+			if masked, itemChanged := c.maskGeneric(item); itemChanged {
+				v[key] = masked
+				changed = true
+			}
+		}
+		return v, changed
+	case []interface{}:
+		changed := false
+		for i, item := range v {
+			if masked, itemChanged := c.maskGeneric(item); itemChanged {
+				v[i] = masked
+				changed = true
+			}
+		}
+		return v, changed
+	case string:
+		if masked, isJSON := c.maskJSONString(v); isJSON && masked != v {
+			return masked, true
+		}
+	}
+	return value, false
+}
+
+func (c *maskingCore) findRule(key string) *maskingRule {
+	normalized := normalizeFieldName(key)
+	for _, rule := range c.rules {
+		if rule.field == normalized {
+			return rule
+		}
+	}
+	return nil
+}
+
+func (r *maskingRule) maskGenericValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return r.maskValue(v)
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(v)
+		return strings.Repeat(maskingChar, len(data))
+	default:
+		return r.maskValue(fmt.Sprint(v))
+	}
+}
+
+// maskValue masks a value known to be sensitive, it is hidden completely when it does not match the pattern.
+func (r *maskingRule) maskValue(value string) string {
+	if value == "" {
+		return value
+	}
+	if !r.pattern.MatchString(value) {
+		return strings.Repeat(maskingChar, len(value))
+	}
+	return r.maskMatches(value)
+}
+
+// maskMatches hides the MASK group, or the whole match when there is none, of every pattern match inside `text`.
+func (r *maskingRule) maskMatches(text string) string {
+	matches := r.pattern.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+
+	var builder strings.Builder
+	last := 0
+	for _, match := range matches {
+		start, end := match[0], match[1]
+		if r.maskGroupIdx > 0 && match[2*r.maskGroupIdx] >= 0 {
+			start, end = match[2*r.maskGroupIdx], match[2*r.maskGroupIdx+1]
+		}
+		if start == end {
+			continue
+		}
+		builder.WriteString(text[last:start])
+		builder.WriteString(strings.Repeat(maskingChar, end-start))
+		last = end
+	}
+	builder.WriteString(text[last:])
+	return builder.String()
+}
+
+func normalizeFieldName(name string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "", ".", "").Replace(name))
+}
+
+func fieldValueString(field zapcore.Field) string {
+	encoder := zapcore.NewMapObjectEncoder()
+	field.AddTo(encoder)
+	return fmt.Sprint(encoder.Fields[field.Key])
+}
+
+func fieldToGeneric(field zapcore.Field) (interface{}, bool) {
+	encoder := zapcore.NewMapObjectEncoder()
+	field.AddTo(encoder)
+	value, ok := encoder.Fields[field.Key]
+	if !ok {
+		return nil, false
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, false
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil, false
+	}
+	return generic, true
+}
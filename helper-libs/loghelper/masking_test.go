@@ -0,0 +1,125 @@
+package loghelper
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+var testMaskingFields = map[string]string{
+	"card_number": `(?P<FIRST>[0-9]{6})(?P<MASK>[0-9]*)(?P<LAST>[0-9]{4})`,
+	"password":    `(?P<MASK>.+)`,
+}
+
+func newTestLogger(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	buffer := &bytes.Buffer{}
+	if err := InitZapWithWriters("testing", "dev", []io.Writer{buffer}, testMaskingFields); err != nil {
+		t.Fatalf("failed to init zap, err: %v", err)
+	}
+	return buffer
+}
+
+func TestMaskingFields(t *testing.T) {
+	tests := []struct {
+		name      string
+		log       func()
+		contains  []string
+		forbidden []string
+	}{
+		{
+			name:      "card number field keeps first six and last four digits",
+			log:       func() { Logger.Infow("payment", "card_number", "4111111111111111") },
+			contains:  []string{`"card_number":"411111******1111"`},
+			forbidden: []string{"4111111111111111"},
+		},
+		{
+			name:      "field name matching ignores case and separators",
+			log:       func() { Logger.Infow("payment", "CardNumber", "5500000000000004") },
+			contains:  []string{`"CardNumber":"550000******0004"`},
+			forbidden: []string{"5500000000000004"},
+		},
+		{
+			name:      "password field is fully masked",
+			log:       func() { Logger.Infow("login", "password", "Abc12345") },
+			contains:  []string{`"password":"********"`},
+			forbidden: []string{"Abc12345"},
+		},
+		{
+			name:      "card number in message",
+			log:       func() { Logger.Infof("charge card 4111111111111111 succeeded") },
+			contains:  []string{"charge card 411111******1111 succeeded"},
+			forbidden: []string{"4111111111111111"},
+		},
+		{
+			name:      "password key value in message",
+			log:       func() { Logger.Infof("config: %+v", struct{ Username, Password string }{"admin", "Abc12345"}) },
+			contains:  []string{"Username:admin", "Password:********"},
+			forbidden: []string{"Abc12345"},
+		},
+		{
+			name: "nested object",
+			log: func() {
+				Logger.L.Info("request", zap.Any("body", map[string]interface{}{
+					"user": map[string]interface{}{"name": "alice", "password": "s3cret"},
+					"cards": []interface{}{
+						map[string]interface{}{"card_number": "4111111111111111"},
+					},
+				}))
+			},
+			contains:  []string{`"name":"alice"`, `"password":"******"`, `"card_number":"411111******1111"`},
+			forbidden: []string{"s3cret", "4111111111111111"},
+		},
+		{
+			name:      "json string field",
+			log:       func() { Logger.Infow("response", "payload", `{"data":{"password":"s3cret","amount":100}}`) },
+			contains:  []string{`password\":\"******\"`, `amount\":100`},
+			forbidden: []string{"s3cret"},
+		},
+		{
+			name:      "fields bound with With",
+			log:       func() { Logger.With("password", "s3cret").Info("bound") },
+			contains:  []string{`"password":"******"`},
+			forbidden: []string{"s3cret"},
+		},
+		{
+			name:      "unrelated fields are untouched",
+			log:       func() { Logger.Infow("order", "order_id", "1234567890123", "amount", 100) },
+			contains:  []string{`"order_id":"1234567890123"`, `"amount":100`},
+			forbidden: []string{"*"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buffer := newTestLogger(t)
+			tt.log()
+
+			output := buffer.String()
+			if !json.Valid(bytes.TrimSpace(buffer.Bytes())) {
+				t.Fatalf("invalid json output: %s", output)
+			}
+			for _, expected := range tt.contains {
+				if !strings.Contains(output, expected) {
+					t.Errorf("expected %q in output: %s", expected, output)
+				}
+			}
+			for _, unexpected := range tt.forbidden {
+				if strings.Contains(output, unexpected) {
+					t.Errorf("unexpected %q in output: %s", unexpected, output)
+				}
+			}
+		})
+	}
+}
+
+func TestInvalidMaskingPattern(t *testing.T) {
+	err := InitZapWithWriters("testing", "dev", []io.Writer{&bytes.Buffer{}}, map[string]string{"password": "(?P<MASK>"})
+	if err == nil {
+		t.Error("expected error for invalid masking pattern")
+	}
+}
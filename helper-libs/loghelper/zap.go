@@ -16,6 +16,11 @@ import (
 var (
 	Logger   *zapLogger = &zapLogger{}
 	DBLogger *zapLogger = &zapLogger{}
+
+	// loggerLevel and dbLoggerLevel let SetLevel change the level of Logger and DBLogger at runtime, each init
+	// only sets the level of the logger it builds.
+	loggerLevel   = zap.NewAtomicLevel()
+	dbLoggerLevel = zap.NewAtomicLevel()
 )
 
 type zapLogger struct {
@@ -60,7 +65,7 @@ type zapLogger struct {
 // }
 
 func InitZap(app, env string, maskingFields map[string]string) error {
-	logLevel := configLogLevel(env)
+	loggerLevel.SetLevel(configLogLevel(env))
 	encoderConfig := zapcore.EncoderConfig{
 		MessageKey:   "message",
 		LevelKey:     "level",
@@ -77,11 +82,17 @@ func InitZap(app, env string, maskingFields map[string]string) error {
 		zapcore.AddSync(os.Stdout),
 	)
 
-	newCore := zapcore.NewCore(
-		zapcore.NewJSONEncoder(encoderConfig),
-		syncer,
-		zap.NewAtomicLevelAt(logLevel),
+	newCore, err := newMaskingCore(
+		zapcore.NewCore(
+			zapcore.NewJSONEncoder(encoderConfig),
+			syncer,
+			loggerLevel,
+		),
+		maskingFields,
 	)
+	if err != nil {
+		return err
+	}
 
 	newLogger := zap.New(
 		newCore,
@@ -99,7 +110,7 @@ func InitZap(app, env string, maskingFields map[string]string) error {
 }
 
 func InitZapWithWriters(app, env string, writers []io.Writer, maskingFields map[string]string) error {
-	logLevel := configLogLevel(env)
+	loggerLevel.SetLevel(configLogLevel(env))
 	encoderConfig := zapcore.EncoderConfig{
 		MessageKey:   "message",
 		LevelKey:     "level",
@@ -120,11 +131,17 @@ func InitZapWithWriters(app, env string, writers []io.Writer, maskingFields map[
 		writerSyncers...,
 	)
 
-	newCore := zapcore.NewCore(
-		zapcore.NewJSONEncoder(encoderConfig),
-		syncer,
-		zap.NewAtomicLevelAt(logLevel),
+	newCore, err := newMaskingCore(
+		zapcore.NewCore(
+			zapcore.NewJSONEncoder(encoderConfig),
+			syncer,
+			loggerLevel,
+		),
+		maskingFields,
 	)
+	if err != nil {
+		return err
+	}
 
 	newLogger := zap.New(
 		newCore,
@@ -142,7 +159,7 @@ func InitZapWithWriters(app, env string, writers []io.Writer, maskingFields map[
 }
 
 func InitZapWithRotatingFile(app, env string, sqlOrmWriter io.Writer, maskFields map[string]string) error {
-	logLevel := configLogLevel(env)
+	loggerLevel.SetLevel(configLogLevel(env))
 	encoderConfig := zapcore.EncoderConfig{
 		MessageKey:   "message",
 		LevelKey:     "level",
@@ -162,11 +179,17 @@ func InitZapWithRotatingFile(app, env string, sqlOrmWriter io.Writer, maskFields
 		}),
 	)
 
-	newCore := zapcore.NewCore(
-		zapcore.NewJSONEncoder(encoderConfig),
-		syncer,
-		zap.NewAtomicLevelAt(logLevel),
+	newCore, err := newMaskingCore(
+		zapcore.NewCore(
+			zapcore.NewJSONEncoder(encoderConfig),
+			syncer,
+			loggerLevel,
+		),
+		maskFields,
 	)
+	if err != nil {
+		return err
+	}
 
 	newLogger := zap.New(
 		newCore,
@@ -184,7 +207,7 @@ func InitZapWithRotatingFile(app, env string, sqlOrmWriter io.Writer, maskFields
 }
 
 func InitZapWithSql(app, env string, sqlOrmWriter io.Writer, maskFields map[string]string) error {
-	logLevel := configLogLevel(env)
+	dbLoggerLevel.SetLevel(configLogLevel(env))
 	encoderConfig := zapcore.EncoderConfig{
 		MessageKey:   "message",
 		LevelKey:     "level",
@@ -201,11 +224,17 @@ func InitZapWithSql(app, env string, sqlOrmWriter io.Writer, maskFields map[stri
 		zapcore.AddSync(sqlOrmWriter),
 	)
 
-	newCore := zapcore.NewCore(
-		zapcore.NewJSONEncoder(encoderConfig),
-		syncer,
-		zap.NewAtomicLevelAt(logLevel),
+	newCore, err := newMaskingCore(
+		zapcore.NewCore(
+			zapcore.NewJSONEncoder(encoderConfig),
+			syncer,
+			dbLoggerLevel,
+		),
+		maskFields,
 	)
+	if err != nil {
+		return err
+	}
 
 	newLogger := zap.New(
 		newCore,
@@ -268,6 +297,17 @@ func configLogLevel(defaultEnv string) zapcore.Level {
 	return level
 }
 
+// SetLevel changes the level of Logger and DBLogger at runtime, `level` is one of commonhelper.LogLevel.
+func SetLevel(level string) error {
+	var zapLevel zapcore.Level
+	if err := zapLevel.UnmarshalText([]byte(level)); err != nil {
+		return err
+	}
+	loggerLevel.SetLevel(zapLevel)
+	dbLoggerLevel.SetLevel(zapLevel)
+	return nil
+}
+
 func CreateFileRotatingWriter() io.Writer {
 	return &lumberjack.Logger{
 		Filename: "logs/log",
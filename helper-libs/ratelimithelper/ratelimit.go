@@ -0,0 +1,221 @@
+package ratelimithelper
+
+import (
+	"context"
+	"fmt"
+	"go-clean-arch/helper-libs/loghelper"
+	"go-clean-arch/helper-libs/redisclienthelper"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultKeyPrefix = "ratelimit:"
+)
+
+type Algorithm string
+
+const (
+	// Algorithm_TokenBucket refills Rate tokens every Period up to Burst, short bursts are allowed.
+	Algorithm_TokenBucket Algorithm = "token_bucket"
+	// Algorithm_SlidingWindow allows Rate requests in any window of Period, weighting the previous fixed window.
+	Algorithm_SlidingWindow Algorithm = "sliding_window"
+)
+
+// Both scripts read the clock of redis, so replicas with skewed clocks share the same buckets, replicate_commands
+// lets redis before 5.0 write after reading the clock.
+// They touch a single key each, which keeps them valid in cluster mode.
+var (
+	tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local burst = tonumber(ARGV[3])
+local cost = tonumber(ARGV[4])
+redis.replicate_commands()
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+  tokens = burst
+  ts = now
+end
+
+local interval = period / rate
+tokens = math.min(burst, tokens + math.max(0, now - ts) / interval)
+local allowed = 0
+local retry_after = 0
+if tokens >= cost then
+  tokens = tokens - cost
+  allowed = 1
+else
+  retry_after = math.ceil((cost - tokens) * interval)
+end
+local reset_after = math.ceil((burst - tokens) * interval)
+
+redis.call('HMSET', KEYS[1], 'tokens', tokens, 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(reset_after / 1000) + 1000)
+return {allowed, math.floor(tokens), retry_after, reset_after}
+`)
+
+	slidingWindowScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+redis.replicate_commands()
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+local current = math.floor(now / window)
+local elapsed = now - current * window
+
+local state = redis.call('HMGET', KEYS[1], 'window', 'current', 'previous')
+local stored = tonumber(state[1])
+local current_count = tonumber(state[2]) or 0
+local previous_count = tonumber(state[3]) or 0
+if stored == current - 1 then
+  previous_count = current_count
+  current_count = 0
+elseif stored ~= current then
+  previous_count = 0
+  current_count = 0
+end
+
+local count = previous_count * (window - elapsed) / window + current_count
+local allowed = 0
+local retry_after = 0
+if count + cost <= limit then
+  current_count = current_count + cost
+  count = count + cost
+  allowed = 1
+elseif current_count + cost > limit then
+  retry_after = window - elapsed
+  if current_count > 0 then
+    retry_after = retry_after + math.max(0, window * (1 - (limit - cost) / current_count))
+  end
+else
+  retry_after = window * (1 - (limit - cost - current_count) / previous_count) - elapsed
+end
+local reset_after = window - elapsed
+if current_count > 0 then
+  reset_after = reset_after + window
+end
+
+redis.call('HMSET', KEYS[1], 'window', current, 'current', current_count, 'previous', previous_count)
+redis.call('PEXPIRE', KEYS[1], math.ceil(2 * window / 1000))
+return {allowed, math.max(0, math.floor(limit - count)), math.ceil(retry_after), reset_after}
+`)
+)
+
+type (
+	// Limit allows Rate requests every Period, Burst only applies to the token bucket and falls back to Rate.
+	Limit struct {
+		Rate   int64
+		Period time.Duration
+		Burst  int64
+	}
+
+	Result struct {
+		Allowed   bool
+		Limit     int64
+		Remaining int64
+		// RetryAfter is zero when allowed.
+		RetryAfter time.Duration
+		// ResetAfter is the time until the key is back to its full limit.
+		ResetAfter time.Duration
+	}
+
+	// Limiter counts requests per key atomically, so every replica shares the same limits.
+	Limiter interface {
+		Allow(ctx context.Context, key string, limit Limit) (*Result, error)
+	}
+
+	RedisLimiterOptions struct {
+		Client    *redisclienthelper.RedisClientHelper
+		Algorithm Algorithm
+		// KeyPrefix falls back to `ratelimit:`.
+		KeyPrefix string
+	}
+
+	redisLimiter struct {
+		client    redis.Scripter
+		algorithm Algorithm
+		keyPrefix string
+	}
+)
+
+// NewRedisLimiter runs the algorithm as a lua script on the single or cluster client of opts.
+func NewRedisLimiter(opts *RedisLimiterOptions) Limiter {
+	if opts.Client == nil {
+		loghelper.Logger.Panic("redis client must specific")
+	}
+	var client redis.Scripter = opts.Client.Client
+	if opts.Client.ClusterClient != nil {
+		client = opts.Client.ClusterClient
+	}
+	algorithm := opts.Algorithm
+	if algorithm == "" {
+		algorithm = Algorithm_TokenBucket
+	}
+	keyPrefix := opts.KeyPrefix
+	if keyPrefix == "" {
+		keyPrefix = defaultKeyPrefix
+	}
+	return &redisLimiter{
+		client:    client,
+		algorithm: algorithm,
+		keyPrefix: keyPrefix,
+	}
+}
+
+// ParseAlgorithm maps the config values, an empty name is the token bucket.
+func ParseAlgorithm(name string) (Algorithm, error) {
+	switch Algorithm(name) {
+	case "", Algorithm_TokenBucket:
+		return Algorithm_TokenBucket, nil
+	case Algorithm_SlidingWindow:
+		return Algorithm_SlidingWindow, nil
+	}
+	return "", fmt.Errorf("unknown rate limit algorithm %q", name)
+}
+
+func (l *redisLimiter) Allow(ctx context.Context, key string, limit Limit) (*Result, error) {
+	if limit.Rate <= 0 {
+		return &Result{Allowed: true}, nil
+	}
+	period := limit.Period
+	if period <= 0 {
+		period = time.Second
+	}
+
+	var values []int64
+	var err error
+	capacity := limit.Rate
+	switch l.algorithm {
+	case Algorithm_SlidingWindow:
+		values, err = slidingWindowScript.Run(ctx, l.client, []string{l.keyPrefix + key},
+			limit.Rate, period.Microseconds(), 1).Int64Slice()
+	default:
+		if limit.Burst > 0 {
+			capacity = limit.Burst
+		}
+		values, err = tokenBucketScript.Run(ctx, l.client, []string{l.keyPrefix + key},
+			limit.Rate, period.Microseconds(), capacity, 1).Int64Slice()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("rate limit %s: %w", key, err)
+	}
+	if len(values) != 4 {
+		return nil, fmt.Errorf("rate limit %s: unexpected script result %v", key, values)
+	}
+
+	return &Result{
+		Allowed:    values[0] == 1,
+		Limit:      capacity,
+		Remaining:  values[1],
+		RetryAfter: time.Duration(values[2]) * time.Microsecond,
+		ResetAfter: time.Duration(values[3]) * time.Microsecond,
+	}, nil
+}
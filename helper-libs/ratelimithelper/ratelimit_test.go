@@ -0,0 +1,90 @@
+package ratelimithelper
+
+import (
+	"context"
+	"go-clean-arch/helper-libs/redisclienthelper"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	redis "github.com/redis/go-redis/v9"
+)
+
+func newTestLimiter(t *testing.T, algorithm Algorithm) (Limiter, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	server.SetTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRedisLimiter(&RedisLimiterOptions{
+		Client:    &redisclienthelper.RedisClientHelper{Client: client},
+		Algorithm: algorithm,
+	}), server
+}
+
+func allowN(t *testing.T, limiter Limiter, n int, limit Limit) (allowed int, last *Result) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		result, err := limiter.Allow(context.Background(), "client", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Allowed {
+			allowed++
+		}
+		last = result
+	}
+	return allowed, last
+}
+
+func TestTokenBucket(t *testing.T) {
+	limiter, server := newTestLimiter(t, Algorithm_TokenBucket)
+	limit := Limit{Rate: 5, Period: time.Second, Burst: 10}
+
+	allowed, last := allowN(t, limiter, 12, limit)
+	if allowed != 10 {
+		t.Fatalf("expected the burst of 10 to be allowed, actual: %d", allowed)
+	}
+	if last.Allowed || last.Remaining != 0 || last.RetryAfter != 200*time.Millisecond || last.Limit != 10 {
+		t.Errorf("expected a rejection retrying after one token interval, actual: %+v", last)
+	}
+
+	// One second refills Rate tokens
+	server.SetTime(time.Date(2024, 1, 1, 0, 0, 1, 0, time.UTC))
+	if allowed, _ = allowN(t, limiter, 6, limit); allowed != 5 {
+		t.Errorf("expected 5 refilled tokens, actual: %d", allowed)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	limiter, server := newTestLimiter(t, Algorithm_SlidingWindow)
+	limit := Limit{Rate: 10, Period: time.Second}
+
+	allowed, last := allowN(t, limiter, 11, limit)
+	if allowed != 10 || last.Allowed || last.RetryAfter <= 0 {
+		t.Fatalf("expected 10 requests allowed in the window, actual: %d, %+v", allowed, last)
+	}
+
+	// Half way through the next window, half of the previous window still counts
+	server.SetTime(time.Date(2024, 1, 1, 0, 0, 1, int(500*time.Millisecond), time.UTC))
+	if allowed, _ = allowN(t, limiter, 10, limit); allowed != 5 {
+		t.Errorf("expected 5 requests allowed, actual: %d", allowed)
+	}
+
+	// Two windows later the key is back to its full limit
+	server.SetTime(time.Date(2024, 1, 1, 0, 0, 3, 0, time.UTC))
+	if allowed, _ = allowN(t, limiter, 10, limit); allowed != 10 {
+		t.Errorf("expected 10 requests allowed, actual: %d", allowed)
+	}
+}
+
+func TestParseAlgorithm(t *testing.T) {
+	for name, expected := range map[string]Algorithm{"": Algorithm_TokenBucket, "sliding_window": Algorithm_SlidingWindow} {
+		if actual, err := ParseAlgorithm(name); err != nil || actual != expected {
+			t.Errorf("%q: expected %s, actual: %s (%v)", name, expected, actual, err)
+		}
+	}
+	if _, err := ParseAlgorithm("leaky_bucket"); err == nil {
+		t.Error("expected an error for an unknown algorithm")
+	}
+}
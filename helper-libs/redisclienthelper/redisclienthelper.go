@@ -3,6 +3,7 @@ package redisclienthelper
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"go-clean-arch/helper-libs/tlshelper"
 
 	redis "github.com/redis/go-redis/v9"
@@ -50,6 +51,30 @@ func NewRedisClientHelper(cfg *RedisConfigOptions) *RedisClientHelper {
 	}
 }
 
+// Ping checks the connection of whichever client is configured, cluster mode pings every shard.
+func (h *RedisClientHelper) Ping(ctx context.Context) error {
+	if h.ClusterClient != nil {
+		return h.ClusterClient.ForEachShard(ctx, func(ctx context.Context, shard *redis.Client) error {
+			return shard.Ping(ctx).Err()
+		})
+	}
+	if h.Client != nil {
+		return h.Client.Ping(ctx).Err()
+	}
+	return errors.New("redis client must specific")
+}
+
+// Close releases the connection pool of whichever client is configured.
+func (h *RedisClientHelper) Close() error {
+	if h.ClusterClient != nil {
+		return h.ClusterClient.Close()
+	}
+	if h.Client != nil {
+		return h.Client.Close()
+	}
+	return nil
+}
+
 func initRedisCluster(cfg *RedisConfigOptions) (*redis.ClusterClient, error) {
 	var tlsConfig *tls.Config
 	var err error
@@ -2,233 +2,332 @@ package redisleaderhelper
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"go-clean-arch/helper-libs/loghelper"
 	"go-clean-arch/helper-libs/redisclienthelper"
+	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/bsm/redislock"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
 )
 
+const (
+	LeaderTransition_Elected LeaderTransition = "elected"
+	LeaderTransition_Demoted LeaderTransition = "demoted"
+
+	defaultTtl  = 10 * time.Second
+	defaultWait = time.Second
+)
+
+var (
+	ErrAlreadyRunning = errors.New("leader election is already running")
+	ErrNotRunning     = errors.New("leader election is not running")
+	// ErrStaleToken is returned for a fencing token of a past term, the write must be rejected.
+	ErrStaleToken = errors.New("stale fencing token")
+)
+
+var (
+	leaderTerm = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "leader_election_term",
+		Help: "Fencing token of the last term led by this instance.",
+	}, []string{"key"})
+	leaderIsLeader = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "leader_election_is_leader",
+		Help: "1 while this instance leads.",
+	}, []string{"key"})
+	leaderChanges = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "leader_election_changes_total",
+		Help: "Leadership transitions of this instance.",
+	}, []string{"key", "transition"})
+)
+
+func init() {
+	prometheus.MustRegister(leaderTerm, leaderIsLeader, leaderChanges)
+}
+
+// acquireScript takes the leadership when it is free or extends it when held by ARGV[1]. Every new term gets the
+// next fencing token, it returns the token of the term or 0 when another instance leads.
+var acquireScript = redis.NewScript(`
+local owner = redis.call('HGET', KEYS[1], 'owner')
+if owner == false then
+	local token = redis.call('INCR', KEYS[2])
+	redis.call('HSET', KEYS[1], 'owner', ARGV[1], 'token', token)
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return token
+end
+if owner == ARGV[1] then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return tonumber(redis.call('HGET', KEYS[1], 'token'))
+end
+return 0
+`)
+
+var releaseScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'owner') == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// validateScript accepts the token of the current term, or of the last term when nobody leads.
+var validateScript = redis.NewScript(`
+local token = redis.call('HGET', KEYS[1], 'token')
+if token == false then
+	token = redis.call('GET', KEYS[2])
+end
+if token == ARGV[1] then
+	return 1
+end
+return 0
+`)
+
 type (
-	RedisLeaderHelper struct {
-		redisClusterClient *redis.ClusterClient
-		redisClient        *redis.Client
-		locker             *redislock.Client
-		lock               *redislock.Lock
-		isStarted          bool
-		wasLeading         bool
-		canLead            bool
-		id                 string
-		renewTimeoutId     *time.Timer
-		electTimeoutId     *time.Timer
-		ttl                int64
-		wait               int64
-		key                string
-		becomeLeaderFunc   func()
-		notElectedFunc     func()
-		demotedFunc        func()
-		errorFunc          func(err error)
+	LeaderTransition string
+
+	// Leadership is the state of this instance after a transition.
+	Leadership struct {
+		IsLeader bool
+		// Token is the fencing token of the term, it grows with every term. Writes done as leader should carry it
+		// so stores can reject those of a previous leader, see ValidateToken. It is 0 for followers.
+		Token int64
+		// Context is canceled when the leadership is lost, leader only work should run with it.
+		Context context.Context
 	}
 
 	RedisLeaderOptions struct {
 		RedisClientHelper *redisclienthelper.RedisClientHelper
-		Ttl               int64
-		Wait              int64
-		Key               string
-		BecomeLeaderFunc  func()
-		NotElectedFunc    func()
-		DemotedFunc       func()
-		ErrorFunc         func(err error)
+		// Key names the election, instances competing for the same role share it.
+		Key string
+		// Id identifies this instance, it falls back to the hostname and a random suffix.
+		Id string
+		// Ttl is how long the leadership outlives a leader that stopped renewing, it falls back to 10s.
+		// The leader renews every Ttl/3.
+		Ttl time.Duration
+		// Wait is the interval between the attempts of followers, it falls back to 1s.
+		Wait time.Duration
+	}
+
+	// RedisLeaderHelper elects one leader among the instances sharing Key.
+	RedisLeaderHelper interface {
+		// Run campaigns until ctx is done, then it releases the leadership so another instance takes over
+		// without waiting for the ttl.
+		Run(ctx context.Context) error
+		// Leadership receives the state after every transition, only the last state is kept when it isn't read.
+		Leadership() <-chan Leadership
+		// IsLeader reports the last known state, it doesn't call redis.
+		IsLeader() bool
+		// Token returns the fencing token of the current term, 0 when not leading.
+		Token() int64
+		Id() string
+		// ValidateToken returns ErrStaleToken unless token is the one of the last term.
+		ValidateToken(ctx context.Context, token int64) error
+		// HealthCheck fails when the election is not running or its last round trip to redis failed.
+		HealthCheck(ctx context.Context) error
+	}
+
+	redisLeaderHelper struct {
+		client     redis.UniversalClient
+		key        string
+		leaderKey  string
+		fencingKey string
+		id         string
+		ttl        time.Duration
+		wait       time.Duration
+		running    atomic.Bool
+		leadership chan Leadership
+
+		mu      sync.Mutex
+		token   int64
+		cancel  context.CancelFunc
+		lastErr error
 	}
 )
 
-func NewRedisLeaderHelper(opts *RedisLeaderOptions) *RedisLeaderHelper {
-	if opts.RedisClientHelper.ClusterClient == nil && opts.RedisClientHelper.Client == nil {
+func NewRedisLeaderHelper(opts *RedisLeaderOptions) RedisLeaderHelper {
+	if opts.RedisClientHelper == nil || (opts.RedisClientHelper.ClusterClient == nil && opts.RedisClientHelper.Client == nil) {
 		loghelper.Logger.Panic("redis client must specific")
-		return &RedisLeaderHelper{}
 	}
-
+	if opts.Key == "" {
+		loghelper.Logger.Panic("leader key must specific")
+	}
+	h := &redisLeaderHelper{
+		key: opts.Key,
+		// The hash tag keeps both keys in the same slot for the scripts in cluster mode
+		leaderKey:  "{" + opts.Key + "}:leader",
+		fencingKey: "{" + opts.Key + "}:fencing",
+		id:         opts.Id,
+		ttl:        opts.Ttl,
+		wait:       opts.Wait,
+		leadership: make(chan Leadership, 1),
+	}
 	if opts.RedisClientHelper.ClusterClient != nil {
-		locker := redislock.New(opts.RedisClientHelper.ClusterClient)
-		return &RedisLeaderHelper{
-			locker:             locker,
-			redisClusterClient: opts.RedisClientHelper.ClusterClient,
-			ttl:                opts.Ttl,
-			wait:               opts.Wait,
-			key:                opts.Key,
-			becomeLeaderFunc:   opts.BecomeLeaderFunc,
-			demotedFunc:        opts.DemotedFunc,
-			errorFunc:          opts.ErrorFunc,
-			notElectedFunc:     opts.NotElectedFunc,
-		}
+		h.client = opts.RedisClientHelper.ClusterClient
+	} else {
+		h.client = opts.RedisClientHelper.Client
 	}
-
-	locker := redislock.New(opts.RedisClientHelper.Client)
-	return &RedisLeaderHelper{
-		locker:           locker,
-		redisClient:      opts.RedisClientHelper.Client,
-		ttl:              opts.Ttl,
-		wait:             opts.Wait,
-		key:              opts.Key,
-		becomeLeaderFunc: opts.BecomeLeaderFunc,
-		demotedFunc:      opts.DemotedFunc,
-		errorFunc:        opts.ErrorFunc,
-		notElectedFunc:   opts.NotElectedFunc,
+	if h.id == "" {
+		h.id = newInstanceId()
 	}
-}
-
-func (h *RedisLeaderHelper) elect() {
-	var err error
-	h.lock, err = h.locker.Obtain(
-		context.Background(),
-		h.key,
-		time.Millisecond*time.Duration(h.ttl),
-		&redislock.Options{
-			Token: h.id,
-		},
-	)
-
-	if err == redislock.ErrNotObtained {
-		h.notifyNotElected()
-		h.electTimeoutId = time.NewTimer(time.Millisecond * time.Duration(h.wait))
-		go func() {
-			<-h.electTimeoutId.C
-			h.elect()
-		}()
-		return
-	} else if err != nil {
-		if h.isStarted {
-			h.notifyError(err)
-		}
-		h.electTimeoutId = time.NewTimer(time.Millisecond * time.Duration(h.wait))
-		go func() {
-			<-h.electTimeoutId.C
-			h.elect()
-		}()
-		return
+	if h.ttl <= 0 {
+		h.ttl = defaultTtl
 	}
-
-	h.notifyElected()
-	h.wasLeading = true
-	if !h.canLead {
-		h.stop()
+	if h.wait <= 0 {
+		h.wait = defaultWait
 	}
-	h.renewTimeoutId = time.NewTimer(time.Millisecond * time.Duration(h.ttl/2))
-	go func() {
-		<-h.renewTimeoutId.C
-		h.renew()
-	}()
+	return h
 }
 
-func (h *RedisLeaderHelper) renew() {
-	err := h.lock.Refresh(
-		context.Background(),
-		time.Millisecond*time.Duration(h.ttl),
-		&redislock.Options{
-			Token: h.id,
-		},
-	)
-	if err == redislock.ErrNotObtained {
-		if h.wasLeading {
-			h.wasLeading = false
-			h.notifyDemoted()
+func (h *redisLeaderHelper) Run(ctx context.Context) error {
+	if !h.running.CompareAndSwap(false, true) {
+		return ErrAlreadyRunning
+	}
+	defer h.running.Store(false)
+
+	// A leader failing to renew steps down once its lease may have expired, before another instance can be elected
+	var leaseEnd time.Time
+	for {
+		attempt := time.Now()
+		token, err := acquireScript.Run(ctx, h.client, []string{h.leaderKey, h.fencingKey}, h.id, h.ttl.Milliseconds()).Int64()
+		h.setLastErr(err)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			loghelper.Logger.Warnw("leader election failed", zap.String("key", h.key), zap.String("id", h.id), zap.Error(err))
+			if h.Token() != 0 && time.Now().After(leaseEnd) {
+				h.demote(ctx)
+			}
+		case err != nil:
+		case token == 0:
+			h.demote(ctx)
+		default:
+			leaseEnd = attempt.Add(h.ttl)
+			h.elect(ctx, token)
 		}
-		if h.renewTimeoutId != nil {
-			h.renewTimeoutId.Stop()
+
+		interval := h.wait
+		if h.Token() != 0 {
+			interval = h.ttl / 3
 		}
-		h.electTimeoutId = time.NewTimer(time.Millisecond * time.Duration(h.wait))
-		go func() {
-			<-h.electTimeoutId.C
-			h.elect()
-		}()
-		return
-	} else if err != nil {
-		if h.isStarted {
-			h.notifyError(err)
+		select {
+		case <-ctx.Done():
+			h.release()
+			return nil
+		case <-time.After(interval):
 		}
-		h.electTimeoutId = time.NewTimer(time.Millisecond * time.Duration(h.wait))
-		go func() {
-			<-h.electTimeoutId.C
-			h.elect()
-		}()
-
-		return
 	}
+}
 
-	h.wasLeading = true
-	h.renewTimeoutId = time.NewTimer(time.Millisecond * time.Duration(h.ttl/2))
-	go func() {
-		<-h.renewTimeoutId.C
-		h.renew()
-	}()
+func (h *redisLeaderHelper) Leadership() <-chan Leadership {
+	return h.leadership
 }
 
-func (h *RedisLeaderHelper) stop() {
-	h.canLead = false
-	if h.renewTimeoutId != nil {
-		h.renewTimeoutId.Stop()
-	}
-	if h.electTimeoutId != nil {
-		h.electTimeoutId.Stop()
-	}
-	err := h.lock.Release(
-		context.Background(),
-	)
-	if err != nil {
-		if h.isStarted {
-			h.notifyError(err)
-		}
-		return
-	}
+func (h *redisLeaderHelper) IsLeader() bool {
+	return h.Token() != 0
+}
+
+func (h *redisLeaderHelper) Token() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.token
+}
 
-	h.notifyDemoted()
-	h.wasLeading = false
+func (h *redisLeaderHelper) Id() string {
+	return h.id
 }
 
-func (h *RedisLeaderHelper) IsLeader() bool {
-	if h.redisClusterClient != nil {
-		return h.id == h.redisClusterClient.Get(context.Background(), h.key).String()
+func (h *redisLeaderHelper) ValidateToken(ctx context.Context, token int64) error {
+	valid, err := validateScript.Run(ctx, h.client, []string{h.leaderKey, h.fencingKey}, token).Int64()
+	if err != nil {
+		return err
 	}
-	return h.id == h.redisClient.Get(context.Background(), h.key).String()
+	if valid == 0 {
+		return ErrStaleToken
+	}
+	return nil
 }
 
-func (h *RedisLeaderHelper) notifyElected() {
-	if h.becomeLeaderFunc != nil {
-		h.becomeLeaderFunc()
+func (h *redisLeaderHelper) HealthCheck(ctx context.Context) error {
+	if !h.running.Load() {
+		return ErrNotRunning
 	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.lastErr
 }
 
-func (h *RedisLeaderHelper) notifyNotElected() {
-	if h.notElectedFunc != nil {
-		h.notElectedFunc()
+// elect publishes a new term, renewals of the current term change nothing.
+func (h *redisLeaderHelper) elect(ctx context.Context, token int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.token == token {
+		return
+	}
+	if h.cancel != nil {
+		h.cancel()
 	}
+	leaderCtx, cancel := context.WithCancel(ctx)
+	h.token, h.cancel = token, cancel
+
+	leaderTerm.WithLabelValues(h.key).Set(float64(token))
+	leaderIsLeader.WithLabelValues(h.key).Set(1)
+	leaderChanges.WithLabelValues(h.key, string(LeaderTransition_Elected)).Inc()
+	loghelper.Logger.Infow("elected leader", zap.String("key", h.key), zap.String("id", h.id), zap.Int64("token", token))
+	h.publish(Leadership{IsLeader: true, Token: token, Context: leaderCtx})
 }
 
-func (h *RedisLeaderHelper) notifyDemoted() {
-	if h.demotedFunc != nil {
-		h.demotedFunc()
+func (h *redisLeaderHelper) demote(ctx context.Context) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.token == 0 {
+		return
 	}
+	h.cancel()
+	h.token, h.cancel = 0, nil
+
+	leaderIsLeader.WithLabelValues(h.key).Set(0)
+	leaderChanges.WithLabelValues(h.key, string(LeaderTransition_Demoted)).Inc()
+	loghelper.Logger.Infow("demoted leader", zap.String("key", h.key), zap.String("id", h.id))
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	h.publish(Leadership{Context: canceled})
 }
 
-func (h *RedisLeaderHelper) notifyError(err error) {
-	if h.errorFunc != nil {
-		h.errorFunc(err)
+// release hands over the leadership on shutdown, the parent context is done so a fresh one is used.
+func (h *redisLeaderHelper) release() {
+	if h.Token() == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), h.wait)
+	defer cancel()
+	if err := releaseScript.Run(ctx, h.client, []string{h.leaderKey}, h.id).Err(); err != nil {
+		loghelper.Logger.Warnw("release leadership failed", zap.String("key", h.key), zap.String("id", h.id), zap.Error(err))
 	}
+	h.demote(ctx)
 }
 
-func (h *RedisLeaderHelper) Run(
-	ctx context.Context,
-) {
-	h.isStarted = true
-	h.canLead = true
-	h.elect()
+// publish keeps only the latest state in the channel, h.mu must be held.
+func (h *redisLeaderHelper) publish(leadership Leadership) {
+	select {
+	case <-h.leadership:
+	default:
+	}
+	h.leadership <- leadership
 }
 
-type RedisLocker struct {
-	Redis *redis.Client
+func (h *redisLeaderHelper) setLastErr(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastErr = err
 }
 
-type RedisClusterLocker struct {
-	Redis *redis.ClusterClient
+func newInstanceId() string {
+	hostname, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return hostname + "-" + hex.EncodeToString(suffix)
 }
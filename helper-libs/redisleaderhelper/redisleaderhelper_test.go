@@ -0,0 +1,136 @@
+package redisleaderhelper
+
+import (
+	"context"
+	"errors"
+	"go-clean-arch/helper-libs/loghelper"
+	"go-clean-arch/helper-libs/redisclienthelper"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestLeaderHelper(t *testing.T, server *miniredis.Miniredis, id string) RedisLeaderHelper {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRedisLeaderHelper(&RedisLeaderOptions{
+		RedisClientHelper: &redisclienthelper.RedisClientHelper{Client: client},
+		Key:               "jobs",
+		Id:                id,
+		Ttl:               300 * time.Millisecond,
+		Wait:              10 * time.Millisecond,
+	})
+}
+
+// runLeader runs leader until stop is called or the test ends, stop waits for Run to return.
+func runLeader(t *testing.T, leader RedisLeaderHelper) (stop func() error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- leader.Run(ctx) }()
+	var err error
+	stopped := false
+	stop = func() error {
+		if !stopped {
+			stopped = true
+			cancel()
+			err = <-done
+		}
+		return err
+	}
+	t.Cleanup(func() { _ = stop() })
+	return stop
+}
+
+func nextLeadership(t *testing.T, leader RedisLeaderHelper) Leadership {
+	t.Helper()
+	select {
+	case leadership := <-leader.Leadership():
+		return leadership
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expected a leadership change of %s", leader.Id())
+		return Leadership{}
+	}
+}
+
+func TestLeaderElection(t *testing.T) {
+	if err := loghelper.InitZap("test", "dev", nil); err != nil {
+		t.Fatal(err)
+	}
+	server := miniredis.RunT(t)
+	first, second := newTestLeaderHelper(t, server, "first"), newTestLeaderHelper(t, server, "second")
+	ctx := context.Background()
+	elected := testutil.ToFloat64(leaderChanges.WithLabelValues("jobs", string(LeaderTransition_Elected)))
+
+	if err := first.HealthCheck(ctx); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("expected %v before Run, actual: %v", ErrNotRunning, err)
+	}
+	stopFirst := runLeader(t, first)
+	leadership := nextLeadership(t, first)
+	if !leadership.IsLeader || leadership.Token != 1 || first.Token() != 1 {
+		t.Fatalf("expected first to lead term 1, actual: %+v", leadership)
+	}
+	if err := first.Run(ctx); !errors.Is(err, ErrAlreadyRunning) {
+		t.Errorf("expected %v, actual: %v", ErrAlreadyRunning, err)
+	}
+
+	runLeader(t, second)
+	time.Sleep(50 * time.Millisecond)
+	if second.IsLeader() || first.HealthCheck(ctx) != nil {
+		t.Fatal("expected second to follow while first renews")
+	}
+
+	// Stopping the leader hands over without waiting for the ttl
+	if err := stopFirst(); err != nil {
+		t.Fatal(err)
+	}
+	if demoted := nextLeadership(t, first); demoted.IsLeader || demoted.Context.Err() == nil || leadership.Context.Err() == nil {
+		t.Errorf("expected first demoted with its term canceled, actual: %+v", demoted)
+	}
+	if leadership := nextLeadership(t, second); !leadership.IsLeader || leadership.Token != 2 {
+		t.Fatalf("expected second to lead term 2, actual: %+v", leadership)
+	}
+
+	if err := second.ValidateToken(ctx, 1); !errors.Is(err, ErrStaleToken) {
+		t.Errorf("expected the token of first to be stale, actual: %v", err)
+	}
+	if err := second.ValidateToken(ctx, 2); err != nil {
+		t.Errorf("expected the token of second to be valid, actual: %v", err)
+	}
+	if actual := testutil.ToFloat64(leaderChanges.WithLabelValues("jobs", string(LeaderTransition_Elected))) - elected; actual != 2 {
+		t.Errorf("expected 2 elections, actual: %v", actual)
+	}
+	if term := testutil.ToFloat64(leaderTerm.WithLabelValues("jobs")); term != 2 {
+		t.Errorf("expected term 2, actual: %v", term)
+	}
+}
+
+func TestLeaderStepsDownWhenTakenOver(t *testing.T) {
+	if err := loghelper.InitZap("test", "dev", nil); err != nil {
+		t.Fatal(err)
+	}
+	server := miniredis.RunT(t)
+	leader := newTestLeaderHelper(t, server, "leader")
+	demoted := testutil.ToFloat64(leaderChanges.WithLabelValues("jobs", string(LeaderTransition_Demoted)))
+
+	runLeader(t, leader)
+	leadership := nextLeadership(t, leader)
+	if !leadership.IsLeader {
+		t.Fatal("expected to lead")
+	}
+
+	// Another instance took over after the lease of leader expired, e.g. during a long GC pause
+	server.HSet("{jobs}:leader", "owner", "other", "token", "2")
+	if leadership := nextLeadership(t, leader); leadership.IsLeader {
+		t.Fatal("expected leader to step down")
+	}
+	if leadership.Context.Err() == nil || leader.IsLeader() {
+		t.Error("expected the leader context to be canceled")
+	}
+	if actual := testutil.ToFloat64(leaderChanges.WithLabelValues("jobs", string(LeaderTransition_Demoted))) - demoted; actual != 1 {
+		t.Errorf("expected 1 demotion, actual: %v", actual)
+	}
+}
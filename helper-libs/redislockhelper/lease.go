@@ -0,0 +1,125 @@
+package redislockhelper
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/bsm/redislock"
+	"github.com/redis/go-redis/v9"
+)
+
+// redisNowMs is the time of the redis server in milliseconds, scripts use it so the clocks of the clients don't
+// matter.
+const redisNowMs = `
+local now = redis.call('TIME')
+local nowMs = tonumber(now[1]) * 1000 + math.floor(tonumber(now[2]) / 1000)
+`
+
+// refreshMemberScript extends the lease ARGV[1] of the sorted set KEYS[1], scored by expiry, by ARGV[2] ms.
+var refreshMemberScript = redis.NewScript(redisNowMs + `
+local expiry = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if expiry == false or tonumber(expiry) <= nowMs then
+	return 0
+end
+redis.call('ZADD', KEYS[1], 'XX', nowMs + tonumber(ARGV[2]), ARGV[1])
+if redis.call('PTTL', KEYS[1]) < tonumber(ARGV[2]) then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+var releaseMemberScript = redis.NewScript(`
+return redis.call('ZREM', KEYS[1], ARGV[1])
+`)
+
+var refreshOwnerScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+var releaseOwnerScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+type (
+	// Lease is a held lock, read lock, write lock or semaphore permit.
+	Lease interface {
+		Key() string
+		// Refresh extends the lease by ttl, it returns ErrNotObtained when the lease isn't held anymore.
+		Refresh(ctx context.Context, ttl time.Duration) error
+		// Release returns redislock.ErrLockNotHeld when the lease already expired.
+		Release(ctx context.Context) error
+	}
+
+	mutexLease struct {
+		lock *redislock.Lock
+	}
+
+	// scriptLease is a lease held by token in redisKey, refreshed and released by scripts.
+	scriptLease struct {
+		client        redis.UniversalClient
+		key           string
+		redisKey      string
+		token         string
+		refreshScript *redis.Script
+		releaseScript *redis.Script
+	}
+)
+
+func (l *mutexLease) Key() string {
+	return l.lock.Key()
+}
+
+func (l *mutexLease) Refresh(ctx context.Context, ttl time.Duration) error {
+	return l.lock.Refresh(ctx, ttl, nil)
+}
+
+func (l *mutexLease) Release(ctx context.Context) error {
+	return l.lock.Release(ctx)
+}
+
+func (l *scriptLease) Key() string {
+	return l.key
+}
+
+func (l *scriptLease) Refresh(ctx context.Context, ttl time.Duration) error {
+	refreshed, err := l.refreshScript.Run(ctx, l.client, []string{l.redisKey}, l.token, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if refreshed == 0 {
+		return ErrNotObtained
+	}
+	return nil
+}
+
+func (l *scriptLease) Release(ctx context.Context) error {
+	released, err := l.releaseScript.Run(ctx, l.client, []string{l.redisKey}, l.token).Int64()
+	if err != nil {
+		return err
+	}
+	if released == 0 {
+		return redislock.ErrLockNotHeld
+	}
+	return nil
+}
+
+// leaseKey hash tags key so the keys of a lock share a slot in cluster mode.
+func leaseKey(key, suffix string) string {
+	return "{" + key + "}:" + suffix
+}
+
+func newLeaseToken() (string, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
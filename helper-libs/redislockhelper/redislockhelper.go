@@ -2,11 +2,13 @@ package redislockhelper
 
 import (
 	"context"
+	"errors"
 	"go-clean-arch/helper-libs/loghelper"
 	"go-clean-arch/helper-libs/redisclienthelper"
 	"time"
 
 	"github.com/bsm/redislock"
+	"github.com/redis/go-redis/v9"
 )
 
 type (
@@ -18,6 +20,7 @@ type (
 type (
 	RedisLockHelper struct {
 		locker *redislock.Client
+		client redis.UniversalClient
 	}
 )
 
@@ -31,12 +34,14 @@ func NewRedisLockerHelper(opts *RedisLockerOptions) *RedisLockHelper {
 		locker := redislock.New(opts.RedisClientHelper.ClusterClient)
 		return &RedisLockHelper{
 			locker: locker,
+			client: opts.RedisClientHelper.ClusterClient,
 		}
 	}
 
 	locker := redislock.New(opts.RedisClientHelper.Client)
 	return &RedisLockHelper{
 		locker: locker,
+		client: opts.RedisClientHelper.Client,
 	}
 }
 
@@ -53,6 +58,32 @@ func (h *RedisLockHelper) Obtain(
 	return lock, nil
 }
 
+// ObtainWithWait retries every retryInterval until the lock is obtained, it returns redislock.ErrNotObtained after wait.
+// A zero wait tries once.
+func (h *RedisLockHelper) ObtainWithWait(
+	ctx context.Context,
+	lockKey string,
+	ttl time.Duration,
+	wait time.Duration,
+	retryInterval time.Duration,
+) (*redislock.Lock, error) {
+	if wait <= 0 {
+		return h.locker.Obtain(ctx, lockKey, ttl, nil)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+
+	lock, err := h.locker.Obtain(waitCtx, lockKey, ttl, &redislock.Options{
+		RetryStrategy: redislock.LinearBackoff(retryInterval),
+	})
+	if err != nil && ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+		return nil, redislock.ErrNotObtained
+	}
+	return lock, err
+}
+
+// ObtainWithAutoRefresh refreshes the lock every refreshInterval until ctx is done or the lock is released or lost,
+// refresh errors are only logged: prefer WithLock, which cancels the work when the lock is lost.
 func (h *RedisLockHelper) ObtainWithAutoRefresh(
 	ctx context.Context,
 	lockKey string,
@@ -64,19 +95,20 @@ func (h *RedisLockHelper) ObtainWithAutoRefresh(
 		return lock, err
 	}
 
-	refreshCtx, cancel := context.WithCancel(ctx)
-	defer cancel()
-
+	// The refresh runs past the return of this function, it stops with ctx or once the lock isn't held anymore
 	go func() {
 		ticker := time.NewTicker(time.Second * time.Duration(refreshIntervalInSeconds))
 		defer ticker.Stop()
 
 		for {
 			select {
-			case <-refreshCtx.Done():
+			case <-ctx.Done():
 				return
 			case <-ticker.C:
-				err := lock.Refresh(refreshCtx, time.Second*time.Duration(timeoutInSeconds), nil)
+				err := lock.Refresh(ctx, time.Second*time.Duration(timeoutInSeconds), nil)
+				if errors.Is(err, redislock.ErrNotObtained) {
+					return
+				}
 				if err != nil {
 					loghelper.Logger.Errorf("failed to refresh lock, err: %v", err)
 				}
@@ -87,6 +119,7 @@ func (h *RedisLockHelper) ObtainWithAutoRefresh(
 	return lock, nil
 }
 
+// ObtainWithAutoRefreshCallback refreshes the lock like ObtainWithAutoRefresh, reporting every refresh to the callbacks.
 func (h *RedisLockHelper) ObtainWithAutoRefreshCallback(
 	ctx context.Context,
 	lockKey string,
@@ -100,23 +133,23 @@ func (h *RedisLockHelper) ObtainWithAutoRefreshCallback(
 		return lock, err
 	}
 
-	refreshCtx, cancel := context.WithCancel(ctx)
-	defer cancel()
-
 	go func() {
 		ticker := time.NewTicker(time.Second * time.Duration(refreshIntervalInSeconds))
 		defer ticker.Stop()
 
 		for {
 			select {
-			case <-refreshCtx.Done():
+			case <-ctx.Done():
 				return
 			case <-ticker.C:
-				err := lock.Refresh(refreshCtx, time.Second*time.Duration(timeoutInSeconds), nil)
+				err := lock.Refresh(ctx, time.Second*time.Duration(timeoutInSeconds), nil)
 				if err != nil {
 					if refreshErrorCallback != nil {
 						refreshErrorCallback(err)
 					}
+					if errors.Is(err, redislock.ErrNotObtained) {
+						return
+					}
 				} else {
 					if refreshCallback != nil {
 						refreshCallback()
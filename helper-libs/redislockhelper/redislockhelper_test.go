@@ -0,0 +1,225 @@
+package redislockhelper
+
+import (
+	"context"
+	"errors"
+	"go-clean-arch/helper-libs/loghelper"
+	"go-clean-arch/helper-libs/redisclienthelper"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestLockHelper(t *testing.T) (*RedisLockHelper, *miniredis.Miniredis) {
+	t.Helper()
+	if err := loghelper.InitZap("test", "dev", nil); err != nil {
+		t.Fatal(err)
+	}
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRedisLockerHelper(&RedisLockerOptions{
+		RedisClientHelper: &redisclienthelper.RedisClientHelper{Client: client},
+	}), server
+}
+
+func TestObtainWithAutoRefreshCallback(t *testing.T) {
+	locker, server := newTestLockHelper(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	refreshed := make(chan struct{}, 1)
+	lock, err := locker.ObtainWithAutoRefreshCallback(ctx, "report", 2, 1, func() {
+		select {
+		case refreshed <- struct{}{}:
+		default:
+		}
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.FastForward(1500 * time.Millisecond)
+
+	select {
+	case <-refreshed:
+	case <-time.After(3 * time.Second):
+		t.Fatal("expected the lock to be refreshed after Obtain returned")
+	}
+	if ttl := server.TTL("report"); ttl != 2*time.Second {
+		t.Errorf("expected the ttl to be extended, actual: %v", ttl)
+	}
+	if err := lock.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithLock(t *testing.T) {
+	locker, server := newTestLockHelper(t)
+	ctx := context.Background()
+	opts := &LockOptions{Ttl: time.Second, Wait: 2 * time.Second, Backoff: LinearBackoff(5 * time.Millisecond)}
+
+	var (
+		wg                sync.WaitGroup
+		active, maxActive atomic.Int64
+	)
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := locker.WithLock(ctx, "report", opts, func(ctx context.Context) error {
+				if current := active.Add(1); current > maxActive.Load() {
+					maxActive.Store(current)
+				}
+				time.Sleep(10 * time.Millisecond)
+				active.Add(-1)
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if maxActive.Load() != 1 {
+		t.Errorf("expected exclusive runs, actual: %d concurrent runs", maxActive.Load())
+	}
+
+	failure := errors.New("failed")
+	err := locker.WithLock(ctx, "report", nil, func(ctx context.Context) error {
+		if err := locker.WithLock(ctx, "report", nil, func(ctx context.Context) error { return nil }); !errors.Is(err, ErrNotObtained) {
+			t.Errorf("expected %v while held, actual: %v", ErrNotObtained, err)
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Errorf("expected the error of fn, actual: %v", err)
+	}
+	if server.Exists("report") {
+		t.Error("expected the lock to be released after fn failed")
+	}
+
+	err = locker.WithLock(ctx, "report", &LockOptions{Ttl: 60 * time.Millisecond}, func(ctx context.Context) error {
+		server.Del("report")
+		<-ctx.Done()
+		if !errors.Is(context.Cause(ctx), ErrLockLost) {
+			t.Errorf("expected the context to be canceled by %v, actual: %v", ErrLockLost, context.Cause(ctx))
+		}
+		return ctx.Err()
+	})
+	if !errors.Is(err, ErrLockLost) || !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %v joined with the error of fn, actual: %v", ErrLockLost, err)
+	}
+}
+
+func TestReadWriteLock(t *testing.T) {
+	locker, _ := newTestLockHelper(t)
+	ctx := context.Background()
+	opts := &LockOptions{Ttl: time.Second}
+
+	first, err := locker.ObtainReadLock(ctx, "catalog", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := locker.ObtainReadLock(ctx, "catalog", opts)
+	if err != nil {
+		t.Fatalf("expected readers to share the lock, actual: %v", err)
+	}
+	if _, err := locker.ObtainWriteLock(ctx, "catalog", opts); !errors.Is(err, ErrNotObtained) {
+		t.Errorf("expected %v while read, actual: %v", ErrNotObtained, err)
+	}
+	reader, err := locker.ObtainReadLock(ctx, "catalog", opts)
+	if err != nil {
+		t.Fatalf("expected a writer giving up not to block readers, actual: %v", err)
+	}
+	if err := reader.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	written := make(chan error, 1)
+	go func() {
+		written <- locker.WithWriteLock(ctx, "catalog", &LockOptions{Ttl: time.Second, Wait: 2 * time.Second, Backoff: LinearBackoff(5 * time.Millisecond)},
+			func(ctx context.Context) error { return nil })
+	}()
+	// The waiting writer refuses new readers
+	deadline := time.Now().Add(time.Second)
+	for {
+		reader, err := locker.ObtainReadLock(ctx, "catalog", opts)
+		if errors.Is(err, ErrNotObtained) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = reader.Release(ctx)
+		if time.Now().After(deadline) {
+			t.Fatal("expected readers to be refused while a writer waits")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	for _, lease := range []Lease{first, second} {
+		if err := lease.Release(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := <-written; err != nil {
+		t.Fatalf("expected the writer to get the lock once read, actual: %v", err)
+	}
+	if _, err := locker.ObtainReadLock(ctx, "catalog", opts); err != nil {
+		t.Errorf("expected readers once written, actual: %v", err)
+	}
+}
+
+func TestSemaphore(t *testing.T) {
+	locker, _ := newTestLockHelper(t)
+	ctx := context.Background()
+	opts := &LockOptions{Ttl: time.Second}
+
+	permits := []Lease{}
+	for range 2 {
+		permit, err := locker.AcquirePermit(ctx, "exports", 2, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		permits = append(permits, permit)
+	}
+	if _, err := locker.AcquirePermit(ctx, "exports", 2, opts); !errors.Is(err, ErrNotObtained) {
+		t.Errorf("expected %v once the permits are held, actual: %v", ErrNotObtained, err)
+	}
+	if err := permits[0].Refresh(ctx, time.Second); err != nil {
+		t.Errorf("expected the permit to be refreshed, actual: %v", err)
+	}
+	if err := permits[0].Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := permits[0].Refresh(ctx, time.Second); !errors.Is(err, ErrNotObtained) {
+		t.Errorf("expected %v for a released permit, actual: %v", ErrNotObtained, err)
+	}
+	err := locker.WithSemaphore(ctx, "exports", 2, opts, func(ctx context.Context) error { return nil })
+	if err != nil {
+		t.Errorf("expected the released permit to be acquired, actual: %v", err)
+	}
+	if _, err := locker.AcquirePermit(ctx, "exports", 0, opts); err == nil {
+		t.Error("expected an error for no permits")
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(10*time.Millisecond, 100*time.Millisecond)
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{1, 5 * time.Millisecond, 10 * time.Millisecond},
+		{3, 20 * time.Millisecond, 40 * time.Millisecond},
+		{5, 50 * time.Millisecond, 100 * time.Millisecond},
+		{100, 50 * time.Millisecond, 100 * time.Millisecond},
+	}
+	for _, test := range tests {
+		if delay := backoff(test.attempt); delay < test.min || delay > test.max {
+			t.Errorf("expected attempt %d to wait between %v and %v, actual: %v", test.attempt, test.min, test.max, delay)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package redislockhelper
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// A read/write lock is made of 3 keys: `{key}:writer` holds the token of the writer, `{key}:readers` the tokens
+// of the readers scored by expiry and `{key}:pending` the token of the writer waiting for the readers to leave.
+// New readers are refused while a writer waits so a steady flow of readers doesn't starve writers.
+
+// acquireReadScript takes a read lease for ARGV[1] during ARGV[2] ms unless a writer holds or waits for the lock.
+var acquireReadScript = redis.NewScript(redisNowMs + `
+redis.call('ZREMRANGEBYSCORE', KEYS[2], '-inf', nowMs)
+if redis.call('EXISTS', KEYS[1]) == 1 or redis.call('EXISTS', KEYS[3]) == 1 then
+	return 0
+end
+redis.call('ZADD', KEYS[2], nowMs + tonumber(ARGV[2]), ARGV[1])
+if redis.call('PTTL', KEYS[2]) < tonumber(ARGV[2]) then
+	redis.call('PEXPIRE', KEYS[2], ARGV[2])
+end
+return 1
+`)
+
+// acquireWriteScript takes the write lease for ARGV[1] during ARGV[2] ms once there is neither a writer nor a
+// reader, otherwise it marks ARGV[1] as the pending writer when there is none.
+var acquireWriteScript = redis.NewScript(redisNowMs + `
+redis.call('ZREMRANGEBYSCORE', KEYS[2], '-inf', nowMs)
+local pending = redis.call('GET', KEYS[3])
+if pending ~= false and pending ~= ARGV[1] then
+	return 0
+end
+if redis.call('EXISTS', KEYS[1]) == 1 or redis.call('ZCARD', KEYS[2]) > 0 then
+	redis.call('SET', KEYS[3], ARGV[1], 'PX', ARGV[2])
+	return 0
+end
+if pending ~= false then
+	redis.call('DEL', KEYS[3])
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return 1
+`)
+
+// WithReadLock runs fn while holding a shared lease of key, see WithLock. Readers run concurrently, and never
+// while a writer holds the lock of key.
+func (h *RedisLockHelper) WithReadLock(ctx context.Context, lockKey string, opts *LockOptions, fn func(ctx context.Context) error) error {
+	opts = opts.withDefaults()
+	lease, err := h.ObtainReadLock(ctx, lockKey, opts)
+	if err != nil {
+		return err
+	}
+	return hold(ctx, lease, opts.Ttl, fn)
+}
+
+// WithWriteLock runs fn while holding the exclusive lease of key, see WithLock. The writer waits for the
+// readers to leave, new readers are refused meanwhile.
+func (h *RedisLockHelper) WithWriteLock(ctx context.Context, lockKey string, opts *LockOptions, fn func(ctx context.Context) error) error {
+	opts = opts.withDefaults()
+	lease, err := h.ObtainWriteLock(ctx, lockKey, opts)
+	if err != nil {
+		return err
+	}
+	return hold(ctx, lease, opts.Ttl, fn)
+}
+
+// ObtainReadLock returns a shared lease of key, it must be refreshed before opts.Ttl and released by the caller.
+func (h *RedisLockHelper) ObtainReadLock(ctx context.Context, lockKey string, opts *LockOptions) (Lease, error) {
+	opts = opts.withDefaults()
+	token, err := newLeaseToken()
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{leaseKey(lockKey, "writer"), leaseKey(lockKey, "readers"), leaseKey(lockKey, "pending")}
+	return obtainWithRetry(ctx, opts, func(ctx context.Context) (Lease, error) {
+		obtained, err := acquireReadScript.Run(ctx, h.client, keys, token, opts.Ttl.Milliseconds()).Int64()
+		if err != nil {
+			return nil, err
+		}
+		if obtained == 0 {
+			return nil, ErrNotObtained
+		}
+		return &scriptLease{
+			client:        h.client,
+			key:           lockKey,
+			redisKey:      keys[1],
+			token:         token,
+			refreshScript: refreshMemberScript,
+			releaseScript: releaseMemberScript,
+		}, nil
+	})
+}
+
+// ObtainWriteLock returns the exclusive lease of key, it must be refreshed before opts.Ttl and released by the caller.
+func (h *RedisLockHelper) ObtainWriteLock(ctx context.Context, lockKey string, opts *LockOptions) (Lease, error) {
+	opts = opts.withDefaults()
+	token, err := newLeaseToken()
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{leaseKey(lockKey, "writer"), leaseKey(lockKey, "readers"), leaseKey(lockKey, "pending")}
+	lease, err := obtainWithRetry(ctx, opts, func(ctx context.Context) (Lease, error) {
+		obtained, err := acquireWriteScript.Run(ctx, h.client, keys, token, opts.Ttl.Milliseconds()).Int64()
+		if err != nil {
+			return nil, err
+		}
+		if obtained == 0 {
+			return nil, ErrNotObtained
+		}
+		return &scriptLease{
+			client:        h.client,
+			key:           lockKey,
+			redisKey:      keys[0],
+			token:         token,
+			refreshScript: refreshOwnerScript,
+			releaseScript: releaseOwnerScript,
+		}, nil
+	})
+	if err != nil {
+		// Readers must not wait for a writer that gave up
+		pending := &scriptLease{client: h.client, key: lockKey, redisKey: keys[2], token: token, releaseScript: releaseOwnerScript}
+		release(ctx, pending)
+		return nil, err
+	}
+	return lease, nil
+}
@@ -0,0 +1,62 @@
+package redislockhelper
+
+import (
+	"context"
+	"errors"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// acquirePermitScript adds ARGV[1] to the permits KEYS[1], scored by expiry, when less than ARGV[3] are held.
+var acquirePermitScript = redis.NewScript(redisNowMs + `
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', nowMs)
+if redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[3]) then
+	return 0
+end
+redis.call('ZADD', KEYS[1], nowMs + tonumber(ARGV[2]), ARGV[1])
+if redis.call('PTTL', KEYS[1]) < tonumber(ARGV[2]) then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+// WithSemaphore runs fn while holding one of the permits of key, see WithLock. At most permits holders run at
+// once across instances, every holder must use the same permits.
+func (h *RedisLockHelper) WithSemaphore(ctx context.Context, key string, permits int, opts *LockOptions, fn func(ctx context.Context) error) error {
+	opts = opts.withDefaults()
+	lease, err := h.AcquirePermit(ctx, key, permits, opts)
+	if err != nil {
+		return err
+	}
+	return hold(ctx, lease, opts.Ttl, fn)
+}
+
+// AcquirePermit returns one of the permits of key, it must be refreshed before opts.Ttl and released by the caller.
+func (h *RedisLockHelper) AcquirePermit(ctx context.Context, key string, permits int, opts *LockOptions) (Lease, error) {
+	if permits <= 0 {
+		return nil, errors.New("semaphore permits must be positive")
+	}
+	opts = opts.withDefaults()
+	token, err := newLeaseToken()
+	if err != nil {
+		return nil, err
+	}
+	permitsKey := leaseKey(key, "permits")
+	return obtainWithRetry(ctx, opts, func(ctx context.Context) (Lease, error) {
+		acquired, err := acquirePermitScript.Run(ctx, h.client, []string{permitsKey}, token, opts.Ttl.Milliseconds(), permits).Int64()
+		if err != nil {
+			return nil, err
+		}
+		if acquired == 0 {
+			return nil, ErrNotObtained
+		}
+		return &scriptLease{
+			client:        h.client,
+			key:           key,
+			redisKey:      permitsKey,
+			token:         token,
+			refreshScript: refreshMemberScript,
+			releaseScript: releaseMemberScript,
+		}, nil
+	})
+}
@@ -0,0 +1,178 @@
+package redislockhelper
+
+import (
+	"context"
+	"errors"
+	"go-clean-arch/helper-libs/loghelper"
+	mathrand "math/rand"
+	"time"
+
+	"github.com/bsm/redislock"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultLockTtl     = 30 * time.Second
+	releaseTimeout     = 5 * time.Second
+	defaultMinBackoff  = 50 * time.Millisecond
+	defaultMaxBackoff  = time.Second
+	exponentialMaxStep = 20
+)
+
+var (
+	// ErrNotObtained is returned when the lock is still held by others once LockOptions.Wait elapsed.
+	ErrNotObtained = redislock.ErrNotObtained
+	// ErrLockLost cancels the context of the work when the lock expired or was taken over while it ran.
+	ErrLockLost = errors.New("lock lost")
+)
+
+type (
+	// Backoff returns the delay before the attempt-th retry, attempts start at 1.
+	Backoff func(attempt int) time.Duration
+
+	LockOptions struct {
+		// Ttl is how long the lock outlives a holder that stopped refreshing, it falls back to 30s.
+		// The lock is refreshed every Ttl/3 while the work runs.
+		Ttl time.Duration
+		// Wait bounds the attempts to obtain the lock, a zero Wait tries once.
+		Wait time.Duration
+		// Backoff paces the attempts, it falls back to ExponentialBackoff(50ms, 1s).
+		Backoff Backoff
+	}
+)
+
+// LinearBackoff waits interval between the attempts.
+func LinearBackoff(interval time.Duration) Backoff {
+	return func(attempt int) time.Duration {
+		return interval
+	}
+}
+
+// ExponentialBackoff doubles the delay from min up to max, the delay is jittered between its half and itself
+// so contenders don't retry in lockstep.
+func ExponentialBackoff(min, max time.Duration) Backoff {
+	return func(attempt int) time.Duration {
+		delay := max
+		if attempt <= exponentialMaxStep {
+			if step := min << (attempt - 1); step > 0 && step < max {
+				delay = step
+			}
+		}
+		half := int64(delay / 2)
+		if half <= 0 {
+			return delay
+		}
+		return time.Duration(half + mathrand.Int63n(half+1))
+	}
+}
+
+// WithLock runs fn while holding the exclusive lock of key, fn's context is canceled with ErrLockLost when the
+// lock is lost. The lock is always released, WithLock returns ErrNotObtained when it couldn't be obtained, or
+// the error of fn joined with ErrLockLost when the lock was lost meanwhile.
+func (h *RedisLockHelper) WithLock(ctx context.Context, lockKey string, opts *LockOptions, fn func(ctx context.Context) error) error {
+	opts = opts.withDefaults()
+	lease, err := obtainWithRetry(ctx, opts, func(ctx context.Context) (Lease, error) {
+		lock, err := h.locker.Obtain(ctx, lockKey, opts.Ttl, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &mutexLease{lock: lock}, nil
+	})
+	if err != nil {
+		return err
+	}
+	return hold(ctx, lease, opts.Ttl, fn)
+}
+
+func (o *LockOptions) withDefaults() *LockOptions {
+	opts := LockOptions{}
+	if o != nil {
+		opts = *o
+	}
+	if opts.Ttl <= 0 {
+		opts.Ttl = defaultLockTtl
+	}
+	if opts.Backoff == nil {
+		opts.Backoff = ExponentialBackoff(defaultMinBackoff, defaultMaxBackoff)
+	}
+	return &opts
+}
+
+// obtainWithRetry calls obtain until it succeeds, fails with another error than ErrNotObtained or opts.Wait elapsed.
+func obtainWithRetry(ctx context.Context, opts *LockOptions, obtain func(ctx context.Context) (Lease, error)) (Lease, error) {
+	deadline := time.Now().Add(opts.Wait)
+	for attempt := 1; ; attempt++ {
+		lease, err := obtain(ctx)
+		if !errors.Is(err, ErrNotObtained) {
+			return lease, err
+		}
+		delay := opts.Backoff(attempt)
+		if remaining := time.Until(deadline); remaining <= 0 {
+			return nil, ErrNotObtained
+		} else if delay > remaining {
+			delay = remaining
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// hold runs fn while refreshing lease every ttl/3. A lease failing to refresh is lost once ttl elapsed since
+// its last refresh, redis may have expired it.
+func hold(ctx context.Context, lease Lease, ttl time.Duration, fn func(ctx context.Context) error) error {
+	defer release(ctx, lease)
+	workCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	refreshed := make(chan struct{})
+	go func() {
+		defer close(refreshed)
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+		lastRefresh := time.Now()
+		for {
+			select {
+			case <-workCtx.Done():
+				return
+			case <-ticker.C:
+			}
+			err := lease.Refresh(workCtx, ttl)
+			switch {
+			case err == nil:
+				lastRefresh = time.Now()
+			case workCtx.Err() != nil:
+				return
+			case errors.Is(err, ErrNotObtained) || time.Since(lastRefresh) >= ttl:
+				loghelper.Logger.Warnw("lock lost", zap.String("key", lease.Key()), zap.Error(err))
+				cancel(ErrLockLost)
+				return
+			default:
+				loghelper.Logger.Warnw("failed to refresh lock", zap.String("key", lease.Key()), zap.Error(err))
+			}
+		}
+	}()
+
+	err := fn(workCtx)
+	lost := errors.Is(context.Cause(workCtx), ErrLockLost)
+	cancel(nil)
+	<-refreshed
+	if lost {
+		return errors.Join(ErrLockLost, err)
+	}
+	return err
+}
+
+// release uses a fresh context so the lease is released when ctx is already done.
+func release(ctx context.Context, lease Lease) {
+	releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), releaseTimeout)
+	defer cancel()
+	if err := lease.Release(releaseCtx); err != nil && !errors.Is(err, redislock.ErrLockNotHeld) {
+		loghelper.Logger.Warnw("failed to release lock", zap.String("key", lease.Key()), zap.Error(err))
+	}
+}
@@ -0,0 +1,126 @@
+package schedulerhelper
+
+import (
+	"context"
+	"go-clean-arch/helper-libs/loghelper"
+	"go-clean-arch/helper-libs/sqlormhelper"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultHistoryTable = "scheduler_job_runs"
+	defaultHistoryLimit = 20
+)
+
+type (
+	PostgresRunHistoryOptions struct {
+		Sql sqlormhelper.SqlGormDatabase
+		// Table falls back to `scheduler_job_runs`.
+		Table string
+	}
+
+	PostgresRunHistory interface {
+		RunHistory
+		// Migrate creates the table and its job index.
+		Migrate(ctx context.Context) error
+		// Purge removes the runs started before before.
+		Purge(ctx context.Context, before time.Time) (int64, error)
+	}
+
+	jobRunEntity struct {
+		Id         string     `gorm:"column:id;primaryKey;type:uuid"`
+		Job        string     `gorm:"column:job;type:text;not null;index:idx_scheduler_job_runs_job_started_at,priority:1"`
+		Trigger    string     `gorm:"column:trigger;type:text;not null"`
+		Status     string     `gorm:"column:status;type:text;not null"`
+		Instance   string     `gorm:"column:instance;type:text"`
+		StartedAt  time.Time  `gorm:"column:started_at;type:timestamptz;not null;index:idx_scheduler_job_runs_job_started_at,priority:2,sort:desc"`
+		FinishedAt *time.Time `gorm:"column:finished_at;type:timestamptz"`
+		Error      string     `gorm:"column:error;type:text"`
+		sqlormhelper.BaseEntity
+	}
+
+	postgresRunHistory struct {
+		sql   sqlormhelper.SqlGormDatabase
+		table string
+	}
+)
+
+func NewPostgresRunHistory(opts *PostgresRunHistoryOptions) PostgresRunHistory {
+	if opts.Sql == nil {
+		loghelper.Logger.Panic("sql gorm database must specific")
+	}
+	table := opts.Table
+	if table == "" {
+		table = defaultHistoryTable
+	}
+	return &postgresRunHistory{
+		sql:   opts.Sql,
+		table: table,
+	}
+}
+
+func (h *postgresRunHistory) Migrate(ctx context.Context) error {
+	return h.db(ctx).AutoMigrate(&jobRunEntity{})
+}
+
+func (h *postgresRunHistory) Save(ctx context.Context, record *RunRecord) error {
+	return h.db(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"status", "finished_at", "error", "last_modified_time"}),
+	}).Create(newJobRunEntity(record)).Error
+}
+
+// List falls back to the last 20 runs when limit isn't positive.
+func (h *postgresRunHistory) List(ctx context.Context, job string, limit int) ([]RunRecord, error) {
+	if limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+	entities := []jobRunEntity{}
+	err := h.db(ctx).Where("job = ?", job).Order("started_at DESC").Limit(limit).Find(&entities).Error
+	if err != nil {
+		return nil, err
+	}
+	records := make([]RunRecord, 0, len(entities))
+	for _, entity := range entities {
+		records = append(records, entity.toRecord())
+	}
+	return records, nil
+}
+
+func (h *postgresRunHistory) Purge(ctx context.Context, before time.Time) (int64, error) {
+	result := h.db(ctx).Where("started_at < ?", before).Delete(&jobRunEntity{})
+	return result.RowsAffected, result.Error
+}
+
+func (h *postgresRunHistory) db(ctx context.Context) *gorm.DB {
+	return h.sql.Open().WithContext(ctx).Table(h.table)
+}
+
+func newJobRunEntity(record *RunRecord) *jobRunEntity {
+	return &jobRunEntity{
+		Id:         record.Id,
+		Job:        record.Job,
+		Trigger:    string(record.Trigger),
+		Status:     string(record.Status),
+		Instance:   record.Instance,
+		StartedAt:  record.StartedAt,
+		FinishedAt: record.FinishedAt,
+		Error:      record.Error,
+	}
+}
+
+func (e *jobRunEntity) toRecord() RunRecord {
+	return RunRecord{
+		Id:         e.Id,
+		Job:        e.Job,
+		Trigger:    RunTrigger(e.Trigger),
+		Status:     RunStatus(e.Status),
+		Instance:   e.Instance,
+		StartedAt:  e.StartedAt,
+		FinishedAt: e.FinishedAt,
+		Error:      e.Error,
+	}
+}
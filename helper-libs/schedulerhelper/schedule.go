@@ -0,0 +1,181 @@
+package schedulerhelper
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronDescriptors are the shortcuts accepted in place of the 5 fields.
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type (
+	// Schedule returns the next activation strictly after t.
+	Schedule interface {
+		Next(t time.Time) time.Time
+	}
+
+	intervalSchedule struct {
+		interval time.Duration
+	}
+
+	// cronSchedule holds a bit per allowed value of each field.
+	cronSchedule struct {
+		minute, hour, dom, month, dow uint64
+		// domAny and dowAny are set for `*`, cron matches either day field when both are restricted
+		domAny, dowAny bool
+		location       *time.Location
+	}
+
+	cronField struct {
+		name     string
+		min, max int
+	}
+)
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12},
+	{name: "day of week", min: 0, max: 6},
+}
+
+// Every runs at a fixed interval. Activations are aligned on multiples of interval so instances agree on them.
+func Every(interval time.Duration) Schedule {
+	return &intervalSchedule{interval: interval}
+}
+
+// ParseSchedule parses a 5 field cron expression `minute hour day-of-month month day-of-week` in local time, a
+// descriptor like `@hourly`, or `@every <duration>`. Fields accept `*`, values, ranges `1-5`, steps `*/15` and
+// lists `1,15`.
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if interval, found := strings.CutPrefix(spec, "@every "); found {
+		duration, err := time.ParseDuration(strings.TrimSpace(interval))
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+		}
+		if duration <= 0 {
+			return nil, fmt.Errorf("invalid schedule %q: interval must be positive", spec)
+		}
+		return Every(duration), nil
+	}
+	if expression, exists := cronDescriptors[spec]; exists {
+		spec = expression
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("invalid schedule %q: expected %d fields, got %d", spec, len(cronFields), len(fields))
+	}
+	bits := make([]uint64, len(fields))
+	for i, field := range fields {
+		var err error
+		if bits[i], err = cronFields[i].parse(field); err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+		}
+	}
+	// 7 is accepted for sunday
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+	return &cronSchedule{
+		minute:   bits[0],
+		hour:     bits[1],
+		dom:      bits[2],
+		month:    bits[3],
+		dow:      bits[4],
+		domAny:   fields[2] == "*",
+		dowAny:   fields[4] == "*",
+		location: time.Local,
+	}, nil
+}
+
+func (s *intervalSchedule) Next(t time.Time) time.Time {
+	return t.Truncate(s.interval).Add(s.interval)
+}
+
+// Next walks forward by the coarsest unit that doesn't match, giving up after 5 years for impossible dates
+// like `0 0 30 2 *`.
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	t = t.In(s.location).Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.location)
+		case !s.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.location)
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.location)
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (s *cronSchedule) matchDay(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+func (f cronField) parse(field string) (uint64, error) {
+	max := f.max
+	if f.name == "day of week" {
+		max = 7
+	}
+	bits := uint64(0)
+	for _, part := range strings.Split(field, ",") {
+		expression, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepText); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q of %s", stepText, f.name)
+			}
+		}
+
+		start, end := f.min, max
+		if expression != "*" {
+			startText, endText, isRange := strings.Cut(expression, "-")
+			var err error
+			if start, err = strconv.Atoi(startText); err != nil {
+				return 0, fmt.Errorf("invalid value %q of %s", startText, f.name)
+			}
+			end = start
+			if isRange {
+				if end, err = strconv.Atoi(endText); err != nil {
+					return 0, fmt.Errorf("invalid value %q of %s", endText, f.name)
+				}
+			} else if hasStep {
+				end = max
+			}
+		} else if !hasStep {
+			end = f.max
+		}
+		if start < f.min || end > max || start > end {
+			return 0, fmt.Errorf("%s must be between %d and %d, got %q", f.name, f.min, max, part)
+		}
+		for value := start; value <= end; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
@@ -0,0 +1,63 @@
+package schedulerhelper
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	from := time.Date(2024, time.January, 31, 10, 17, 30, 0, time.Local) // wednesday
+	tests := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2024, time.January, 31, 10, 18, 0, 0, time.Local)},
+		{"*/15 * * * *", time.Date(2024, time.January, 31, 10, 30, 0, 0, time.Local)},
+		{"5,20 10-12 * * *", time.Date(2024, time.January, 31, 10, 20, 0, 0, time.Local)},
+		{"0 9 * * 1-5", time.Date(2024, time.February, 1, 9, 0, 0, 0, time.Local)},
+		{"0 0 * * 7", time.Date(2024, time.February, 4, 0, 0, 0, 0, time.Local)},
+		{"0 0 29 2 *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.Local)},
+		// both day fields restricted: either matches
+		{"0 0 15 * 5", time.Date(2024, time.February, 2, 0, 0, 0, 0, time.Local)},
+		{"30 2/6 * * *", time.Date(2024, time.January, 31, 14, 30, 0, 0, time.Local)},
+		{"@hourly", time.Date(2024, time.January, 31, 11, 0, 0, 0, time.Local)},
+		{"@monthly", time.Date(2024, time.February, 1, 0, 0, 0, 0, time.Local)},
+		{"@yearly", time.Date(2025, time.January, 1, 0, 0, 0, 0, time.Local)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			schedule, err := ParseSchedule(test.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := schedule.Next(from); !actual.Equal(test.expected) {
+				t.Errorf("expected %v, actual: %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseScheduleInvalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8",
+		"*/0 * * * *", "5-1 * * * *", "a * * * *", "@every", "@every -1s", "@every soon"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("expected an error for %q", spec)
+		}
+	}
+}
+
+func TestEvery(t *testing.T) {
+	schedule, err := ParseSchedule("@every 10m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := time.Date(2024, time.January, 31, 10, 17, 30, 0, time.UTC)
+	expected := time.Date(2024, time.January, 31, 10, 20, 0, 0, time.UTC)
+	if actual := schedule.Next(from); !actual.Equal(expected) {
+		t.Errorf("expected activations aligned on the interval %v, actual: %v", expected, actual)
+	}
+	if actual := schedule.Next(expected); !actual.Equal(expected.Add(10 * time.Minute)) {
+		t.Errorf("expected the next activation after %v, actual: %v", expected, actual)
+	}
+}
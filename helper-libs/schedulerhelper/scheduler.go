@@ -0,0 +1,579 @@
+package schedulerhelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-clean-arch/helper-libs/loghelper"
+	"go-clean-arch/helper-libs/redisleaderhelper"
+	"go-clean-arch/helper-libs/redislockhelper"
+	"go-clean-arch/helper-libs/uuidhelper"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bsm/redislock"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+const (
+	// JobMode_Leader runs the job on the elected leader only, its context is canceled when the leadership is lost.
+	JobMode_Leader JobMode = "leader"
+	// JobMode_Lock runs each activation on the first instance claiming it, any instance may run the job.
+	JobMode_Lock JobMode = "lock"
+
+	RunTrigger_Schedule RunTrigger = "schedule"
+	RunTrigger_Manual   RunTrigger = "manual"
+
+	RunStatus_Running   RunStatus = "running"
+	RunStatus_Succeeded RunStatus = "succeeded"
+	RunStatus_Failed    RunStatus = "failed"
+	RunStatus_TimedOut  RunStatus = "timed_out"
+	RunStatus_Canceled  RunStatus = "canceled"
+
+	defaultTimeout    = 10 * time.Minute
+	defaultLockPrefix = "scheduler:"
+	// lockMargin keeps the locks a bit longer than the timeout of the run they protect
+	lockMargin     = 5 * time.Second
+	historyTimeout = 5 * time.Second
+)
+
+var (
+	ErrAlreadyRunning = errors.New("scheduler is already running")
+	ErrNotRunning     = errors.New("scheduler is not running")
+	ErrJobNotFound    = errors.New("job not found")
+	ErrJobRunning     = errors.New("job is already running")
+)
+
+var (
+	jobRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "scheduler_job_runs_total",
+		Help: "Finished job runs by status.",
+	}, []string{"job", "status"})
+	jobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "scheduler_job_duration_seconds",
+		Help:    "Duration of the job runs.",
+		Buckets: prometheus.ExponentialBuckets(0.01, 4, 10),
+	}, []string{"job"})
+	jobSkipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "scheduler_job_skipped_total",
+		Help: "Activations skipped because the previous run was still running.",
+	}, []string{"job"})
+)
+
+func init() {
+	prometheus.MustRegister(jobRuns, jobDuration, jobSkipped)
+}
+
+type fencingTokenKey struct{}
+
+type (
+	JobMode    string
+	RunTrigger string
+	RunStatus  string
+
+	JobFunc func(ctx context.Context) error
+
+	Job struct {
+		Name string
+		// Schedule is a cron expression, see ParseSchedule. Interval is used when it is empty.
+		Schedule string
+		Interval time.Duration
+		// Mode falls back to JobMode_Leader.
+		Mode JobMode
+		// Timeout cancels the run, it falls back to 10m.
+		Timeout time.Duration
+		// Jitter delays every activation by a random duration up to Jitter, so jobs sharing a schedule don't start
+		// at once.
+		Jitter time.Duration
+		Run    JobFunc
+	}
+
+	JobInfo struct {
+		Name      string     `json:"name"`
+		Schedule  string     `json:"schedule"`
+		Mode      JobMode    `json:"mode"`
+		TimeoutMs int64      `json:"timeout_ms"`
+		Running   bool       `json:"running"`
+		NextRun   *time.Time `json:"next_run,omitempty"`
+		// LastRun is the last run started by this instance.
+		LastRun *RunRecord `json:"last_run,omitempty"`
+	}
+
+	RunRecord struct {
+		Id         string     `json:"id"`
+		Job        string     `json:"job"`
+		Trigger    RunTrigger `json:"trigger"`
+		Status     RunStatus  `json:"status"`
+		Instance   string     `json:"instance"`
+		StartedAt  time.Time  `json:"started_at"`
+		FinishedAt *time.Time `json:"finished_at,omitempty"`
+		Error      string     `json:"error,omitempty"`
+	}
+
+	// RunHistory persists the runs, Save is called when a run starts and again when it finishes.
+	RunHistory interface {
+		Save(ctx context.Context, record *RunRecord) error
+		// List returns the last runs of job, the most recent first.
+		List(ctx context.Context, job string, limit int) ([]RunRecord, error)
+	}
+
+	SchedulerOptions struct {
+		// Leader is required by JobMode_Leader jobs, the scheduler consumes its Leadership channel.
+		Leader redisleaderhelper.RedisLeaderHelper
+		// Locker is required by JobMode_Lock jobs. When set, every run also holds a lock per job so runs don't
+		// overlap across instances, e.g. a manual trigger on a follower while the leader runs the job.
+		Locker *redislockhelper.RedisLockHelper
+		// LockPrefix prefixes the lock keys, it falls back to `scheduler:`.
+		LockPrefix string
+		// History keeps no runs when nil.
+		History RunHistory
+		// Instance identifies this instance in the history, it falls back to the id of Leader.
+		Instance string
+		// MaxConcurrent bounds the runs of this instance, unbounded when 0.
+		MaxConcurrent int
+	}
+
+	// Scheduler runs registered jobs on their schedule. Runs of a job never overlap: an activation is skipped
+	// while the previous run is still running.
+	Scheduler interface {
+		Register(jobs ...Job) error
+		// Run schedules the jobs until ctx is done, then it cancels the running jobs and waits for them.
+		Run(ctx context.Context) error
+		Jobs() []JobInfo
+		// Trigger starts a run of the job now on this instance whatever its mode, it returns ErrJobRunning when
+		// the job is running.
+		Trigger(ctx context.Context, name string) (*RunRecord, error)
+		// History returns the last runs of the job, ErrJobNotFound for unknown jobs.
+		History(ctx context.Context, name string, limit int) ([]RunRecord, error)
+	}
+
+	scheduledJob struct {
+		Job
+		schedule Schedule
+		running  atomic.Bool
+
+		mu      sync.Mutex
+		nextRun time.Time
+		lastRun *RunRecord
+	}
+
+	scheduler struct {
+		leader     redisleaderhelper.RedisLeaderHelper
+		locker     *redislockhelper.RedisLockHelper
+		lockPrefix string
+		history    RunHistory
+		instance   string
+		slots      chan struct{}
+		started    atomic.Bool
+		wg         sync.WaitGroup
+
+		mu         sync.Mutex
+		jobs       map[string]*scheduledJob
+		ctx        context.Context
+		leadership redisleaderhelper.Leadership
+	}
+)
+
+func NewScheduler(opts *SchedulerOptions) Scheduler {
+	s := &scheduler{
+		leader:     opts.Leader,
+		locker:     opts.Locker,
+		lockPrefix: opts.LockPrefix,
+		history:    opts.History,
+		instance:   opts.Instance,
+		jobs:       map[string]*scheduledJob{},
+	}
+	if s.lockPrefix == "" {
+		s.lockPrefix = defaultLockPrefix
+	}
+	if s.instance == "" && s.leader != nil {
+		s.instance = s.leader.Id()
+	}
+	if opts.MaxConcurrent > 0 {
+		s.slots = make(chan struct{}, opts.MaxConcurrent)
+	}
+	return s
+}
+
+// FencingToken returns the fencing token of the term a JobMode_Leader run belongs to, 0 for other runs.
+// Stores rejecting stale tokens keep a demoted leader from overwriting the work of the new one.
+func FencingToken(ctx context.Context) int64 {
+	token, _ := ctx.Value(fencingTokenKey{}).(int64)
+	return token
+}
+
+// Register adds jobs, it must be called before Run.
+func (s *scheduler) Register(jobs ...Job) error {
+	if s.started.Load() {
+		return ErrAlreadyRunning
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, job := range jobs {
+		scheduled, err := s.newScheduledJob(job)
+		if err != nil {
+			return err
+		}
+		s.jobs[job.Name] = scheduled
+	}
+	return nil
+}
+
+func (s *scheduler) newScheduledJob(job Job) (*scheduledJob, error) {
+	if job.Name == "" {
+		return nil, errors.New("job name is required")
+	}
+	if _, exists := s.jobs[job.Name]; exists {
+		return nil, fmt.Errorf("job %s is already registered", job.Name)
+	}
+	if job.Run == nil {
+		return nil, fmt.Errorf("job %s requires a run func", job.Name)
+	}
+	if job.Mode == "" {
+		job.Mode = JobMode_Leader
+	}
+	switch {
+	case job.Mode == JobMode_Leader && s.leader == nil:
+		return nil, fmt.Errorf("job %s requires a leader in %s mode", job.Name, job.Mode)
+	case job.Mode == JobMode_Lock && s.locker == nil:
+		return nil, fmt.Errorf("job %s requires a locker in %s mode", job.Name, job.Mode)
+	case job.Mode != JobMode_Leader && job.Mode != JobMode_Lock:
+		return nil, fmt.Errorf("job %s has unsupported mode %s", job.Name, job.Mode)
+	}
+	if job.Timeout <= 0 {
+		job.Timeout = defaultTimeout
+	}
+
+	var schedule Schedule
+	switch {
+	case job.Schedule != "":
+		var err error
+		if schedule, err = ParseSchedule(job.Schedule); err != nil {
+			return nil, fmt.Errorf("job %s: %w", job.Name, err)
+		}
+	case job.Interval > 0:
+		schedule = Every(job.Interval)
+		job.Schedule = "@every " + job.Interval.String()
+	default:
+		return nil, fmt.Errorf("job %s requires a schedule or an interval", job.Name)
+	}
+	return &scheduledJob{Job: job, schedule: schedule}, nil
+}
+
+func (s *scheduler) Run(ctx context.Context) error {
+	if !s.started.CompareAndSwap(false, true) {
+		return ErrAlreadyRunning
+	}
+	s.mu.Lock()
+	s.ctx = ctx
+	jobs := make([]*scheduledJob, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job)
+	}
+	s.mu.Unlock()
+
+	var loops sync.WaitGroup
+	if s.leader != nil {
+		loops.Add(1)
+		go func() {
+			defer loops.Done()
+			s.followLeadership(ctx)
+		}()
+	}
+	for _, job := range jobs {
+		loops.Add(1)
+		go func(job *scheduledJob) {
+			defer loops.Done()
+			s.schedule(ctx, job)
+		}(job)
+	}
+	loops.Wait()
+	s.wg.Wait()
+	return nil
+}
+
+func (s *scheduler) Jobs() []JobInfo {
+	s.mu.Lock()
+	jobs := make([]*scheduledJob, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job)
+	}
+	s.mu.Unlock()
+
+	infos := make([]JobInfo, 0, len(jobs))
+	for _, job := range jobs {
+		infos = append(infos, job.info())
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
+func (s *scheduler) Trigger(ctx context.Context, name string) (*RunRecord, error) {
+	s.mu.Lock()
+	runCtx := s.ctx
+	s.mu.Unlock()
+	if runCtx == nil || runCtx.Err() != nil {
+		return nil, ErrNotRunning
+	}
+	job, err := s.job(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.start(ctx, runCtx, job, RunTrigger_Manual, nil)
+}
+
+func (s *scheduler) History(ctx context.Context, name string, limit int) ([]RunRecord, error) {
+	if _, err := s.job(name); err != nil {
+		return nil, err
+	}
+	if s.history == nil {
+		return []RunRecord{}, nil
+	}
+	return s.history.List(ctx, name, limit)
+}
+
+func (s *scheduler) job(name string) (*scheduledJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, exists := s.jobs[name]
+	if !exists {
+		return nil, ErrJobNotFound
+	}
+	return job, nil
+}
+
+func (s *scheduler) followLeadership(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case leadership := <-s.leader.Leadership():
+			s.mu.Lock()
+			s.leadership = leadership
+			s.mu.Unlock()
+		}
+	}
+}
+
+// schedule waits for the activations of job, the jitter delays the start but not the activation time, which
+// is the key all instances agree on.
+func (s *scheduler) schedule(ctx context.Context, job *scheduledJob) {
+	for {
+		activation := job.schedule.Next(time.Now())
+		if activation.IsZero() {
+			loghelper.Logger.Warnw("job has no next activation", zap.String("job", job.Name), zap.String("schedule", job.Schedule))
+			return
+		}
+		job.setNextRun(activation)
+		delay := time.Until(activation)
+		if job.Jitter > 0 {
+			delay += time.Duration(rand.Int63n(int64(job.Jitter)))
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		var leadership *redisleaderhelper.Leadership
+		if job.Mode == JobMode_Leader {
+			s.mu.Lock()
+			current := s.leadership
+			s.mu.Unlock()
+			if !current.IsLeader || current.Context.Err() != nil {
+				continue
+			}
+			leadership = &current
+		} else if !s.claim(ctx, job, activation) {
+			continue
+		}
+
+		if _, err := s.start(ctx, ctx, job, RunTrigger_Schedule, leadership); err != nil {
+			if errors.Is(err, ErrJobRunning) {
+				jobSkipped.WithLabelValues(job.Name).Inc()
+				loghelper.Logger.Warnw("job skipped, previous run still running", zap.String("job", job.Name))
+				continue
+			}
+			loghelper.Logger.Errorw("start job failed", zap.String("job", job.Name), zap.Error(err))
+		}
+	}
+}
+
+// claim takes the activation of a JobMode_Lock job, the claim is never released so the instances firing later
+// because of clock skew or jitter skip it.
+func (s *scheduler) claim(ctx context.Context, job *scheduledJob, activation time.Time) bool {
+	key := s.lockPrefix + job.Name + ":" + strconv.FormatInt(activation.UnixMilli(), 10)
+	_, err := s.locker.Obtain(ctx, key, lockSeconds(job.Timeout))
+	if err != nil && !errors.Is(err, redislock.ErrNotObtained) && ctx.Err() == nil {
+		loghelper.Logger.Errorw("claim job activation failed", zap.String("job", job.Name), zap.Error(err))
+	}
+	return err == nil
+}
+
+// start runs job in the background once it is known not to be running, the run is canceled with parent.
+func (s *scheduler) start(ctx, parent context.Context, job *scheduledJob, trigger RunTrigger, leadership *redisleaderhelper.Leadership) (*RunRecord, error) {
+	if !job.running.CompareAndSwap(false, true) {
+		return nil, ErrJobRunning
+	}
+	var lock *redislock.Lock
+	if s.locker != nil {
+		var err error
+		if lock, err = s.locker.Obtain(ctx, s.lockPrefix+job.Name, lockSeconds(job.Timeout)); err != nil {
+			job.running.Store(false)
+			if errors.Is(err, redislock.ErrNotObtained) {
+				return nil, ErrJobRunning
+			}
+			return nil, err
+		}
+	}
+
+	record := &RunRecord{
+		Id:        uuidhelper.NewUuidV7String(),
+		Job:       job.Name,
+		Trigger:   trigger,
+		Status:    RunStatus_Running,
+		Instance:  s.instance,
+		StartedAt: time.Now(),
+	}
+	job.setLastRun(record)
+	s.save(record)
+
+	started := *record
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer job.running.Store(false)
+		if lock != nil {
+			defer s.release(job, lock)
+		}
+		s.execute(parent, job, record, leadership)
+	}()
+	return &started, nil
+}
+
+func (s *scheduler) execute(parent context.Context, job *scheduledJob, record *RunRecord, leadership *redisleaderhelper.Leadership) {
+	if s.slots != nil {
+		select {
+		case s.slots <- struct{}{}:
+			defer func() { <-s.slots }()
+		case <-parent.Done():
+			s.finish(parent, job, record, parent.Err())
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(parent, job.Timeout)
+	defer cancel()
+	if leadership != nil {
+		// The leader only work stops as soon as the leadership is lost
+		stop := context.AfterFunc(leadership.Context, cancel)
+		defer stop()
+		ctx = context.WithValue(ctx, fencingTokenKey{}, leadership.Token)
+	}
+	s.finish(ctx, job, record, runJob(ctx, job.Run))
+}
+
+func (s *scheduler) finish(ctx context.Context, job *scheduledJob, record *RunRecord, err error) {
+	finished := time.Now()
+	status := RunStatus_Succeeded
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		status = RunStatus_TimedOut
+	case ctx.Err() != nil:
+		status = RunStatus_Canceled
+	case err != nil:
+		status = RunStatus_Failed
+	}
+
+	job.mu.Lock()
+	record.Status, record.FinishedAt = status, &finished
+	if err != nil {
+		record.Error = err.Error()
+	}
+	job.mu.Unlock()
+	s.save(record)
+
+	jobRuns.WithLabelValues(job.Name, string(status)).Inc()
+	jobDuration.WithLabelValues(job.Name).Observe(finished.Sub(record.StartedAt).Seconds())
+	if status != RunStatus_Succeeded {
+		loghelper.Logger.Warnw("job run failed", zap.String("job", job.Name), zap.String("run", record.Id), zap.String("status", string(status)), zap.Error(err))
+	}
+}
+
+// save writes a copy of record with its own timeout, runs finish after the context of the scheduler is done.
+func (s *scheduler) save(record *RunRecord) {
+	if s.history == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), historyTimeout)
+	defer cancel()
+	snapshot := *record
+	if err := s.history.Save(ctx, &snapshot); err != nil {
+		loghelper.Logger.Errorw("save job run failed", zap.String("job", record.Job), zap.String("run", record.Id), zap.Error(err))
+	}
+}
+
+func (s *scheduler) release(job *scheduledJob, lock *redislock.Lock) {
+	ctx, cancel := context.WithTimeout(context.Background(), historyTimeout)
+	defer cancel()
+	if err := lock.Release(ctx); err != nil && !errors.Is(err, redislock.ErrLockNotHeld) {
+		loghelper.Logger.Warnw("release job lock failed", zap.String("job", job.Name), zap.Error(err))
+	}
+}
+
+func (j *scheduledJob) setNextRun(next time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.nextRun = next
+}
+
+func (j *scheduledJob) setLastRun(record *RunRecord) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.lastRun = record
+}
+
+func (j *scheduledJob) info() JobInfo {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	info := JobInfo{
+		Name:      j.Name,
+		Schedule:  j.Schedule,
+		Mode:      j.Mode,
+		TimeoutMs: j.Timeout.Milliseconds(),
+		Running:   j.running.Load(),
+	}
+	if !j.nextRun.IsZero() {
+		next := j.nextRun
+		info.NextRun = &next
+	}
+	if j.lastRun != nil {
+		last := *j.lastRun
+		info.LastRun = &last
+	}
+	return info
+}
+
+// runJob turns a panic of the job into an error so it is recorded like any failure.
+func runJob(ctx context.Context, run JobFunc) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("job panicked: %v", recovered)
+		}
+	}()
+	return run(ctx)
+}
+
+func lockSeconds(timeout time.Duration) int64 {
+	return int64((timeout + lockMargin + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,293 @@
+package schedulerhelper
+
+import (
+	"context"
+	"errors"
+	"go-clean-arch/helper-libs/loghelper"
+	"go-clean-arch/helper-libs/redisclienthelper"
+	"go-clean-arch/helper-libs/redisleaderhelper"
+	"go-clean-arch/helper-libs/redislockhelper"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+type memoryRunHistory struct {
+	mu      sync.Mutex
+	records map[string]RunRecord
+	order   []string
+}
+
+func newMemoryRunHistory() *memoryRunHistory {
+	return &memoryRunHistory{records: map[string]RunRecord{}}
+}
+
+func (h *memoryRunHistory) Save(ctx context.Context, record *RunRecord) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, exists := h.records[record.Id]; !exists {
+		h.order = append(h.order, record.Id)
+	}
+	h.records[record.Id] = *record
+	return nil
+}
+
+func (h *memoryRunHistory) List(ctx context.Context, job string, limit int) ([]RunRecord, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	records := []RunRecord{}
+	for i := len(h.order) - 1; i >= 0 && (limit <= 0 || len(records) < limit); i-- {
+		if record := h.records[h.order[i]]; record.Job == job {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func newTestClientHelper(t *testing.T, server *miniredis.Miniredis) *redisclienthelper.RedisClientHelper {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return &redisclienthelper.RedisClientHelper{Client: client}
+}
+
+// runInBackground runs fn until the test ends, the cleanup waits for it to return.
+func runInBackground(t *testing.T, fn func(ctx context.Context) error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = fn(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func waitFor(t *testing.T, message string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal(message)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func waitForStatus(t *testing.T, history RunHistory, job string, status RunStatus) RunRecord {
+	t.Helper()
+	var record RunRecord
+	waitFor(t, "expected a run of "+job+" with status "+string(status), func() bool {
+		records, _ := history.List(context.Background(), job, 1)
+		if len(records) == 0 {
+			return false
+		}
+		record = records[0]
+		return record.Status == status
+	})
+	return record
+}
+
+func TestSchedulerLeaderJobs(t *testing.T) {
+	if err := loghelper.InitZap("test", "dev", nil); err != nil {
+		t.Fatal(err)
+	}
+	server := miniredis.RunT(t)
+	var (
+		runs   [2]atomic.Int64
+		tokens [2]atomic.Int64
+	)
+	for i, id := range []string{"first", "second"} {
+		leader := redisleaderhelper.NewRedisLeaderHelper(&redisleaderhelper.RedisLeaderOptions{
+			RedisClientHelper: newTestClientHelper(t, server),
+			Key:               "jobs",
+			Id:                id,
+			Ttl:               300 * time.Millisecond,
+			Wait:              10 * time.Millisecond,
+		})
+		scheduler := NewScheduler(&SchedulerOptions{Leader: leader})
+		err := scheduler.Register(Job{
+			Name:     "report",
+			Interval: 20 * time.Millisecond,
+			Run: func(ctx context.Context) error {
+				runs[i].Add(1)
+				tokens[i].Store(FencingToken(ctx))
+				return nil
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		runInBackground(t, leader.Run)
+		runInBackground(t, scheduler.Run)
+	}
+
+	waitFor(t, "expected the leader to run the job", func() bool {
+		return runs[0].Load()+runs[1].Load() >= 3
+	})
+	if runs[0].Load() != 0 && runs[1].Load() != 0 {
+		t.Errorf("expected only the leader to run the job, actual runs: %d and %d", runs[0].Load(), runs[1].Load())
+	}
+	if tokens[0].Load()+tokens[1].Load() != 1 {
+		t.Errorf("expected the fencing token of term 1, actual: %d and %d", tokens[0].Load(), tokens[1].Load())
+	}
+}
+
+func TestSchedulerLockJobs(t *testing.T) {
+	if err := loghelper.InitZap("test", "dev", nil); err != nil {
+		t.Fatal(err)
+	}
+	server := miniredis.RunT(t)
+	var (
+		runs, active, maxActive atomic.Int64
+		started                 = time.Now()
+	)
+	for range 3 {
+		scheduler := NewScheduler(&SchedulerOptions{
+			Locker: redislockhelper.NewRedisLockerHelper(&redislockhelper.RedisLockerOptions{
+				RedisClientHelper: newTestClientHelper(t, server),
+			}),
+		})
+		err := scheduler.Register(Job{
+			Name:     "sync",
+			Interval: 20 * time.Millisecond,
+			Mode:     JobMode_Lock,
+			Jitter:   5 * time.Millisecond,
+			Run: func(ctx context.Context) error {
+				current := active.Add(1)
+				defer active.Add(-1)
+				for previous := maxActive.Load(); current > previous && !maxActive.CompareAndSwap(previous, current); previous = maxActive.Load() {
+				}
+				runs.Add(1)
+				return nil
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		runInBackground(t, scheduler.Run)
+	}
+
+	waitFor(t, "expected the job to run", func() bool { return runs.Load() >= 5 })
+	activations := int64(time.Since(started)/(20*time.Millisecond)) + 1
+	if runs.Load() > activations {
+		t.Errorf("expected each activation to run once, actual: %d runs for %d activations", runs.Load(), activations)
+	}
+	if maxActive.Load() != 1 {
+		t.Errorf("expected runs not to overlap, actual: %d concurrent runs", maxActive.Load())
+	}
+}
+
+func TestSchedulerTrigger(t *testing.T) {
+	if err := loghelper.InitZap("test", "dev", nil); err != nil {
+		t.Fatal(err)
+	}
+	server := miniredis.RunT(t)
+	history := newMemoryRunHistory()
+	scheduler := NewScheduler(&SchedulerOptions{
+		Locker: redislockhelper.NewRedisLockerHelper(&redislockhelper.RedisLockerOptions{
+			RedisClientHelper: newTestClientHelper(t, server),
+		}),
+		History:  history,
+		Instance: "test",
+	})
+	release := make(chan struct{})
+	err := scheduler.Register(
+		Job{Name: "blocking", Schedule: "@yearly", Mode: JobMode_Lock, Run: func(ctx context.Context) error {
+			<-release
+			return nil
+		}},
+		Job{Name: "slow", Schedule: "@yearly", Mode: JobMode_Lock, Timeout: 20 * time.Millisecond, Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+		Job{Name: "failing", Schedule: "@yearly", Mode: JobMode_Lock, Run: func(ctx context.Context) error {
+			panic("boom")
+		}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := scheduler.Trigger(ctx, "blocking"); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("expected %v before Run, actual: %v", ErrNotRunning, err)
+	}
+	runInBackground(t, scheduler.Run)
+	waitFor(t, "expected the scheduler to run", func() bool {
+		_, err := scheduler.Trigger(ctx, "unknown")
+		return errors.Is(err, ErrJobNotFound)
+	})
+
+	record, err := scheduler.Trigger(ctx, "blocking")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Status != RunStatus_Running || record.Trigger != RunTrigger_Manual || record.Instance != "test" {
+		t.Errorf("expected a running manual run, actual: %+v", record)
+	}
+	if _, err := scheduler.Trigger(ctx, "blocking"); !errors.Is(err, ErrJobRunning) {
+		t.Errorf("expected %v, actual: %v", ErrJobRunning, err)
+	}
+	if jobs := scheduler.Jobs(); len(jobs) != 3 || jobs[0].Name != "blocking" || !jobs[0].Running || jobs[0].LastRun.Id != record.Id {
+		t.Errorf("expected blocking to be listed as running, actual: %+v", jobs)
+	}
+	close(release)
+	if finished := waitForStatus(t, history, "blocking", RunStatus_Succeeded); finished.Id != record.Id || finished.FinishedAt == nil {
+		t.Errorf("expected run %s to finish, actual: %+v", record.Id, finished)
+	}
+
+	if _, err := scheduler.Trigger(ctx, "slow"); err != nil {
+		t.Fatal(err)
+	}
+	waitForStatus(t, history, "slow", RunStatus_TimedOut)
+	if _, err := scheduler.Trigger(ctx, "failing"); err != nil {
+		t.Fatal(err)
+	}
+	if failed := waitForStatus(t, history, "failing", RunStatus_Failed); failed.Error != "job panicked: boom" {
+		t.Errorf("expected the panic to be recorded, actual: %q", failed.Error)
+	}
+
+	runs, err := scheduler.History(ctx, "blocking", 10)
+	if err != nil || len(runs) != 1 {
+		t.Errorf("expected 1 run of blocking, actual: %v, %v", runs, err)
+	}
+	if _, err := scheduler.History(ctx, "unknown", 10); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("expected %v, actual: %v", ErrJobNotFound, err)
+	}
+}
+
+func TestSchedulerRegister(t *testing.T) {
+	run := func(ctx context.Context) error { return nil }
+	tests := []struct {
+		name string
+		job  Job
+	}{
+		{"missing name", Job{Interval: time.Minute, Mode: JobMode_Lock, Run: run}},
+		{"missing run", Job{Name: "job", Interval: time.Minute, Mode: JobMode_Lock}},
+		{"missing schedule", Job{Name: "job", Mode: JobMode_Lock, Run: run}},
+		{"invalid schedule", Job{Name: "job", Schedule: "* *", Mode: JobMode_Lock, Run: run}},
+		{"missing leader", Job{Name: "job", Interval: time.Minute, Run: run}},
+		{"unsupported mode", Job{Name: "job", Interval: time.Minute, Mode: "everywhere", Run: run}},
+		{"duplicate", Job{Name: "registered", Interval: time.Minute, Mode: JobMode_Lock, Run: run}},
+	}
+	scheduler := NewScheduler(&SchedulerOptions{Locker: &redislockhelper.RedisLockHelper{}})
+	if err := scheduler.Register(Job{Name: "registered", Interval: time.Minute, Mode: JobMode_Lock, Run: run}); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := scheduler.Register(test.job); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+	if jobs := scheduler.Jobs(); len(jobs) != 1 || jobs[0].Schedule != "@every 1m0s" || jobs[0].Mode != JobMode_Lock {
+		t.Errorf("expected the registered job only, actual: %+v", jobs)
+	}
+}
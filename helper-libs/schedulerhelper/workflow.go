@@ -0,0 +1,19 @@
+package schedulerhelper
+
+import (
+	"go-clean-arch/helper-libs/confighelper"
+	"time"
+)
+
+// NewWorkflowJob builds the job of a workflow, ScheduleCron takes precedence over ScheduleInterval and
+// RequestTimeout is the timeout of the runs, both in seconds.
+func NewWorkflowJob(name string, cfg *confighelper.WorkflowConfig, mode JobMode, run JobFunc) Job {
+	return Job{
+		Name:     name,
+		Schedule: cfg.ScheduleCron,
+		Interval: time.Duration(cfg.ScheduleInterval) * time.Second,
+		Mode:     mode,
+		Timeout:  time.Duration(cfg.RequestTimeout) * time.Second,
+		Run:      run,
+	}
+}
@@ -0,0 +1,74 @@
+package sqlormhelper
+
+import (
+	"context"
+	"errors"
+	"go-clean-arch/helper-libs/loghelper"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/utils"
+)
+
+type gormLogger struct {
+	config logger.Config
+}
+
+// NewGormLogger creates a gorm logger writing through loghelper.Logger, so every statement carries the traceId of its context.
+func NewGormLogger(config logger.Config) logger.Interface {
+	return &gormLogger{
+		config: config,
+	}
+}
+
+func (l *gormLogger) LogMode(level logger.LogLevel) logger.Interface {
+	newLogger := *l
+	newLogger.config.LogLevel = level
+	return &newLogger
+}
+
+func (l *gormLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if l.config.LogLevel >= logger.Info {
+		loghelper.Logger.WithContext(ctx).Infof(msg, data...)
+	}
+}
+
+func (l *gormLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if l.config.LogLevel >= logger.Warn {
+		loghelper.Logger.WithContext(ctx).Warnf(msg, data...)
+	}
+}
+
+func (l *gormLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if l.config.LogLevel >= logger.Error {
+		loghelper.Logger.WithContext(ctx).Errorf(msg, data...)
+	}
+}
+
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.config.LogLevel <= logger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	log := func() *zap.SugaredLogger {
+		sql, rows := fc()
+		return loghelper.Logger.WithContext(ctx).With(
+			zap.String("sql", sql),
+			zap.Int64("rows", rows),
+			zap.Float64("elapsed_ms", float64(elapsed.Nanoseconds())/1e6),
+			zap.String("source", utils.FileWithLineNum()),
+		)
+	}
+
+	switch {
+	case err != nil && l.config.LogLevel >= logger.Error && (!errors.Is(err, gorm.ErrRecordNotFound) || !l.config.IgnoreRecordNotFoundError):
+		log().Errorw("gorm query failed", zap.Error(err))
+	case l.config.SlowThreshold != 0 && elapsed > l.config.SlowThreshold && l.config.LogLevel >= logger.Warn:
+		log().Warnw("gorm slow query", zap.Duration("threshold", l.config.SlowThreshold))
+	case l.config.LogLevel == logger.Info:
+		log().Info("gorm query")
+	}
+}
@@ -30,7 +30,11 @@ func (h gormMysqlDB) Open() *gorm.DB {
 }
 
 func (h *gormMysqlDB) Close() error {
-	return nil
+	sqlDB, err := h.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
 }
 
 func (h gormMysqlDB) Begin() *gorm.DB {
@@ -49,6 +53,14 @@ func (h gormMysqlDB) GetConn() (*gorm.DB, error) {
 	return h.db, nil
 }
 
+func (h gormMysqlDB) Ping(ctx context.Context) error {
+	sqlDB, err := h.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
 func (h gormMysqlDB) List(ctx context.Context, input *ListParams) *gorm.DB {
 	db := h.Open()
 	result := db.Model(input.Model)
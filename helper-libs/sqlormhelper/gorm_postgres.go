@@ -42,7 +42,11 @@ func (h gormPostgresqlDB) Open() *gorm.DB {
 }
 
 func (h *gormPostgresqlDB) Close() error {
-	return nil
+	sqlDB, err := h.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
 }
 
 func (h gormPostgresqlDB) Begin() *gorm.DB {
@@ -61,6 +65,14 @@ func (h gormPostgresqlDB) GetConn() (*gorm.DB, error) {
 	return h.db, nil
 }
 
+func (h gormPostgresqlDB) Ping(ctx context.Context) error {
+	sqlDB, err := h.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
 func (h gormPostgresqlDB) CreateConditions(ctx context.Context, expressions []*ConditionExpression) []any {
 	conds := []any{}
 
@@ -229,6 +241,8 @@ type CRUDHelper interface {
 	// DeletePermanently deletes record matching given conditions permanently.
 	// `cond` can be an instance of the model, then primary key will be used as the condition
 	DeletePermanently(db *gorm.DB, cond ...interface{}) error
+	// RowsAffected returns the number of records affected by the last executed statement.
+	RowsAffected() int64
 }
 
 type crudHelper struct {
@@ -312,7 +326,8 @@ func (cdb *crudHelper) Delete(db *gorm.DB, cond ...interface{}) error {
 			val = val.Elem()
 		}
 		if val.Kind() == reflect.Struct {
-			return db.Delete(cond[0]).Error
+			cdb.GDB = db.Delete(cond[0])
+			return cdb.GDB.Error
 		}
 	}
 	where := ParseCond(cond...)
@@ -328,7 +343,8 @@ func (cdb *crudHelper) DeletePermanently(db *gorm.DB, cond ...interface{}) error
 			val = val.Elem()
 		}
 		if val.Kind() == reflect.Struct {
-			return db.Delete(cond[0]).Error
+			cdb.GDB = db.Delete(cond[0])
+			return cdb.GDB.Error
 		}
 	}
 	where := ParseCond(cond...)
@@ -350,6 +366,11 @@ func (cdb *crudHelper) CreateInBatches(db *gorm.DB, input interface{}, batchSize
 	return cdb.GDB.Error
 }
 
+// RowsAffected returns the number of records affected by the last executed statement.
+func (cdb *crudHelper) RowsAffected() int64 {
+	return cdb.GDB.RowsAffected
+}
+
 func ParseCondWithConfig(cfg gowhere.Config, cond ...interface{}) []interface{} {
 	if len(cond) == 1 {
 		switch c := cond[0].(type) {
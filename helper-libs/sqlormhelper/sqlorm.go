@@ -25,6 +25,7 @@ type (
 		Commit(tx *gorm.DB) *gorm.DB
 		Rollback(tx *gorm.DB) *gorm.DB
 		GetConn() (*gorm.DB, error)
+		Ping(ctx context.Context) error
 		List(ctx context.Context, input *ListParams) *gorm.DB
 	}
 
@@ -0,0 +1,111 @@
+package sqlormhelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-clean-arch/helper-libs/commonhelper"
+
+	"gorm.io/gorm"
+)
+
+type (
+	// UnitOfWork runs several repository calls atomically.
+	UnitOfWork interface {
+		// Do runs fn in a transaction carried by the context given to fn, the transaction is committed when fn returns nil
+		// and rolled back when it returns an error or panics.
+		// A nested Do joins the ambient transaction through a savepoint, so only the nested work is undone on error.
+		Do(ctx context.Context, fn func(ctx context.Context) error) error
+	}
+
+	gormUnitOfWork struct {
+		sql SqlGormDatabase
+	}
+
+	gormTxState struct {
+		tx    *gorm.DB
+		depth int
+	}
+)
+
+func NewUnitOfWork(sql SqlGormDatabase) UnitOfWork {
+	return &gormUnitOfWork{
+		sql: sql,
+	}
+}
+
+func (u *gormUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if state, ok := ctx.Value(commonhelper.ContextKeyType_GormTx).(*gormTxState); ok && state.tx != nil {
+		return doInSavepoint(ctx, state, fn)
+	}
+
+	tx := u.sql.Open().WithContext(ctx).Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			tx.Rollback()
+			panic(recovered)
+		}
+	}()
+
+	err = fn(context.WithValue(ctx, commonhelper.ContextKeyType_GormTx, &gormTxState{tx: tx}))
+	if err != nil {
+		if rollbackErr := tx.Rollback().Error; rollbackErr != nil {
+			return errors.Join(err, fmt.Errorf("rollback: %w", rollbackErr))
+		}
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func doInSavepoint(ctx context.Context, parent *gormTxState, fn func(ctx context.Context) error) (err error) {
+	state := &gormTxState{
+		tx:    parent.tx,
+		depth: parent.depth + 1,
+	}
+	savepoint := fmt.Sprintf("sp_%d", state.depth)
+	if err = state.tx.SavePoint(savepoint).Error; err != nil {
+		return err
+	}
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			state.tx.RollbackTo(savepoint)
+			panic(recovered)
+		}
+	}()
+
+	err = fn(context.WithValue(ctx, commonhelper.ContextKeyType_GormTx, state))
+	if err != nil {
+		if rollbackErr := state.tx.RollbackTo(savepoint).Error; rollbackErr != nil {
+			return errors.Join(err, fmt.Errorf("rollback to savepoint: %w", rollbackErr))
+		}
+		return err
+	}
+	return state.tx.Exec("RELEASE SAVEPOINT " + savepoint).Error
+}
+
+// ContextWithTx returns a copy of ctx carrying tx, repositories resolving their DB from this context join the transaction.
+func ContextWithTx(ctx context.Context, tx *gorm.DB) context.Context {
+	return context.WithValue(ctx, commonhelper.ContextKeyType_GormTx, &gormTxState{tx: tx})
+}
+
+// TxFromContext returns the transaction stored by UnitOfWork.Do or ContextWithTx, if any.
+func TxFromContext(ctx context.Context) (*gorm.DB, bool) {
+	state, ok := ctx.Value(commonhelper.ContextKeyType_GormTx).(*gormTxState)
+	if !ok || state.tx == nil {
+		return nil, false
+	}
+	return state.tx, true
+}
+
+// DBFromContext resolves the ambient transaction of ctx or falls back to the root connection of sql.
+func DBFromContext(ctx context.Context, sql SqlGormDatabase) *gorm.DB {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx.WithContext(ctx)
+	}
+	return sql.Open().WithContext(ctx)
+}
@@ -0,0 +1,159 @@
+package sqlormhelper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// newMockDatabase uses a single connection, so a statement that doesn't join the transaction of its context blocks.
+func newMockDatabase(t *testing.T) (SqlGormDatabase, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock, err: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &gormPostgresqlDB{db: gormDB}, mock
+}
+
+func TestUnitOfWorkCommit(t *testing.T) {
+	database, mock := newMockDatabase(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO account").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := NewUnitOfWork(database).Do(ctx, func(ctx context.Context) error {
+		if _, ok := TxFromContext(ctx); !ok {
+			t.Error("expected transaction in context")
+		}
+		return DBFromContext(ctx, database).Exec("INSERT INTO account VALUES (1)").Error
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnitOfWorkRollbackOnError(t *testing.T) {
+	database, mock := newMockDatabase(t)
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	expected := errors.New("insufficient balance")
+	err := NewUnitOfWork(database).Do(context.Background(), func(ctx context.Context) error {
+		return expected
+	})
+	if !errors.Is(err, expected) {
+		t.Errorf("expected: %v, actual: %v", expected, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnitOfWorkRollbackOnPanic(t *testing.T) {
+	database, mock := newMockDatabase(t)
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic to be propagated")
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}()
+	_ = NewUnitOfWork(database).Do(context.Background(), func(ctx context.Context) error {
+		panic("boom")
+	})
+}
+
+func TestUnitOfWorkNestedSavepoint(t *testing.T) {
+	database, mock := newMockDatabase(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SAVEPOINT sp_2").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT sp_2").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("RELEASE SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	unitOfWork := NewUnitOfWork(database)
+	err := unitOfWork.Do(context.Background(), func(ctx context.Context) error {
+		nestedErr := unitOfWork.Do(ctx, func(ctx context.Context) error {
+			return errors.New("optional step failed")
+		})
+		if nestedErr == nil {
+			t.Error("expected nested error")
+		}
+		return unitOfWork.Do(ctx, func(ctx context.Context) error {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Error("expected panic to be propagated")
+					}
+				}()
+				_ = unitOfWork.Do(ctx, func(ctx context.Context) error {
+					panic("boom")
+				})
+			}()
+			return nil
+		})
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnitOfWorkJoinsAmbientTransaction(t *testing.T) {
+	database, mock := newMockDatabase(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE account").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("RELEASE SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	if _, ok := TxFromContext(context.Background()); ok {
+		t.Error("expected no transaction in a bare context")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	tx := database.Open().WithContext(ctx).Begin()
+	ctx = ContextWithTx(ctx, tx)
+	if resolved := DBFromContext(ctx, database); resolved.Statement.ConnPool != tx.Statement.ConnPool {
+		t.Error("expected the transaction of the context to be resolved")
+	}
+
+	err := NewUnitOfWork(database).Do(ctx, func(ctx context.Context) error {
+		return DBFromContext(ctx, database).Exec("UPDATE account SET balance = 0").Error
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := tx.Commit().Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
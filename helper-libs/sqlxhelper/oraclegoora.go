@@ -73,6 +73,10 @@ func (h oracleGoOraDB) GetConn() (*sqlx.Conn, error) {
 	return h.db.Connx(context.Background())
 }
 
+func (h oracleGoOraDB) Ping(ctx context.Context) error {
+	return h.db.PingContext(ctx)
+}
+
 func initOracleGoOraDB(host string, port int, username, password, database string) (*sqlx.DB, error) {
 	databaseURL := go_ora.BuildUrl(host, port, database, username, password, nil)
 
@@ -73,6 +73,10 @@ func (h postgresqlDB) GetConn() (*sqlx.Conn, error) {
 	return h.db.Connx(context.Background())
 }
 
+func (h postgresqlDB) Ping(ctx context.Context) error {
+	return h.db.PingContext(ctx)
+}
+
 func initPostgresqlDB(host string, port int, username, password, database, schema string) (*sqlx.DB, error) {
 	psqlInfo := fmt.Sprintf(
 		"host=%s port=%d user=%s "+"password=%s dbname=%s sslmode=disable search_path=%s",
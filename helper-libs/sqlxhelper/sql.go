@@ -1,6 +1,10 @@
 package sqlxhelper
 
-import "github.com/jmoiron/sqlx"
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+)
 
 type (
 	SqlDatabase interface {
@@ -12,5 +16,6 @@ type (
 		QueryRowsPaging(stmt string, offset, limit uint32, args []any) (*sqlx.Rows, error)
 		QueryRowPaging(stmt string, offset, limit uint32, args []any) *sqlx.Row
 		GetConn() (*sqlx.Conn, error)
+		Ping(ctx context.Context) error
 	}
 )
@@ -72,6 +72,10 @@ func (h sqlServerDB) GetConn() (*sqlx.Conn, error) {
 	return h.db.Connx(context.Background())
 }
 
+func (h sqlServerDB) Ping(ctx context.Context) error {
+	return h.db.PingContext(ctx)
+}
+
 func initSqlServerDB(host string, port int, username, password, database string) (*sqlx.DB, error) {
 	databaseURL := fmt.Sprintf(
 		"server=%s;port=%d;user id=%s;password=%s;database=%s;",
@@ -0,0 +1,147 @@
+package sqlxhelper
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-clean-arch/helper-libs/commonhelper"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type (
+	// Executor is implemented by both *sqlx.DB and *sqlx.Tx so repositories don't care whether they run in a transaction.
+	Executor interface {
+		sqlx.ExtContext
+		GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+		SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+		NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error)
+	}
+
+	// UnitOfWork runs several repository calls atomically.
+	UnitOfWork interface {
+		// Do runs fn in a transaction carried by the context given to fn, the transaction is committed when fn returns nil
+		// and rolled back when it returns an error or panics.
+		// A nested Do joins the ambient transaction through a savepoint, so only the nested work is undone on error.
+		Do(ctx context.Context, fn func(ctx context.Context) error) error
+	}
+
+	sqlxUnitOfWork struct {
+		sql SqlDatabase
+	}
+
+	sqlxTxState struct {
+		tx    *sqlx.Tx
+		depth int
+	}
+)
+
+func NewUnitOfWork(sql SqlDatabase) UnitOfWork {
+	return &sqlxUnitOfWork{
+		sql: sql,
+	}
+}
+
+func (u *sqlxUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if state, ok := ctx.Value(commonhelper.ContextKeyType_SqlxTx).(*sqlxTxState); ok && state.tx != nil {
+		return doInSavepoint(ctx, state, fn)
+	}
+
+	tx, err := u.sql.Open().BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			_ = tx.Rollback()
+			panic(recovered)
+		}
+	}()
+
+	err = fn(context.WithValue(ctx, commonhelper.ContextKeyType_SqlxTx, &sqlxTxState{tx: tx}))
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return errors.Join(err, fmt.Errorf("rollback: %w", rollbackErr))
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+func doInSavepoint(ctx context.Context, parent *sqlxTxState, fn func(ctx context.Context) error) (err error) {
+	state := &sqlxTxState{
+		tx:    parent.tx,
+		depth: parent.depth + 1,
+	}
+	savepoint := fmt.Sprintf("sp_%d", state.depth)
+	statements := savepointStatements(state.tx.DriverName(), savepoint)
+	if _, err = state.tx.ExecContext(ctx, statements.create); err != nil {
+		return err
+	}
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			_, _ = state.tx.ExecContext(ctx, statements.rollback)
+			panic(recovered)
+		}
+	}()
+
+	err = fn(context.WithValue(ctx, commonhelper.ContextKeyType_SqlxTx, state))
+	if err != nil {
+		if _, rollbackErr := state.tx.ExecContext(ctx, statements.rollback); rollbackErr != nil {
+			return errors.Join(err, fmt.Errorf("rollback to savepoint: %w", rollbackErr))
+		}
+		return err
+	}
+	if statements.release == "" {
+		return nil
+	}
+	_, err = state.tx.ExecContext(ctx, statements.release)
+	return err
+}
+
+type savepointStatement struct {
+	create   string
+	rollback string
+	release  string
+}
+
+func savepointStatements(driverName, savepoint string) savepointStatement {
+	switch driverName {
+	case "sqlserver", "mssql":
+		return savepointStatement{
+			create:   "SAVE TRANSACTION " + savepoint,
+			rollback: "ROLLBACK TRANSACTION " + savepoint,
+		}
+	case "oracle":
+		return savepointStatement{
+			create:   "SAVEPOINT " + savepoint,
+			rollback: "ROLLBACK TO SAVEPOINT " + savepoint,
+		}
+	default:
+		return savepointStatement{
+			create:   "SAVEPOINT " + savepoint,
+			rollback: "ROLLBACK TO SAVEPOINT " + savepoint,
+			release:  "RELEASE SAVEPOINT " + savepoint,
+		}
+	}
+}
+
+// TxFromContext returns the transaction stored by UnitOfWork.Do, if any.
+func TxFromContext(ctx context.Context) (*sqlx.Tx, bool) {
+	state, ok := ctx.Value(commonhelper.ContextKeyType_SqlxTx).(*sqlxTxState)
+	if !ok || state.tx == nil {
+		return nil, false
+	}
+	return state.tx, true
+}
+
+// ExecutorFromContext resolves the ambient transaction of ctx or falls back to the root connection of sql.
+func ExecutorFromContext(ctx context.Context, sql SqlDatabase) Executor {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx
+	}
+	return sql.Open()
+}
@@ -0,0 +1,105 @@
+package sqlxhelper
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+)
+
+func newMockDatabase(t *testing.T) (SqlDatabase, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock, err: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return &postgresqlDB{db: sqlx.NewDb(db, "postgres")}, mock
+}
+
+func TestUnitOfWorkCommit(t *testing.T) {
+	database, mock := newMockDatabase(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO account").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err := NewUnitOfWork(database).Do(context.Background(), func(ctx context.Context) error {
+		if _, ok := TxFromContext(ctx); !ok {
+			t.Error("expected transaction in context")
+		}
+		_, err := ExecutorFromContext(ctx, database).ExecContext(ctx, "INSERT INTO account VALUES (1)")
+		return err
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnitOfWorkRollbackOnError(t *testing.T) {
+	database, mock := newMockDatabase(t)
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	expected := errors.New("insufficient balance")
+	err := NewUnitOfWork(database).Do(context.Background(), func(ctx context.Context) error {
+		return expected
+	})
+	if !errors.Is(err, expected) {
+		t.Errorf("expected: %v, actual: %v", expected, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnitOfWorkRollbackOnPanic(t *testing.T) {
+	database, mock := newMockDatabase(t)
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic to be propagated")
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}()
+	_ = NewUnitOfWork(database).Do(context.Background(), func(ctx context.Context) error {
+		panic("boom")
+	})
+}
+
+func TestUnitOfWorkNestedSavepoint(t *testing.T) {
+	database, mock := newMockDatabase(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("RELEASE SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	unitOfWork := NewUnitOfWork(database)
+	err := unitOfWork.Do(context.Background(), func(ctx context.Context) error {
+		nestedErr := unitOfWork.Do(ctx, func(ctx context.Context) error {
+			return errors.New("optional step failed")
+		})
+		if nestedErr == nil {
+			t.Error("expected nested error")
+		}
+		return unitOfWork.Do(ctx, func(ctx context.Context) error {
+			return nil
+		})
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
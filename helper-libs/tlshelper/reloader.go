@@ -0,0 +1,184 @@
+package tlshelper
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"go-clean-arch/helper-libs/loghelper"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+const (
+	ClientAuth_None             = "none"
+	ClientAuth_Request          = "request"
+	ClientAuth_Require          = "require"
+	ClientAuth_VerifyIfGiven    = "verify_if_given"
+	ClientAuth_RequireAndVerify = "require_and_verify"
+)
+
+// nextProtos are offered through ALPN, http.Server only adds h2 to the outer config and not to the one returned
+// by GetConfigForClient.
+var nextProtos = []string{"h2", "http/1.1"}
+
+type (
+	// CertificateReloader serves the certificate and client CAs of the last successful load,
+	// so renewed files are picked up by new handshakes without restarting the server.
+	CertificateReloader interface {
+		// TLSConfig resolves the current certificate and client CAs on every handshake.
+		TLSConfig() *tls.Config
+		Reload() error
+		// Watch reloads the files on change until ctx is done, a failed reload keeps the current certificate.
+		Watch(ctx context.Context) error
+	}
+
+	certificateReloader struct {
+		certFile   string
+		keyFile    string
+		caCertFile string
+		clientAuth tls.ClientAuthType
+		config     atomic.Pointer[tls.Config]
+	}
+)
+
+// NewServerTLSConfig builds the server config of opts, a config built from files is reloaded on change until ctx is done.
+func NewServerTLSConfig(ctx context.Context, opts *TlsServerOptions) (*tls.Config, error) {
+	if opts.CertFile == "" {
+		return NewServerTLSConfigFromBase64(opts.CertBase64, opts.KeyBase64, opts.RootCACertBase64, opts.ClientAuth)
+	}
+	reloader, err := NewCertificateReloader(opts.CertFile, opts.KeyFile, opts.RootCACertFile, opts.ClientAuth)
+	if err != nil {
+		return nil, err
+	}
+	if err = reloader.Watch(ctx); err != nil {
+		return nil, err
+	}
+	return reloader.TLSConfig(), nil
+}
+
+// NewCertificateReloader loads the files once, caCertFile is optional unless clientAuth verifies client certificates.
+func NewCertificateReloader(certFile, keyFile, caCertFile string, clientAuth tls.ClientAuthType) (CertificateReloader, error) {
+	reloader := &certificateReloader{
+		certFile:   certFile,
+		keyFile:    keyFile,
+		caCertFile: caCertFile,
+		clientAuth: clientAuth,
+	}
+	if err := reloader.Reload(); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// ParseClientAuthType maps the tls_client_auth config values, an empty name is `none`.
+func ParseClientAuthType(name string) (tls.ClientAuthType, error) {
+	switch strings.ToLower(name) {
+	case "", ClientAuth_None:
+		return tls.NoClientCert, nil
+	case ClientAuth_Request:
+		return tls.RequestClientCert, nil
+	case ClientAuth_Require:
+		return tls.RequireAnyClientCert, nil
+	case ClientAuth_VerifyIfGiven:
+		return tls.VerifyClientCertIfGiven, nil
+	case ClientAuth_RequireAndVerify:
+		return tls.RequireAndVerifyClientCert, nil
+	}
+	return tls.NoClientCert, fmt.Errorf("unknown tls client auth %q", name)
+}
+
+func (r *certificateReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: nextProtos,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.config.Load(), nil
+		},
+	}
+}
+
+func (r *certificateReloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load tls key pair: %w", err)
+	}
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   r.clientAuth,
+		NextProtos:   nextProtos,
+	}
+
+	if r.caCertFile != "" {
+		caCert, err := os.ReadFile(r.caCertFile)
+		if err != nil {
+			return fmt.Errorf("read tls root ca: %w", err)
+		}
+		caCertPool := x509.NewCertPool()
+		if !caCertPool.AppendCertsFromPEM(caCert) {
+			return fmt.Errorf("no certificate found in %s", r.caCertFile)
+		}
+		config.ClientCAs = caCertPool
+	} else if r.clientAuth == tls.VerifyClientCertIfGiven || r.clientAuth == tls.RequireAndVerifyClientCert {
+		return errors.New("tls root ca is required to verify client certificates")
+	}
+
+	r.config.Store(config)
+	return nil
+}
+
+// Watch watches the directories rather than the files, so atomic renames and kubernetes `..data` symlink swaps are seen.
+func (r *certificateReloader) Watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	names := map[string]bool{}
+	for _, file := range []string{r.certFile, r.keyFile, r.caCertFile} {
+		if file == "" {
+			continue
+		}
+		names[filepath.Base(file)] = true
+		if err = watcher.Add(filepath.Dir(file)); err != nil {
+			watcher.Close()
+			return fmt.Errorf("watch %s: %w", file, err)
+		}
+	}
+
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				name := filepath.Base(event.Name)
+				if event.Has(fsnotify.Chmod) || (!names[name] && !strings.HasPrefix(name, "..")) {
+					continue
+				}
+				// The cert and the key are rarely written at once, a mismatch is fixed by the next event
+				if err := r.Reload(); err != nil {
+					loghelper.Logger.Warnw("reload tls certificate failed, keep the current certificate", zap.Error(err))
+					continue
+				}
+				loghelper.Logger.Infof("tls certificate reloaded from %s", r.certFile)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				loghelper.Logger.Errorw("watch tls certificate failed", zap.Error(err))
+			}
+		}
+	}()
+	return nil
+}
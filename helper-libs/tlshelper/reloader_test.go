@@ -0,0 +1,141 @@
+package tlshelper
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"go-clean-arch/helper-libs/loghelper"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeSelfSignedCert(t *testing.T, certFile, keyFile string, serial int64) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func currentSerial(t *testing.T, config *tls.Config) int64 {
+	t.Helper()
+	serverConfig, err := config.GetConfigForClient(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(serverConfig.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert.SerialNumber.Int64()
+}
+
+func TestCertificateReloaderWatch(t *testing.T) {
+	_ = loghelper.InitZap("testing", "dev", map[string]string{})
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeSelfSignedCert(t, certFile, keyFile, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	config, err := NewServerTLSConfig(ctx, &TlsServerOptions{CertFile: certFile, KeyFile: keyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serial := currentSerial(t, config); serial != 1 {
+		t.Fatalf("expected serial 1, actual: %d", serial)
+	}
+
+	writeSelfSignedCert(t, certFile, keyFile, 2)
+	deadline := time.Now().Add(5 * time.Second)
+	for currentSerial(t, config) != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("certificate was not reloaded")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestCertificateReloaderNegotiatesHTTP2(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeSelfSignedCert(t, certFile, keyFile, 1)
+	reloader, err := NewCertificateReloader(certFile, keyFile, "", tls.NoClientCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+	go tls.Server(serverConn, reloader.TLSConfig()).Handshake()
+	client := tls.Client(clientConn, &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"h2", "http/1.1"}})
+	if err := client.Handshake(); err != nil {
+		t.Fatal(err)
+	}
+	if protocol := client.ConnectionState().NegotiatedProtocol; protocol != "h2" {
+		t.Errorf("expected h2 to be negotiated, actual: %q", protocol)
+	}
+}
+
+func TestCertificateReloaderRequiresRootCA(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeSelfSignedCert(t, certFile, keyFile, 1)
+
+	if _, err := NewCertificateReloader(certFile, keyFile, "", tls.RequireAndVerifyClientCert); err == nil {
+		t.Error("expected an error verifying client certificates without root ca")
+	}
+	if _, err := NewCertificateReloader(certFile, keyFile, certFile, tls.RequireAndVerifyClientCert); err != nil {
+		t.Errorf("expected no error, actual: %v", err)
+	}
+}
+
+func TestParseClientAuthType(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected tls.ClientAuthType
+		wantErr  bool
+	}{
+		{name: "", expected: tls.NoClientCert},
+		{name: "require_and_verify", expected: tls.RequireAndVerifyClientCert},
+		{name: "VERIFY_IF_GIVEN", expected: tls.VerifyClientCertIfGiven},
+		{name: "always", wantErr: true},
+	}
+	for _, tt := range tests {
+		actual, err := ParseClientAuthType(tt.name)
+		if (err != nil) != tt.wantErr || actual != tt.expected {
+			t.Errorf("%q: expected %v (error %v), actual: %v (%v)", tt.name, tt.expected, tt.wantErr, actual, err)
+		}
+	}
+}
@@ -1,5 +1,9 @@
 package tlshelper
 
+import (
+	"crypto/tls"
+)
+
 type (
 	TlsClientOptions struct {
 		UseTls             bool
@@ -11,4 +15,15 @@ type (
 		RootCACertFile     string
 		InsecureSkipVerify bool
 	}
+
+	// TlsServerOptions prefers the files, which are reloaded on change, over the base64 values.
+	TlsServerOptions struct {
+		CertBase64       string
+		KeyBase64        string
+		RootCACertBase64 string
+		CertFile         string
+		KeyFile          string
+		RootCACertFile   string
+		ClientAuth       tls.ClientAuthType
+	}
 )
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-clean-arch/helper-libs/cachehelper"
+	"go-clean-arch/helper-libs/commonhelper"
+	"go-clean-arch/helper-libs/loghelper"
+	"go-clean-arch/internal/domain"
+	"net/http"
+
+	echo "github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// StatusClientClosedRequest is reported when the client cancels the request before it completes.
+const StatusClientClosedRequest = 499
+
+type (
+	// ErrorResponse is the envelope of every failed request.
+	ErrorResponse struct {
+		Status  commonhelper.APIResponseStatus `json:"status"`
+		Code    domain.ErrorCode               `json:"code"`
+		Message string                         `json:"message"`
+		Details []domain.ErrorDetail           `json:"details,omitempty"`
+		TraceId string                         `json:"trace_id"`
+	}
+)
+
+// HTTPErrorHandler renders every error returned by handlers and middlewares as an ErrorResponse.
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	ctx := c.Request().Context()
+	domainErr := ToDomainError(err)
+	if domainErr.HttpStatus >= http.StatusInternalServerError {
+		loghelper.Logger.WithContext(ctx).Errorw("request failed", zap.String("code", string(domainErr.Code)), zap.Error(err))
+	} else {
+		loghelper.Logger.WithContext(ctx).Infow("request rejected", zap.String("code", string(domainErr.Code)), zap.Error(err))
+	}
+
+	var writeErr error
+	if c.Request().Method == http.MethodHead {
+		writeErr = c.NoContent(domainErr.HttpStatus)
+	} else {
+		writeErr = c.JSON(domainErr.HttpStatus, &ErrorResponse{
+			Status:  responseStatus(domainErr.HttpStatus),
+			Code:    domainErr.Code,
+			Message: domainErr.Message,
+			Details: domainErr.Details,
+			TraceId: commonhelper.GetTraceIdFromContext(ctx),
+		})
+	}
+	if writeErr != nil {
+		loghelper.Logger.WithContext(ctx).Errorw("write error response failed", zap.Error(writeErr))
+	}
+}
+
+// ToDomainError maps err to the domain error sent to the client, unknown errors become internal errors hiding their cause.
+func ToDomainError(err error) *domain.Error {
+	var (
+		domainErr  *domain.Error
+		bindingErr *echo.BindingError
+		httpErr    *echo.HTTPError
+	)
+	switch {
+	case errors.As(err, &domainErr):
+		return domainErr
+	case errors.As(err, &bindingErr):
+		return domain.NewBadRequestError("invalid request").Wrap(err).WithDetails(domain.ErrorDetail{
+			Field:  bindingErr.Field,
+			Reason: fmt.Sprint(bindingErr.Message),
+		})
+	case errors.As(err, &httpErr):
+		message := http.StatusText(httpErr.Code)
+		if text, ok := httpErr.Message.(string); ok && text != "" {
+			message = text
+		}
+		return domain.NewError(errorCodeOf(httpErr.Code), httpErr.Code, message).Wrap(err)
+	case errors.Is(err, domain.ErrNotFound), errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, redis.Nil),
+		errors.Is(err, cachehelper.ErrNotFound):
+		return domain.NewNotFoundError("resource not found").Wrap(err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return domain.NewError(domain.ErrorCode_Timeout, http.StatusGatewayTimeout, "request timed out").Wrap(err)
+	case errors.Is(err, context.Canceled):
+		return domain.NewError(domain.ErrorCode_RequestCanceled, StatusClientClosedRequest, "request canceled").Wrap(err)
+	default:
+		return domain.NewInternalError(err)
+	}
+}
+
+func errorCodeOf(httpStatus int) domain.ErrorCode {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return domain.ErrorCode_BadRequest
+	case http.StatusUnprocessableEntity:
+		return domain.ErrorCode_ValidationFailed
+	case http.StatusUnauthorized:
+		return domain.ErrorCode_Unauthorized
+	case http.StatusForbidden:
+		return domain.ErrorCode_Forbidden
+	case http.StatusNotFound, http.StatusMethodNotAllowed:
+		return domain.ErrorCode_NotFound
+	case http.StatusConflict:
+		return domain.ErrorCode_Conflict
+	case http.StatusTooManyRequests:
+		return domain.ErrorCode_TooManyRequests
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return domain.ErrorCode_Timeout
+	case http.StatusServiceUnavailable:
+		return domain.ErrorCode_Unavailable
+	}
+	if httpStatus >= http.StatusInternalServerError {
+		return domain.ErrorCode_Internal
+	}
+	return domain.ErrorCode_BadRequest
+}
+
+// responseStatus tells clients whether retrying may help, rejected requests must be fixed first.
+func responseStatus(httpStatus int) commonhelper.APIResponseStatus {
+	if httpStatus >= http.StatusInternalServerError {
+		return commonhelper.API_RESP_STATUS__FAILED
+	}
+	return commonhelper.API_RESP_STATUS__REJECT
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-clean-arch/helper-libs/cachehelper"
+	"go-clean-arch/helper-libs/commonhelper"
+	"go-clean-arch/helper-libs/loghelper"
+	"go-clean-arch/internal/domain"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	echo "github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+func TestHTTPErrorHandler(t *testing.T) {
+	if err := loghelper.InitZap("test", "dev", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedCode   domain.ErrorCode
+		expectedResp   commonhelper.APIResponseStatus
+	}{
+		{
+			name:           "domain error",
+			err:            fmt.Errorf("create card: %w", domain.NewValidationError("invalid card", domain.ErrorDetail{Field: "pan", Reason: "required"})),
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   domain.ErrorCode_ValidationFailed,
+			expectedResp:   commonhelper.API_RESP_STATUS__REJECT,
+		},
+		{
+			name:           "binding error",
+			err:            echo.NewBindingError("limit", []string{"abc"}, "invalid number", nil),
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   domain.ErrorCode_BadRequest,
+			expectedResp:   commonhelper.API_RESP_STATUS__REJECT,
+		},
+		{
+			name:           "echo http error",
+			err:            echo.NewHTTPError(http.StatusUnauthorized, "invalid or expired bearer token"),
+			expectedStatus: http.StatusUnauthorized,
+			expectedCode:   domain.ErrorCode_Unauthorized,
+			expectedResp:   commonhelper.API_RESP_STATUS__REJECT,
+		},
+		{
+			name:           "gorm record not found",
+			err:            fmt.Errorf("get card: %w", gorm.ErrRecordNotFound),
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   domain.ErrorCode_NotFound,
+			expectedResp:   commonhelper.API_RESP_STATUS__REJECT,
+		},
+		{
+			name:           "negative cache hit",
+			err:            cachehelper.ErrNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   domain.ErrorCode_NotFound,
+			expectedResp:   commonhelper.API_RESP_STATUS__REJECT,
+		},
+		{
+			name:           "redis nil",
+			err:            redis.Nil,
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   domain.ErrorCode_NotFound,
+			expectedResp:   commonhelper.API_RESP_STATUS__REJECT,
+		},
+		{
+			name:           "deadline exceeded",
+			err:            fmt.Errorf("query: %w", context.DeadlineExceeded),
+			expectedStatus: http.StatusGatewayTimeout,
+			expectedCode:   domain.ErrorCode_Timeout,
+			expectedResp:   commonhelper.API_RESP_STATUS__FAILED,
+		},
+		{
+			name:           "unknown error",
+			err:            errors.New("connection reset by peer"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedCode:   domain.ErrorCode_Internal,
+			expectedResp:   commonhelper.API_RESP_STATUS__FAILED,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/cards", nil)
+			req = req.WithContext(commonhelper.SetTraceIdToContext(req.Context(), "trace-1"))
+			rec := httptest.NewRecorder()
+
+			HTTPErrorHandler(tt.err, echo.New().NewContext(req, rec))
+
+			if rec.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, actual: %d", tt.expectedStatus, rec.Code)
+			}
+			var resp ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Code != tt.expectedCode || resp.Status != tt.expectedResp || resp.TraceId != "trace-1" {
+				t.Errorf("unexpected response: %+v", resp)
+			}
+			if tt.expectedCode == domain.ErrorCode_Internal && resp.Message != http.StatusText(http.StatusInternalServerError) {
+				t.Errorf("internal error must not leak its cause, actual: %q", resp.Message)
+			}
+		})
+	}
+}
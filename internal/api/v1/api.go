@@ -54,7 +54,11 @@ func (a *apiServer) ConfigRoute(eg *echo.Group) {
 }
 
 func (h *apiServer) readiness(c echo.Context) error {
-	return c.JSON(http.StatusOK, nil)
+	report := h.useCase.Readiness(c.Request().Context())
+	if !report.IsReady() {
+		return c.JSON(http.StatusServiceUnavailable, report)
+	}
+	return c.JSON(http.StatusOK, report)
 }
 
 func (h *apiServer) liveness(c echo.Context) error {
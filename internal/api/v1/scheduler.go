@@ -0,0 +1,91 @@
+package v1
+
+import (
+	"go-clean-arch/internal/domain"
+	"go-clean-arch/internal/usecase"
+	"net/http"
+	"strconv"
+
+	echo "github.com/labstack/echo/v4"
+)
+
+const maxRunsLimit = 100
+
+type schedulerAPIServer struct {
+	useCase usecase.SchedulerUsecase
+}
+
+func NewSchedulerAPIServer(useCase usecase.SchedulerUsecase) APIServer {
+	return &schedulerAPIServer{
+		useCase: useCase,
+	}
+}
+
+// ConfigRoute creates the admin routes of the scheduled jobs
+func (a *schedulerAPIServer) ConfigRoute(eg *echo.Group) {
+	route := eg.Group("/admin/jobs")
+
+	// List jobs
+	// @Summary List the scheduled jobs.
+	// @Description List the scheduled jobs with their next run and the last run started by the instance.
+	// @Tags Scheduler
+	// @Security BasicAuth
+	// @Produce json
+	// @Success 200 {array} schedulerhelper.JobInfo
+	// @Router /v1/admin/jobs [get]
+	route.GET("", a.listJobs)
+
+	// List runs
+	// @Summary List the last runs of a job.
+	// @Tags Scheduler
+	// @Security BasicAuth
+	// @Produce json
+	// @Param name path string true "job name"
+	// @Param limit query int false "number of runs, 20 by default"
+	// @Success 200 {array} schedulerhelper.RunRecord
+	// @Failure 404 {object} api.ErrorResponse
+	// @Router /v1/admin/jobs/{name}/runs [get]
+	route.GET("/:name/runs", a.listRuns)
+
+	// Trigger job
+	// @Summary Start a run of a job now.
+	// @Tags Scheduler
+	// @Security BasicAuth
+	// @Produce json
+	// @Param name path string true "job name"
+	// @Success 202 {object} schedulerhelper.RunRecord
+	// @Failure 404 {object} api.ErrorResponse
+	// @Failure 409 {object} api.ErrorResponse
+	// @Router /v1/admin/jobs/{name}/trigger [post]
+	route.POST("/:name/trigger", a.triggerJob)
+}
+
+func (h *schedulerAPIServer) listJobs(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.useCase.ListJobs(c.Request().Context()))
+}
+
+func (h *schedulerAPIServer) listRuns(c echo.Context) error {
+	limit := 0
+	if value := c.QueryParam("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 || limit > maxRunsLimit {
+			return domain.NewValidationError("invalid limit", domain.ErrorDetail{
+				Field:  "limit",
+				Reason: "must be between 1 and " + strconv.Itoa(maxRunsLimit),
+			})
+		}
+	}
+	runs, err := h.useCase.ListRuns(c.Request().Context(), c.Param("name"), limit)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, runs)
+}
+
+func (h *schedulerAPIServer) triggerJob(c echo.Context) error {
+	run, err := h.useCase.TriggerJob(c.Request().Context(), c.Param("name"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusAccepted, run)
+}
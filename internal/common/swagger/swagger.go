@@ -2,8 +2,16 @@ package swagger
 
 type Ok struct{}
 
+// Error documents the envelope rendered by api.HTTPErrorHandler.
 type Error struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-	TraceId string `json:"trace_id"`
+	Status  string        `json:"status" example:"REJECT"`
+	Code    string        `json:"code" example:"VALIDATION_FAILED"`
+	Message string        `json:"message"`
+	Details []ErrorDetail `json:"details,omitempty"`
+	TraceId string        `json:"trace_id"`
+}
+
+type ErrorDetail struct {
+	Field  string `json:"field,omitempty"`
+	Reason string `json:"reason"`
 }
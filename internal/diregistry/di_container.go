@@ -1,31 +1,55 @@
 package diregistry
 
 import (
+	"context"
+	"fmt"
 	"go-clean-arch/config"
+	"go-clean-arch/helper-libs/cachehelper"
+	"go-clean-arch/helper-libs/confighelper"
 	"go-clean-arch/helper-libs/copyhelper"
 	"go-clean-arch/helper-libs/dihelper"
+	"go-clean-arch/helper-libs/healthhelper"
+	"go-clean-arch/helper-libs/idempotencyhelper"
+	"go-clean-arch/helper-libs/ratelimithelper"
+	"go-clean-arch/helper-libs/redisclienthelper"
+	"go-clean-arch/helper-libs/redisleaderhelper"
+	"go-clean-arch/helper-libs/redislockhelper"
+	"go-clean-arch/helper-libs/schedulerhelper"
 	"go-clean-arch/helper-libs/sqlormhelper"
 	v1 "go-clean-arch/internal/api/v1"
 	"go-clean-arch/internal/usecase"
+	"time"
 
 	"github.com/sarulabs/di"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
 // DI Path
 const (
 	// Redis
 	CacheHelperDIName       string = "RedisCacheHelper"
+	TieredCacheHelperDIName string = "TieredCacheHelper"
+	StreamHelperDIName      string = "StreamHelper"
+	KeyEventHelperDIName    string = "KeyEventHelper"
 	RedisClientHelperDIName string = "RedisClientHelper"
+	RedisLockHelperDIName   string = "RedisLockHelper"
+	LeaderHelperDIName      string = "LeaderHelper"
 
 	// Config
-	ConfigDIName string = "Config"
+	ConfigDIName       string = "Config"
+	ConfigLoaderDIName string = "ConfigLoader"
 
 	// Helper
 	PbConverterDIName      string = "PbConverter"
 	ModelConverterDIName   string = "ModelConverter"
 	AdapterConverterDIName string = "AdapterConverter"
 	SqlGormHelperDIName    string = "SqlGormHelper"
+	HealthRegistryDIName   string = "HealthRegistry"
+	RateLimiterDIName      string = "RateLimiter"
+	IdempotencyStoreDIName string = "IdempotencyStore"
+	UnitOfWorkDIName       string = "UnitOfWork"
+	SchedulerDIName        string = "Scheduler"
 
 	DataBaseDIName string = "Database"
 
@@ -33,10 +57,12 @@ const (
 	BaseRepositoryDIName string = "BaseRepository"
 
 	//Usecase
-	HealthUsecaseDIName string = "HealthUsecase"
+	HealthUsecaseDIName    string = "HealthUsecase"
+	SchedulerUsecaseDIName string = "SchedulerUsecase"
 
 	// Api
-	ApiServerV1DIName string = "ApiServerV1"
+	ApiServerV1DIName          string = "ApiServerV1"
+	SchedulerApiServerV1DIName string = "SchedulerApiServerV1"
 )
 
 func BuildDIContainer() {
@@ -52,10 +78,20 @@ func initBuilder() {
 	dihelper.ConfigsBuilder = func() []di.Def {
 		arr := []di.Def{}
 		arr = append(arr, di.Def{
+			Name:  ConfigLoaderDIName,
+			Scope: di.App,
+			Build: func(ctn di.Container) (interface{}, error) {
+				return config.NewLoader(), nil
+			},
+			Close: func(obj interface{}) error {
+				return nil
+			},
+		}, di.Def{
 			Name:  ConfigDIName,
 			Scope: di.App,
 			Build: func(ctn di.Container) (interface{}, error) {
-				cfg, err := config.Load()
+				loader := ctn.Get(ConfigLoaderDIName).(*confighelper.Loader[config.Config])
+				cfg, err := loader.Load()
 				return cfg, err
 			},
 			Close: func(obj interface{}) error {
@@ -74,18 +110,222 @@ func initBuilder() {
 			Build: func(ctn di.Container) (interface{}, error) {
 				cfg := ctn.Get(ConfigDIName).(*config.Config)
 				return sqlormhelper.NewGormPostgresqlDB(&sqlormhelper.GormConnectionOptions{
-					Host:       cfg.Database.Host,
-					Port:       int(cfg.Database.Port),
-					Username:   cfg.Database.Username,
-					Password:   cfg.Database.Password,
-					Database:   cfg.Database.Database,
-					Schema:     cfg.Database.SearchPath,
-					GormConfig: &gorm.Config{},
+					Host:     cfg.Database.Host,
+					Port:     int(cfg.Database.Port),
+					Username: cfg.Database.Username,
+					Password: cfg.Database.Password,
+					Database: cfg.Database.Database,
+					Schema:   cfg.Database.SearchPath,
+					GormConfig: &gorm.Config{
+						Logger: sqlormhelper.NewGormLogger(logger.Config{
+							SlowThreshold:             time.Second,
+							LogLevel:                  logger.Warn,
+							IgnoreRecordNotFoundError: true,
+						}),
+					},
+				}), nil
+			},
+			Close: func(obj interface{}) error {
+				return obj.(sqlormhelper.SqlGormDatabase).Close()
+			},
+		}, di.Def{
+			Name:  UnitOfWorkDIName,
+			Scope: di.App,
+			Build: func(ctn di.Container) (interface{}, error) {
+				sql := ctn.Get(SqlGormHelperDIName).(sqlormhelper.SqlGormDatabase)
+				return sqlormhelper.NewUnitOfWork(sql), nil
+			},
+			Close: func(obj interface{}) error {
+				return nil
+			},
+		}, di.Def{
+			Name:  RedisClientHelperDIName,
+			Scope: di.App,
+			Build: func(ctn di.Container) (interface{}, error) {
+				cfg := ctn.Get(ConfigDIName).(*config.Config)
+				return redisclienthelper.NewRedisClientHelper(&redisclienthelper.RedisConfigOptions{
+					Addrs:    []string{fmt.Sprintf("%s:%d", cfg.Cache.Host, cfg.Cache.Port)},
+					Password: cfg.Cache.Password,
+				}), nil
+			},
+			Close: func(obj interface{}) error {
+				return obj.(*redisclienthelper.RedisClientHelper).Close()
+			},
+		}, di.Def{
+			Name:  CacheHelperDIName,
+			Scope: di.App,
+			Build: func(ctn di.Container) (interface{}, error) {
+				redisClient := ctn.Get(RedisClientHelperDIName).(*redisclienthelper.RedisClientHelper)
+				return cachehelper.NewCacheHelper(&cachehelper.CacheConfigOptions{
+					RedisClientHelper: redisClient,
+				}), nil
+			},
+			Close: func(obj interface{}) error {
+				return nil
+			},
+		}, di.Def{
+			Name:  TieredCacheHelperDIName,
+			Scope: di.App,
+			Build: func(ctn di.Container) (interface{}, error) {
+				cfg := ctn.Get(ConfigDIName).(*config.Config)
+				return cachehelper.NewTieredCacheHelper(&cachehelper.TieredCacheOptions{
+					CacheHelper: ctn.Get(CacheHelperDIName).(cachehelper.CacheHelper),
+					Name:        cfg.App,
+					Size:        cfg.Cache.LocalSize,
+					LocalTTL:    cfg.Cache.LocalTTL,
+					Channel:     cfg.App + ":cache:invalidation",
+				}), nil
+			},
+			Close: func(obj interface{}) error {
+				return obj.(cachehelper.TieredCacheHelper).Close()
+			},
+		}, di.Def{
+			Name:  StreamHelperDIName,
+			Scope: di.App,
+			Build: func(ctn di.Container) (interface{}, error) {
+				cfg := ctn.Get(ConfigDIName).(*config.Config)
+				return cachehelper.NewStreamHelper(&cachehelper.StreamOptions{
+					RedisClientHelper: ctn.Get(RedisClientHelperDIName).(*redisclienthelper.RedisClientHelper),
+					Group:             cfg.App,
 				}), nil
 			},
 			Close: func(obj interface{}) error {
 				return nil
 			},
+		}, di.Def{
+			Name:  KeyEventHelperDIName,
+			Scope: di.App,
+			Build: func(ctn di.Container) (interface{}, error) {
+				return cachehelper.NewKeyEventHelper(&cachehelper.KeyEventOptions{
+					RedisClientHelper: ctn.Get(RedisClientHelperDIName).(*redisclienthelper.RedisClientHelper),
+				}), nil
+			},
+			Close: func(obj interface{}) error {
+				return nil
+			},
+		}, di.Def{
+			Name:  RedisLockHelperDIName,
+			Scope: di.App,
+			Build: func(ctn di.Container) (interface{}, error) {
+				redisClient := ctn.Get(RedisClientHelperDIName).(*redisclienthelper.RedisClientHelper)
+				return redislockhelper.NewRedisLockerHelper(&redislockhelper.RedisLockerOptions{
+					RedisClientHelper: redisClient,
+				}), nil
+			},
+			Close: func(obj interface{}) error {
+				return nil
+			},
+		}, di.Def{
+			Name:  LeaderHelperDIName,
+			Scope: di.App,
+			Build: func(ctn di.Container) (interface{}, error) {
+				cfg := ctn.Get(ConfigDIName).(*config.Config)
+				return redisleaderhelper.NewRedisLeaderHelper(&redisleaderhelper.RedisLeaderOptions{
+					RedisClientHelper: ctn.Get(RedisClientHelperDIName).(*redisclienthelper.RedisClientHelper),
+					Key:               cfg.App + ":scheduler",
+					Ttl:               cfg.Scheduler.LeaderTtl,
+				}), nil
+			},
+			Close: func(obj interface{}) error {
+				return nil
+			},
+		}, di.Def{
+			Name:  SchedulerDIName,
+			Scope: di.App,
+			Build: func(ctn di.Container) (interface{}, error) {
+				cfg := ctn.Get(ConfigDIName).(*config.Config)
+				leader := ctn.Get(LeaderHelperDIName).(redisleaderhelper.RedisLeaderHelper)
+				history := schedulerhelper.NewPostgresRunHistory(&schedulerhelper.PostgresRunHistoryOptions{
+					Sql: ctn.Get(SqlGormHelperDIName).(sqlormhelper.SqlGormDatabase),
+				})
+				if cfg.Database.AutoMigration {
+					if err := history.Migrate(context.Background()); err != nil {
+						return nil, err
+					}
+				}
+				scheduler := schedulerhelper.NewScheduler(&schedulerhelper.SchedulerOptions{
+					Leader:        leader,
+					Locker:        ctn.Get(RedisLockHelperDIName).(*redislockhelper.RedisLockHelper),
+					LockPrefix:    cfg.App + ":scheduler:",
+					History:       history,
+					MaxConcurrent: cfg.Scheduler.MaxConcurrent,
+				})
+				if cfg.Scheduler.HistoryRetention > 0 {
+					err := scheduler.Register(schedulerhelper.Job{
+						Name:     "purge-job-runs",
+						Schedule: "@daily",
+						Jitter:   time.Hour,
+						Run: func(ctx context.Context) error {
+							_, err := history.Purge(ctx, time.Now().Add(-cfg.Scheduler.HistoryRetention))
+							return err
+						},
+					})
+					if err != nil {
+						return nil, err
+					}
+				}
+				ctn.Get(HealthRegistryDIName).(healthhelper.Registry).Register(healthhelper.Checker{
+					Name:  "leader_election",
+					Check: leader.HealthCheck,
+				})
+				return scheduler, nil
+			},
+			Close: func(obj interface{}) error {
+				return nil
+			},
+		}, di.Def{
+			Name:  IdempotencyStoreDIName,
+			Scope: di.App,
+			Build: func(ctn di.Container) (interface{}, error) {
+				cfg := ctn.Get(ConfigDIName).(*config.Config)
+				if cfg.Idempotency.Store != config.IdempotencyStore_Postgres {
+					return idempotencyhelper.NewRedisStore(&idempotencyhelper.RedisStoreOptions{
+						CacheHelper: ctn.Get(CacheHelperDIName).(cachehelper.CacheHelper),
+						KeyPrefix:   cfg.App + ":idempotency:",
+					}), nil
+				}
+				store := idempotencyhelper.NewPostgresStore(&idempotencyhelper.PostgresStoreOptions{
+					Sql: ctn.Get(SqlGormHelperDIName).(sqlormhelper.SqlGormDatabase),
+				})
+				if cfg.Database.AutoMigration {
+					if err := store.Migrate(context.Background()); err != nil {
+						return nil, err
+					}
+				}
+				return store, nil
+			},
+			Close: func(obj interface{}) error {
+				return nil
+			},
+		}, di.Def{
+			Name:  RateLimiterDIName,
+			Scope: di.App,
+			Build: func(ctn di.Container) (interface{}, error) {
+				cfg := ctn.Get(ConfigDIName).(*config.Config)
+				redisClient := ctn.Get(RedisClientHelperDIName).(*redisclienthelper.RedisClientHelper)
+				algorithm, err := ratelimithelper.ParseAlgorithm(cfg.Server.RateLimitAlgorithm)
+				if err != nil {
+					return nil, err
+				}
+				return ratelimithelper.NewRedisLimiter(&ratelimithelper.RedisLimiterOptions{
+					Client:    redisClient,
+					Algorithm: algorithm,
+					KeyPrefix: cfg.App + ":ratelimit:",
+				}), nil
+			},
+			Close: func(obj interface{}) error {
+				return nil
+			},
+		}, di.Def{
+			Name:  HealthRegistryDIName,
+			Scope: di.App,
+			Build: func(ctn di.Container) (interface{}, error) {
+				return healthhelper.NewRegistry(), nil
+			},
+			Close: func(obj interface{}) error {
+				obj.(healthhelper.Registry).MarkShuttingDown()
+				return nil
+			},
 		}, di.Def{
 			Name:  ModelConverterDIName,
 			Scope: di.App,
@@ -101,14 +341,15 @@ func initBuilder() {
 
 	dihelper.RepositoriesBuilder = func() []di.Def {
 		arr := []di.Def{}
+		// Each aggregate only needs its entity struct, e.g. for `type Merchant struct { sqlormhelper.BaseEntityWithId ... }`
 		// arr = append(arr,
 		// 	di.Def{
-		// 		Name:  BaseRepositoryDIName,
+		// 		Name:  MerchantRepositoryDIName,
 		// 		Scope: di.App,
 		// 		Build: func(ctn di.Container) (interface{}, error) {
 		// 			sql := ctn.Get(SqlGormHelperDIName).(sqlormhelper.SqlGormDatabase)
-		// 			baseRepository := repository.NewBaseRepository(sql)
-		// 			return baseRepository, nil
+		// 			merchantRepository := repository.NewBaseRepository[entity.Merchant, string](sql)
+		// 			return merchantRepository, nil
 		// 		},
 		// 		Close: func(obj interface{}) error {
 		// 			return nil
@@ -126,7 +367,33 @@ func initBuilder() {
 				Name:  HealthUsecaseDIName,
 				Scope: di.App,
 				Build: func(ctn di.Container) (interface{}, error) {
-					return usecase.NewHealthUsecase(), nil
+					registry := ctn.Get(HealthRegistryDIName).(healthhelper.Registry)
+					sqlGorm := ctn.Get(SqlGormHelperDIName).(sqlormhelper.SqlGormDatabase)
+					redisClient := ctn.Get(RedisClientHelperDIName).(*redisclienthelper.RedisClientHelper)
+					registry.Register(
+						healthhelper.Checker{
+							Name:     "postgres",
+							Check:    sqlGorm.Ping,
+							Critical: true,
+						},
+						healthhelper.Checker{
+							Name:     "redis",
+							Check:    redisClient.Ping,
+							Critical: true,
+						},
+					)
+					return usecase.NewHealthUsecase(registry), nil
+				},
+				Close: func(obj interface{}) error {
+					return nil
+				},
+			},
+			di.Def{
+				Name:  SchedulerUsecaseDIName,
+				Scope: di.App,
+				Build: func(ctn di.Container) (interface{}, error) {
+					scheduler := ctn.Get(SchedulerDIName).(schedulerhelper.Scheduler)
+					return usecase.NewSchedulerUsecase(scheduler), nil
 				},
 				Close: func(obj interface{}) error {
 					return nil
@@ -151,6 +418,17 @@ func initBuilder() {
 					return nil
 				},
 			},
+			di.Def{
+				Name:  SchedulerApiServerV1DIName,
+				Scope: di.App,
+				Build: func(ctn di.Container) (interface{}, error) {
+					schedulerUsecase := ctn.Get(SchedulerUsecaseDIName).(usecase.SchedulerUsecase)
+					return v1.NewSchedulerAPIServer(schedulerUsecase), nil
+				},
+				Close: func(obj interface{}) error {
+					return nil
+				},
+			},
 		)
 		return arr
 	}
@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	ErrNotFound = errors.New("record not found")
+)
+
+type ErrorCode string
+
+const (
+	ErrorCode_BadRequest       ErrorCode = "BAD_REQUEST"
+	ErrorCode_ValidationFailed ErrorCode = "VALIDATION_FAILED"
+	ErrorCode_Unauthorized     ErrorCode = "UNAUTHORIZED"
+	ErrorCode_Forbidden        ErrorCode = "FORBIDDEN"
+	ErrorCode_NotFound         ErrorCode = "NOT_FOUND"
+	ErrorCode_Conflict         ErrorCode = "CONFLICT"
+	ErrorCode_TooManyRequests  ErrorCode = "TOO_MANY_REQUESTS"
+	ErrorCode_RequestCanceled  ErrorCode = "REQUEST_CANCELED"
+	ErrorCode_Timeout          ErrorCode = "TIMEOUT"
+	ErrorCode_Unavailable      ErrorCode = "SERVICE_UNAVAILABLE"
+	ErrorCode_Internal         ErrorCode = "INTERNAL_ERROR"
+)
+
+type (
+	// ErrorDetail describes why a single field, or the request as a whole when Field is empty, was rejected.
+	ErrorDetail struct {
+		Field  string `json:"field,omitempty"`
+		Reason string `json:"reason"`
+	}
+
+	// Error is returned by usecases when a failure must reach the client with a stable code,
+	// the HTTP layer renders it as is while any other error becomes an internal error.
+	Error struct {
+		Code       ErrorCode
+		HttpStatus int
+		Message    string
+		Details    []ErrorDetail
+		// Err is the cause, it is logged but never sent to the client.
+		Err error
+	}
+)
+
+func NewError(code ErrorCode, httpStatus int, message string) *Error {
+	return &Error{
+		Code:       code,
+		HttpStatus: httpStatus,
+		Message:    message,
+	}
+}
+
+func NewBadRequestError(message string) *Error {
+	return NewError(ErrorCode_BadRequest, http.StatusBadRequest, message)
+}
+
+func NewValidationError(message string, details ...ErrorDetail) *Error {
+	return NewError(ErrorCode_ValidationFailed, http.StatusUnprocessableEntity, message).WithDetails(details...)
+}
+
+func NewNotFoundError(message string) *Error {
+	return NewError(ErrorCode_NotFound, http.StatusNotFound, message)
+}
+
+func NewConflictError(message string) *Error {
+	return NewError(ErrorCode_Conflict, http.StatusConflict, message)
+}
+
+func NewInternalError(err error) *Error {
+	return NewError(ErrorCode_Internal, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).Wrap(err)
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WithDetails returns a copy of the error carrying details in addition to the existing ones.
+func (e *Error) WithDetails(details ...ErrorDetail) *Error {
+	clone := *e
+	clone.Details = append(append([]ErrorDetail{}, e.Details...), details...)
+	return &clone
+}
+
+// Wrap returns a copy of the error caused by err.
+func (e *Error) Wrap(err error) *Error {
+	clone := *e
+	clone.Err = err
+	return &clone
+}
@@ -1,27 +1,104 @@
 package repository
 
 import (
-	"go-clean-arch/config"
-	"go-clean-arch/helper-libs/copyhelper"
-	"go-clean-arch/helper-libs/sqlxhelper"
+	"context"
+	"errors"
+	"go-clean-arch/helper-libs/sqlormhelper"
+	"go-clean-arch/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+const (
+	idCondition = sqlormhelper.BaseColumnNameType_Id + " = ?"
 )
 
 type (
-	baseRepository struct {
-		cfg          *config.Config
-		objectCopier copyhelper.ObjectCopier
-		sqldb        sqlxhelper.SqlDatabase
+	baseRepository[T any, ID comparable] struct {
+		sql sqlormhelper.SqlGormDatabase
 	}
 )
 
-func NewBaseRepository(
-	cfg *config.Config,
-	objectCopier copyhelper.ObjectCopier,
-	sqldb sqlxhelper.SqlDatabase,
-) BaseRepository {
-	return &baseRepository{
-		cfg:          cfg,
-		objectCopier: objectCopier,
-		sqldb:        sqldb,
+// NewBaseRepository creates the gorm backed Repository of entity T, calls join the transaction carried by the context if any.
+func NewBaseRepository[T any, ID comparable](sql sqlormhelper.SqlGormDatabase) Repository[T, ID] {
+	return &baseRepository[T, ID]{
+		sql: sql,
+	}
+}
+
+func (r *baseRepository[T, ID]) Create(ctx context.Context, entity *T) error {
+	return r.crud(ctx).Create(r.db(ctx), entity)
+}
+
+func (r *baseRepository[T, ID]) Get(ctx context.Context, id ID) (*T, error) {
+	output := new(T)
+	err := r.crud(ctx).View(r.db(ctx), output, idCondition, id)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return output, nil
+}
+
+func (r *baseRepository[T, ID]) List(ctx context.Context, lq *sqlormhelper.ListQueryCondition, count *int64) ([]*T, error) {
+	output := []*T{}
+	err := r.crud(ctx).List(r.db(ctx).Model(new(T)), &output, lq, count)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return output, nil
+}
+
+func (r *baseRepository[T, ID]) Update(ctx context.Context, id ID, updates interface{}) error {
+	crud := r.crud(ctx)
+	return affected(crud, crud.Update(r.db(ctx), updates, idCondition, id))
+}
+
+func (r *baseRepository[T, ID]) SoftDelete(ctx context.Context, id ID) error {
+	crud := r.crud(ctx)
+	return affected(crud, crud.Delete(r.db(ctx), idCondition, id))
+}
+
+func (r *baseRepository[T, ID]) HardDelete(ctx context.Context, id ID) error {
+	crud := r.crud(ctx)
+	return affected(crud, crud.DeletePermanently(r.db(ctx), idCondition, id))
+}
+
+func (r *baseRepository[T, ID]) Exists(ctx context.Context, id ID) (bool, error) {
+	exists, err := r.crud(ctx).Exist(r.db(ctx), idCondition, id)
+	return exists, mapError(err)
+}
+
+func (r *baseRepository[T, ID]) BatchInsert(ctx context.Context, entities []*T, batchSize int) error {
+	if len(entities) == 0 {
+		return nil
+	}
+	return r.crud(ctx).CreateInBatches(r.db(ctx), entities, batchSize)
+}
+
+// crud returns a new helper per call since CRUDHelper keeps the last executed statement.
+func (r *baseRepository[T, ID]) crud(ctx context.Context) sqlormhelper.CRUDHelper {
+	return sqlormhelper.NewCRUDHelper(r.db(ctx), new(T))
+}
+
+// db returns the transaction carried by ctx, or the root connection when there is none.
+func (r *baseRepository[T, ID]) db(ctx context.Context) *gorm.DB {
+	return sqlormhelper.DBFromContext(ctx, r.sql)
+}
+
+// affected maps err, or returns domain.ErrNotFound when the last statement of crud matched no record.
+func affected(crud sqlormhelper.CRUDHelper, err error) error {
+	if err != nil {
+		return mapError(err)
+	}
+	if crud.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func mapError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return domain.ErrNotFound
 	}
+	return err
 }
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"go-clean-arch/helper-libs/sqlormhelper"
+	"go-clean-arch/internal/domain"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type (
+	testAccount struct {
+		Id        string `gorm:"primaryKey"`
+		Name      string
+		DeletedAt gorm.DeletedAt
+	}
+
+	// mockGormDatabase only serves Open, the other methods aren't used by repositories.
+	mockGormDatabase struct {
+		sqlormhelper.SqlGormDatabase
+		db *gorm.DB
+	}
+)
+
+func (d *mockGormDatabase) Open() *gorm.DB {
+	return d.db
+}
+
+// newMockRepository uses a single connection, so a call that doesn't join the transaction of its context blocks.
+func newMockRepository(t *testing.T) (Repository[testAccount, string], sqlormhelper.SqlGormDatabase, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock, err: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	database := &mockGormDatabase{db: gormDB}
+	return NewBaseRepository[testAccount, string](database), database, mock
+}
+
+func TestBaseRepositoryGetNotFound(t *testing.T) {
+	repository, _, mock := newMockRepository(t)
+	mock.ExpectQuery(`SELECT \* FROM "test_accounts" WHERE id = \$1`).
+		WithArgs("missing", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "deleted_at"}))
+
+	if _, err := repository.Get(context.Background(), "missing"); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected: %v, actual: %v", domain.ErrNotFound, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBaseRepositoryMissingRecord(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		call   func(ctx context.Context, repository Repository[testAccount, string]) error
+		result int64
+		err    error
+	}{
+		{
+			name:  "update",
+			query: `UPDATE "test_accounts" SET "name"=\$1 WHERE id = \$2`,
+			call: func(ctx context.Context, repository Repository[testAccount, string]) error {
+				return repository.Update(ctx, "missing", map[string]interface{}{"name": "Bob"})
+			},
+			err: domain.ErrNotFound,
+		},
+		{
+			name:  "soft delete",
+			query: `UPDATE "test_accounts" SET "deleted_at"=\$1 WHERE id = \$2 AND "test_accounts"."deleted_at" IS NULL`,
+			call: func(ctx context.Context, repository Repository[testAccount, string]) error {
+				return repository.SoftDelete(ctx, "missing")
+			},
+			err: domain.ErrNotFound,
+		},
+		{
+			name:  "hard delete",
+			query: `DELETE FROM "test_accounts" WHERE id = \$1`,
+			call: func(ctx context.Context, repository Repository[testAccount, string]) error {
+				return repository.HardDelete(ctx, "missing")
+			},
+			err: domain.ErrNotFound,
+		},
+		{
+			name:  "existing record",
+			query: `DELETE FROM "test_accounts" WHERE id = \$1`,
+			call: func(ctx context.Context, repository Repository[testAccount, string]) error {
+				return repository.HardDelete(ctx, "1")
+			},
+			result: 1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repository, _, mock := newMockRepository(t)
+			mock.ExpectExec(test.query).WillReturnResult(sqlmock.NewResult(0, test.result))
+
+			if err := test.call(context.Background(), repository); !errors.Is(err, test.err) {
+				t.Errorf("expected: %v, actual: %v", test.err, err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestBaseRepositoryJoinsTransactionOfContext(t *testing.T) {
+	repository, database, mock := newMockRepository(t)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "test_accounts" SET "name"=\$1 WHERE id = \$2`).
+		WithArgs("Bob", "1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// The transaction holds the only connection, the update would wait for it until the deadline otherwise
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := sqlormhelper.NewUnitOfWork(database).Do(ctx, func(ctx context.Context) error {
+		return repository.Update(ctx, "1", map[string]interface{}{"name": "Bob"})
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -1,6 +1,27 @@
 package repository
 
+import (
+	"context"
+	"go-clean-arch/helper-libs/sqlormhelper"
+)
+
 type (
-	BaseRepository interface {
+	// Repository is the typed CRUD contract of an aggregate, T is the gorm entity and ID the type of its `id` primary key.
+	Repository[T any, ID comparable] interface {
+		Create(ctx context.Context, entity *T) error
+		// Get returns domain.ErrNotFound when there is no record with the given id.
+		Get(ctx context.Context, id ID) (*T, error)
+		// List applies filter & pagination of `lq` if given, `count` receives the total number of records when not nil.
+		List(ctx context.Context, lq *sqlormhelper.ListQueryCondition, count *int64) ([]*T, error)
+		// Update could receive a model struct or map[string]interface{} as `updates`, it returns domain.ErrNotFound
+		// when there is no record with the given id.
+		Update(ctx context.Context, id ID, updates interface{}) error
+		// SoftDelete only sets `deleted_at` when T has a gorm.DeletedAt field, otherwise the record is deleted.
+		// It returns domain.ErrNotFound when there is no record with the given id, or it is already deleted.
+		SoftDelete(ctx context.Context, id ID) error
+		// HardDelete returns domain.ErrNotFound when there is no record with the given id.
+		HardDelete(ctx context.Context, id ID) error
+		Exists(ctx context.Context, id ID) (bool, error)
+		BatchInsert(ctx context.Context, entities []*T, batchSize int) error
 	}
 )
@@ -1,23 +1,31 @@
 package usecase
 
+import (
+	"context"
+	"go-clean-arch/helper-libs/healthhelper"
+)
+
 type (
 	HealthUsecase interface {
 		Liveness() error
-		Readiness() error
+		Readiness(ctx context.Context) *healthhelper.Report
 	}
 
 	healthUsecase struct {
+		registry healthhelper.Registry
 	}
 )
 
-func NewHealthUsecase() HealthUsecase {
-	return &healthUsecase{}
+func NewHealthUsecase(registry healthhelper.Registry) HealthUsecase {
+	return &healthUsecase{
+		registry: registry,
+	}
 }
 
 func (u *healthUsecase) Liveness() error {
 	return nil
 }
 
-func (u *healthUsecase) Readiness() error {
-	return nil
+func (u *healthUsecase) Readiness(ctx context.Context) *healthhelper.Report {
+	return u.registry.Check(ctx)
 }
@@ -0,0 +1,59 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"go-clean-arch/helper-libs/schedulerhelper"
+	"go-clean-arch/internal/domain"
+	"net/http"
+)
+
+type (
+	SchedulerUsecase interface {
+		ListJobs(ctx context.Context) []schedulerhelper.JobInfo
+		ListRuns(ctx context.Context, name string, limit int) ([]schedulerhelper.RunRecord, error)
+		TriggerJob(ctx context.Context, name string) (*schedulerhelper.RunRecord, error)
+	}
+
+	schedulerUsecase struct {
+		scheduler schedulerhelper.Scheduler
+	}
+)
+
+func NewSchedulerUsecase(scheduler schedulerhelper.Scheduler) SchedulerUsecase {
+	return &schedulerUsecase{
+		scheduler: scheduler,
+	}
+}
+
+func (u *schedulerUsecase) ListJobs(ctx context.Context) []schedulerhelper.JobInfo {
+	return u.scheduler.Jobs()
+}
+
+func (u *schedulerUsecase) ListRuns(ctx context.Context, name string, limit int) ([]schedulerhelper.RunRecord, error) {
+	runs, err := u.scheduler.History(ctx, name, limit)
+	if err != nil {
+		return nil, toSchedulerError(name, err)
+	}
+	return runs, nil
+}
+
+func (u *schedulerUsecase) TriggerJob(ctx context.Context, name string) (*schedulerhelper.RunRecord, error) {
+	run, err := u.scheduler.Trigger(ctx, name)
+	if err != nil {
+		return nil, toSchedulerError(name, err)
+	}
+	return run, nil
+}
+
+func toSchedulerError(name string, err error) error {
+	switch {
+	case errors.Is(err, schedulerhelper.ErrJobNotFound):
+		return domain.NewNotFoundError("job " + name + " not found").Wrap(err)
+	case errors.Is(err, schedulerhelper.ErrJobRunning):
+		return domain.NewConflictError("job " + name + " is already running").Wrap(err)
+	case errors.Is(err, schedulerhelper.ErrNotRunning):
+		return domain.NewError(domain.ErrorCode_Unavailable, http.StatusServiceUnavailable, "scheduler is not running").Wrap(err)
+	}
+	return err
+}